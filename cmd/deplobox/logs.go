@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"deplobox/internal/history"
+
+	"github.com/spf13/cobra"
+)
+
+var logsDBPath string
+
+var logsCmd = &cobra.Command{
+	Use:   "logs DEPLOYMENT_ID",
+	Short: "Show the step log of a deployment",
+	Long: `Show a deployment from the history with the command, exit code, duration
+and captured output of each step (release build, shared files, hooks and
+health check).
+
+Deployment IDs are shown by the status endpoint and in the server log.
+
+Examples:
+  deplobox logs 42
+  deplobox logs 42 --db /var/lib/deplobox/deployments.db`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLogs,
+}
+
+func init() {
+	logsCmd.Flags().StringVar(&logsDBPath, "db", getEnvOrDefault("DEPLOBOX_DB_PATH", "./deployments.db"), "Path to SQLite database")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("invalid deployment ID '%s'", args[0])
+	}
+
+	hist, err := history.NewHistory(logsDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open history database %s: %w", logsDBPath, err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	record, err := hist.GetDeployment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("deployment %d not found in %s", id, logsDBPath)
+	}
+
+	steps, err := hist.GetDeploymentSteps(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deployment %d of project '%s' (%s)\n", record.ID, record.Project, record.Ref)
+	fmt.Printf("  Status:  %s\n", record.Status)
+	if record.TriggeredBy != nil {
+		fmt.Printf("  Trigger: %s by %s\n", record.Trigger, *record.TriggeredBy)
+	} else {
+		fmt.Printf("  Trigger: %s\n", record.Trigger)
+	}
+	if record.CommitHash != nil {
+		fmt.Printf("  Commit:  %s\n", *record.CommitHash)
+	}
+	fmt.Printf("  Started: %s\n", record.StartedAt.Format(time.RFC3339))
+	if record.DurationSeconds != nil {
+		fmt.Printf("  Took:    %.1fs\n", *record.DurationSeconds)
+	}
+	if record.ErrorMessage != nil {
+		fmt.Printf("  Error:   %s\n", *record.ErrorMessage)
+	}
+
+	if len(steps) == 0 {
+		fmt.Println("\nNo steps recorded.")
+		return nil
+	}
+
+	for _, step := range steps {
+		fmt.Printf("\n==> %s: %s (exit %d, %.1fs)\n", step.Step, step.Command, step.ExitCode, step.DurationSeconds)
+		if output := strings.TrimRight(step.Output, "\n"); output != "" {
+			fmt.Println(output)
+		}
+		if step.ErrorMessage != nil {
+			fmt.Printf("error: %s\n", *step.ErrorMessage)
+		}
+	}
+
+	return nil
+}
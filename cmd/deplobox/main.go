@@ -66,4 +66,7 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(releasesCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(tokensCmd)
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/project"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	releasesConfigFile string
+)
+
+var releasesCmd = &cobra.Command{
+	Use:   "releases",
+	Short: "Manage project releases",
+	Long: `List, inspect, compare and pin the releases of a project.
+
+Pinned releases are never removed by release cleanup, regardless of
+keep_releases, max_release_age or max_releases_size.`,
+}
+
+var releasesListCmd = &cobra.Command{
+	Use:   "list PROJECT_NAME",
+	Short: "List releases, newest first",
+	Long: `List the releases of a project with their commit, age and size.
+The current and previous releases and pinned releases are marked.
+
+Example:
+  deplobox releases list myapp`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReleasesList,
+}
+
+var releasesShowCmd = &cobra.Command{
+	Use:   "show PROJECT_NAME RELEASE",
+	Short: "Show the details of a release",
+	Long: `Show a release's manifest: commit, branch, pusher, deployment ID and timestamps.
+
+Example:
+  deplobox releases show myapp 20251207T130803Z-1a2b3c4`,
+	Args: cobra.ExactArgs(2),
+	RunE: runReleasesShow,
+}
+
+var releasesDiffCmd = &cobra.Command{
+	Use:   "diff PROJECT_NAME RELEASE_A RELEASE_B",
+	Short: "Show the commits and changed files between two releases",
+	Long: `Show the git log and diffstat from the commit of RELEASE_A to the commit of RELEASE_B.
+
+Example:
+  deplobox releases diff myapp 20251207T130803Z-1a2b3c4 20251207T141516Z-5d6e7f8`,
+	Args: cobra.ExactArgs(3),
+	RunE: runReleasesDiff,
+}
+
+var releasesPinCmd = &cobra.Command{
+	Use:   "pin PROJECT_NAME RELEASE",
+	Short: "Protect a release from cleanup",
+	Long: `Pin a release so that release cleanup never removes it.
+
+Example:
+  deplobox releases pin myapp 20251207T130803Z-1a2b3c4`,
+	Args: cobra.ExactArgs(2),
+	RunE: runReleasesPin,
+}
+
+var releasesUnpinCmd = &cobra.Command{
+	Use:   "unpin PROJECT_NAME RELEASE",
+	Short: "Allow a pinned release to be cleaned up",
+	Long: `Unpin a release so that release cleanup may remove it again.
+
+Example:
+  deplobox releases unpin myapp 20251207T130803Z-1a2b3c4`,
+	Args: cobra.ExactArgs(2),
+	RunE: runReleasesUnpin,
+}
+
+func init() {
+	// Config file flag, shared by all releases subcommands
+	releasesCmd.PersistentFlags().StringVarP(&releasesConfigFile, "config", "c", defaultConfigPath, "Path to projects config file")
+
+	releasesCmd.AddCommand(releasesListCmd)
+	releasesCmd.AddCommand(releasesShowCmd)
+	releasesCmd.AddCommand(releasesDiffCmd)
+	releasesCmd.AddCommand(releasesPinCmd)
+	releasesCmd.AddCommand(releasesUnpinCmd)
+}
+
+// loadReleasesProject loads the named project from the releases config file
+func loadReleasesProject(projectName string) (*project.Project, error) {
+	_, projects, err := project.LoadConfig(releasesConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config from %s: %w", releasesConfigFile, err)
+	}
+
+	proj, exists := projects[projectName]
+	if !exists {
+		return nil, fmt.Errorf("project '%s' not found in config file %s", projectName, releasesConfigFile)
+	}
+
+	return proj, nil
+}
+
+// releaseMarkers returns the current/previous/pinned labels of each release
+func releaseMarkers(executor *deployment.Executor, releases []deployment.Release) (map[string][]string, error) {
+	pinned, err := executor.PinnedReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	markers := make(map[string][]string)
+	if current, err := executor.CurrentRelease(); err == nil {
+		markers[current.Name] = append(markers[current.Name], "current")
+		if previous := deployment.PreviousRelease(releases, current.Name); previous != "" {
+			markers[previous] = append(markers[previous], "previous")
+		}
+	}
+	for _, release := range releases {
+		if pinned[release.Name] {
+			markers[release.Name] = append(markers[release.Name], "pinned")
+		}
+	}
+
+	return markers, nil
+}
+
+// shortCommit returns the abbreviated commit of a release, or "-" if unknown
+func shortCommit(executor *deployment.Executor, release deployment.Release, timeout int) string {
+	commit, err := executor.ReleaseCommit(context.Background(), release, timeout)
+	if err != nil {
+		return "-"
+	}
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return commit
+}
+
+// formatAge formats how long ago t was, e.g. "3h" or "12d"
+func formatAge(t time.Time) string {
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return "<1m"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age/time.Minute))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(age/(24*time.Hour)))
+	}
+}
+
+// formatSize formats a size in bytes with binary units, e.g. "12.5 MB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func runReleasesList(cmd *cobra.Command, args []string) error {
+	proj, err := loadReleasesProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	executor := deployment.NewExecutor(proj.Path)
+	releases, err := executor.ListReleases()
+	if err != nil {
+		return fmt.Errorf("list failed: %w", err)
+	}
+	if len(releases) == 0 {
+		fmt.Printf("No releases found for project '%s'\n", proj.Name)
+		return nil
+	}
+
+	markers, err := releaseMarkers(executor, releases)
+	if err != nil {
+		return fmt.Errorf("list failed: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RELEASE\tCOMMIT\tAGE\tSIZE\t")
+	for _, release := range releases {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			release.Name,
+			shortCommit(executor, release, proj.PullTimeout),
+			formatAge(release.Created),
+			formatSize(deployment.ReleaseSize(release)),
+			strings.Join(markers[release.Name], ", "),
+		)
+	}
+	return w.Flush()
+}
+
+func runReleasesShow(cmd *cobra.Command, args []string) error {
+	proj, err := loadReleasesProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	executor := deployment.NewExecutor(proj.Path)
+	release, err := executor.FindRelease(args[1])
+	if err != nil {
+		return fmt.Errorf("show failed: %w", err)
+	}
+	releases, err := executor.ListReleases()
+	if err != nil {
+		return fmt.Errorf("show failed: %w", err)
+	}
+	markers, err := releaseMarkers(executor, releases)
+	if err != nil {
+		return fmt.Errorf("show failed: %w", err)
+	}
+
+	fmt.Printf("Release:        %s\n", release.Name)
+	fmt.Printf("Path:           %s\n", release.Path)
+	if len(markers[release.Name]) > 0 {
+		fmt.Printf("Status:         %s\n", strings.Join(markers[release.Name], ", "))
+	}
+	if commit, err := executor.ReleaseCommit(context.Background(), *release, proj.PullTimeout); err == nil {
+		fmt.Printf("Commit:         %s\n", commit)
+	}
+	if manifest := release.Manifest; manifest != nil {
+		fmt.Printf("Branch:         %s\n", manifest.Branch)
+		if manifest.Pusher != "" {
+			fmt.Printf("Pusher:         %s\n", manifest.Pusher)
+		}
+		fmt.Printf("Deployment ID:  %s\n", manifest.DeploymentID)
+	}
+	fmt.Printf("Created:        %s (%s ago)\n", release.Created.Local().Format(time.RFC3339), formatAge(release.Created))
+	if release.Manifest != nil && release.Manifest.ActivatedAt != nil {
+		fmt.Printf("Activated:      %s\n", release.Manifest.ActivatedAt.Local().Format(time.RFC3339))
+	}
+	fmt.Printf("Size:           %s\n", formatSize(deployment.ReleaseSize(*release)))
+
+	return nil
+}
+
+func runReleasesDiff(cmd *cobra.Command, args []string) error {
+	proj, err := loadReleasesProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	executor := deployment.NewExecutor(proj.Path)
+	from, err := executor.FindRelease(args[1])
+	if err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+	to, err := executor.FindRelease(args[2])
+	if err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+
+	log, stat, err := executor.CompareReleases(context.Background(), *from, *to, proj.PullTimeout)
+	if err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+
+	fmt.Printf("Commits from %s to %s:\n", from.Name, to.Name)
+	if strings.TrimSpace(log) == "" {
+		fmt.Println("  (none)")
+	} else {
+		fmt.Print(log)
+	}
+	fmt.Println()
+	if strings.TrimSpace(stat) == "" {
+		fmt.Println("No file changes")
+	} else {
+		fmt.Print(stat)
+	}
+
+	return nil
+}
+
+func runReleasesPin(cmd *cobra.Command, args []string) error {
+	proj, err := loadReleasesProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The pins file is read by release cleanup in running deployments
+	lock, err := deployment.TryLockProject(proj.Path, "pin")
+	if err != nil {
+		return fmt.Errorf("pin failed: %w", err)
+	}
+	defer lock.Release()
+
+	executor := deployment.NewExecutor(proj.Path)
+	if err := executor.PinRelease(args[1]); err != nil {
+		return fmt.Errorf("pin failed: %w", err)
+	}
+
+	fmt.Printf("Pinned release '%s' of project '%s'\n", args[1], proj.Name)
+	return nil
+}
+
+func runReleasesUnpin(cmd *cobra.Command, args []string) error {
+	proj, err := loadReleasesProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The pins file is read by release cleanup in running deployments
+	lock, err := deployment.TryLockProject(proj.Path, "unpin")
+	if err != nil {
+		return fmt.Errorf("unpin failed: %w", err)
+	}
+	defer lock.Release()
+
+	executor := deployment.NewExecutor(proj.Path)
+	if err := executor.UnpinRelease(args[1]); err != nil {
+		return fmt.Errorf("unpin failed: %w", err)
+	}
+
+	fmt.Printf("Unpinned release '%s' of project '%s'\n", args[1], proj.Name)
+	return nil
+}
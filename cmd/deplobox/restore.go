@@ -1,32 +1,56 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"deplobox/internal/deployment"
+	"deplobox/internal/history"
 	"deplobox/internal/project"
 
 	"github.com/spf13/cobra"
 )
 
-const defaultConfigPath = "/etc/deplobox/projects.yaml"
+const (
+	defaultConfigPath = "/etc/deplobox/projects.yaml"
+
+	// systemdUnitPath is where deplobox install writes the server's unit
+	systemdUnitPath = "/etc/systemd/system/deplobox.service"
+)
 
 var (
-	restoreConfigFile string
+	restoreConfigFile      string
+	restoreDBPath          string
+	restoreTo              string
+	restoreSteps           int
+	restoreRunPostActivate bool
 )
 
 var restoreCmd = &cobra.Command{
 	Use:   "restore PROJECT_NAME",
-	Short: "Restore a project to its previous release",
-	Long: `Restore a project to its previous release by switching the current symlink.
+	Short: "Restore a project to an earlier release",
+	Long: `Restore a project to an earlier release by switching the current symlink.
 
 This command will:
 - Read the project configuration from projects.yaml
-- Find the previous release (by timestamp)
-- Atomically switch the current symlink to the previous release
-
-Example:
-  deplobox restore myapp`,
+- Take the project lock, failing if a deployment or restore is running
+- Find the release to restore: the previous one (by creation time), the one
+  --steps releases back, or the one named by --to (release name or commit SHA)
+- Atomically switch the current symlink to that release
+- Optionally run the project's post_activate commands in it
+- Record the restore in the server's deployment history with status
+  'restored' (--db, DEPLOBOX_DB_PATH or the path in the deplobox systemd unit)
+
+Examples:
+  deplobox restore myapp
+  deplobox restore myapp --steps 2
+  deplobox restore myapp --to 20251207T130803Z-1a2b3c4
+  deplobox restore myapp --to 1a2b3c4 --run-post-activate`,
 	Args: cobra.ExactArgs(1),
 	RunE: runRestore,
 }
@@ -34,6 +58,11 @@ Example:
 func init() {
 	// Config file flag
 	restoreCmd.Flags().StringVarP(&restoreConfigFile, "config", "c", defaultConfigPath, "Path to projects config file")
+	restoreCmd.Flags().StringVar(&restoreDBPath, "db", "", "Path to the server's SQLite database (default: DEPLOBOX_DB_PATH or the deplobox systemd unit's)")
+	restoreCmd.Flags().StringVar(&restoreTo, "to", "", "Release name or commit SHA to restore")
+	restoreCmd.Flags().IntVar(&restoreSteps, "steps", 1, "Number of releases to step back from current")
+	restoreCmd.Flags().BoolVar(&restoreRunPostActivate, "run-post-activate", false, "Run the project's post_activate commands after restoring")
+	restoreCmd.MarkFlagsMutuallyExclusive("to", "steps")
 }
 
 func runRestore(cmd *cobra.Command, args []string) error {
@@ -51,20 +80,132 @@ func runRestore(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("project '%s' not found in config file %s", projectName, restoreConfigFile)
 	}
 
-	// Create executor for the project
-	executor := deployment.NewExecutor(proj.Path)
+	// Open the history first, so every restore is recorded
+	dbPath, err := restoreHistoryPath()
+	if err != nil {
+		return err
+	}
+	hist, err := history.NewHistory(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open history database %s: %w", dbPath, err)
+	}
+	defer hist.Close()
+
+	// Take the project lock before choosing the release, so a deployment
+	// cannot switch current in between
+	lock, err := deployment.TryLockProject(proj.Path, "restore")
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	defer lock.Release()
 
-	// Restore to previous release
-	fmt.Printf("Restoring project '%s' to previous release...\n", projectName)
-	oldRelease, newRelease, err := executor.RestorePreviousRelease()
+	// Find the release to restore
+	ctx := context.Background()
+	executor := deployment.NewExecutor(proj.Path)
+	target, err := executor.FindRestoreTarget(ctx, restoreTo, restoreSteps, proj.PullTimeout)
 	if err != nil {
 		return fmt.Errorf("restore failed: %w", err)
 	}
 
+	fmt.Printf("Restoring project '%s' to release %s...\n", projectName, target.Name)
+	startTime := time.Now()
+	restore := deployment.NewRestore(proj, true, nil)
+	restore.Lock = lock
+	response, statusCode := restore.Restore(ctx, target, restoreRunPostActivate)
+
+	recordRestore(ctx, hist, proj, restore, response, time.Since(startTime).Seconds())
+
+	if output, _ := response["output"].(string); strings.TrimSpace(output) != "" {
+		fmt.Printf("\n%s\n", strings.TrimSpace(output))
+	}
+	if statusCode != http.StatusOK {
+		errMsg, _ := response["error"].(string)
+		return errors.New(errMsg)
+	}
+
+	previousRelease, _ := response["previous_release"].(string)
 	fmt.Printf("\nRestore successful!\n")
-	fmt.Printf("  Previous (current): %s\n", oldRelease)
-	fmt.Printf("  Restored to:        %s\n", newRelease)
-	fmt.Printf("\nThe 'current' symlink now points to: %s\n", newRelease)
+	fmt.Printf("  Previous (current): %s\n", previousRelease)
+	fmt.Printf("  Restored to:        %s\n", target.Name)
+	if manifest := target.Manifest; manifest != nil {
+		fmt.Printf("  Commit:             %s (%s)\n", manifest.Commit, manifest.Branch)
+		fmt.Printf("  Created:            %s\n", manifest.CreatedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("\nThe 'current' symlink now points to: %s\n", target.Name)
 
 	return nil
 }
+
+// restoreHistoryPath returns the history database the server records
+// deployments in: --db, DEPLOBOX_DB_PATH, or the DEPLOBOX_DB_PATH set in the
+// systemd unit written by deplobox install. The database must already exist,
+// so a restore is never recorded in a stray new one.
+func restoreHistoryPath() (string, error) {
+	path := restoreDBPath
+	if path == "" {
+		path = os.Getenv("DEPLOBOX_DB_PATH")
+	}
+	if path == "" {
+		path = systemdUnitEnv(systemdUnitPath, "DEPLOBOX_DB_PATH")
+	}
+	if path == "" {
+		return "", fmt.Errorf("no history database found: pass --db or set DEPLOBOX_DB_PATH to the server's database")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("history database %s not found: pass --db or set DEPLOBOX_DB_PATH to the server's database", path)
+	}
+	return path, nil
+}
+
+// systemdUnitEnv returns the value of an Environment= variable in a systemd
+// unit file, or "" if the file or variable is missing
+func systemdUnitEnv(unitPath, key string) string {
+	data, err := os.ReadFile(unitPath)
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "Environment=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		if v, ok := strings.CutPrefix(value, key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// recordRestore records a restore in the history: 'restored' once current was
+// switched (with the error if post_activate failed afterwards), 'failed' otherwise
+func recordRestore(ctx context.Context, hist *history.History, proj *project.Project, restore *deployment.Deployment, response map[string]interface{}, duration float64) {
+	status := "failed"
+	if restore.Restored {
+		status = "restored"
+	}
+
+	var errorMsg *string
+	if errStr, ok := response["error"].(string); ok {
+		errorMsg = &errStr
+	}
+	var commitHash *string
+	if restore.CommitHash != "" {
+		commitHash = &restore.CommitHash
+	}
+
+	if _, err := hist.RecordDeployment(ctx, &history.DeploymentRecord{
+		Project:         proj.Name,
+		Branch:          proj.Branch,
+		Ref:             restore.Push.Ref,
+		Status:          status,
+		DurationSeconds: &duration,
+		CommitHash:      commitHash,
+		ErrorMessage:    errorMsg,
+		Trigger:         restore.Push.TriggerName(),
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record restore in history: %v\n", err)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"deplobox/internal/history"
 	"deplobox/internal/project"
@@ -16,12 +18,13 @@ import (
 )
 
 var (
-	configFile string
-	logFile    string
-	dbPath     string
-	host       string
-	port       int
-	testMode   bool
+	configFile  string
+	logFile     string
+	dbPath      string
+	host        string
+	port        int
+	metricsAddr string
+	testMode    bool
 )
 
 var serveCmd = &cobra.Command{
@@ -40,6 +43,7 @@ func init() {
 	serveCmd.Flags().StringVar(&dbPath, "db", getEnvOrDefault("DEPLOBOX_DB_PATH", "./deployments.db"), "Path to SQLite database")
 	serveCmd.Flags().StringVar(&host, "host", getEnvOrDefault("DEPLOBOX_HOST", "127.0.0.1"), "Host to bind to")
 	serveCmd.Flags().IntVarP(&port, "port", "p", getEnvOrDefaultInt("DEPLOBOX_PORT", 5000), "Port to listen on")
+	serveCmd.Flags().StringVar(&metricsAddr, "metrics-addr", getEnvOrDefault("DEPLOBOX_METRICS_ADDR", ""), "Separate address for /metrics, e.g. 127.0.0.1:9100 (default: main listener, behind an API token)")
 	serveCmd.Flags().BoolVar(&testMode, "test-mode", os.Getenv("DEPLOBOX_SKIP_VALIDATION") == "1", "Enable test mode (skip validation)")
 }
 
@@ -70,7 +74,7 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	// Load configuration
 	logger.Info("Loading configuration", "config", configFile)
-	_, projects, err := project.LoadConfig(configFile)
+	config, projects, err := project.LoadConfig(configFile)
 	if err != nil {
 		logger.Error("Failed to load configuration", "error", err)
 		return fmt.Errorf("failed to load configuration: %w", err)
@@ -78,6 +82,13 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	logger.Info("Configuration validated successfully", "count", len(projects))
 
+	// Surface non-fatal configuration issues
+	for name, proj := range projects {
+		for _, warning := range proj.Warnings {
+			logger.Warn("Configuration warning", "project", name, "warning", strings.TrimSpace(warning))
+		}
+	}
+
 	// Warn if no projects are configured
 	if len(projects) == 0 {
 		logger.Warn("No projects configured in config file", "config", configFile)
@@ -101,6 +112,11 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	// Create and start server
 	srv := server.NewServer(registry, hist, logger, testMode)
+	srv.AdminTokens = config.AdminTokens
+	srv.MetricsAddr = metricsAddr
+
+	// Clean up after deployments interrupted by a crash or restart
+	srv.RecoverInterruptedDeployments(context.Background())
 
 	logger.Info("Starting HTTP server", "host", host, "port", port)
 	if err := srv.Start(host, port); err != nil {
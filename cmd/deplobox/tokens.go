@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+	"deplobox/internal/security"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokensDBPath   string
+	tokensScopes   []string
+	tokensProjects []string
+	tokensExpires  string
+)
+
+var tokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "Manage API tokens",
+	Long: `Create, list and revoke the API tokens that authenticate requests to the
+status, deployment log and /api endpoints.
+
+Tokens are stored as SHA-256 hashes in the history database. Tokens can also
+be listed under admin_tokens in the projects config file.
+
+Scopes:
+  read      status and deployment logs
+  deploy    manual deployments (POST /api/projects/{project}/deploy)
+  rollback  restores (POST /api/projects/{project}/restore)
+  admin     everything
+
+Every scope includes read.`,
+}
+
+var tokensCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create an API token",
+	Long: `Create an API token and print it. The token is shown only once.
+
+The name identifies the token in the history of deployments and restores it
+starts.
+
+Examples:
+  deplobox tokens create dashboard
+  deplobox tokens create ci --scope deploy --project myapp --expires 90d`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokensCreate,
+}
+
+var tokensListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens",
+	Long: `List API tokens with their scopes, projects, expiry and last use.
+
+Example:
+  deplobox tokens list`,
+	Args: cobra.NoArgs,
+	RunE: runTokensList,
+}
+
+var tokensRevokeCmd = &cobra.Command{
+	Use:   "revoke NAME",
+	Short: "Revoke an API token",
+	Long: `Revoke an API token. Requests with it are rejected from then on.
+
+Example:
+  deplobox tokens revoke ci`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokensRevoke,
+}
+
+func init() {
+	// Database flag, shared by all tokens subcommands
+	tokensCmd.PersistentFlags().StringVar(&tokensDBPath, "db", getEnvOrDefault("DEPLOBOX_DB_PATH", "./deployments.db"), "Path to SQLite database")
+
+	tokensCreateCmd.Flags().StringSliceVar(&tokensScopes, "scope", []string{security.ScopeRead}, "Scope to grant (read, deploy, rollback or admin); repeat or comma-separate for several")
+	tokensCreateCmd.Flags().StringSliceVar(&tokensProjects, "project", nil, "Project the token may act on; repeat for several (default: all)")
+	tokensCreateCmd.Flags().StringVar(&tokensExpires, "expires", "", "Lifetime such as 90d or 12h (default: never expires)")
+
+	tokensCmd.AddCommand(tokensCreateCmd)
+	tokensCmd.AddCommand(tokensListCmd)
+	tokensCmd.AddCommand(tokensRevokeCmd)
+}
+
+// openTokensHistory opens the history database holding the tokens
+func openTokensHistory() (*history.History, error) {
+	hist, err := history.NewHistory(tokensDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database %s: %w", tokensDBPath, err)
+	}
+	return hist, nil
+}
+
+func runTokensCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := security.ValidateProjectName(name); err != nil {
+		return fmt.Errorf("invalid token name '%s': use letters, digits, '-' and '_'", name)
+	}
+	for _, scope := range tokensScopes {
+		if !security.ValidScope(scope) {
+			return fmt.Errorf("unknown scope '%s' (must be one of: %s)", scope, strings.Join(security.Scopes, ", "))
+		}
+	}
+	for _, projectName := range tokensProjects {
+		if err := security.ValidateProjectName(projectName); err != nil {
+			return fmt.Errorf("invalid project name '%s': %w", projectName, err)
+		}
+	}
+
+	var expiresAt *time.Time
+	if tokensExpires != "" {
+		lifetime, err := project.ParseAge(tokensExpires)
+		if err != nil {
+			return fmt.Errorf("invalid --expires: %w", err)
+		}
+		t := time.Now().Add(lifetime)
+		expiresAt = &t
+	}
+
+	token, err := security.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	hist, err := openTokensHistory()
+	if err != nil {
+		return err
+	}
+	defer hist.Close()
+
+	if _, err := hist.CreateToken(context.Background(), &history.APIToken{
+		Name:      name,
+		TokenHash: security.HashToken(token),
+		Scopes:    tokensScopes,
+		Projects:  tokensProjects,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created token '%s' with scopes %s\n", name, strings.Join(tokensScopes, ", "))
+	fmt.Println("Store it now, it is not shown again:")
+	fmt.Println()
+	fmt.Println(token)
+	return nil
+}
+
+func runTokensList(cmd *cobra.Command, args []string) error {
+	hist, err := openTokensHistory()
+	if err != nil {
+		return err
+	}
+	defer hist.Close()
+
+	tokens, err := hist.ListTokens(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		fmt.Println("No tokens found")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSCOPES\tPROJECTS\tCREATED\tEXPIRES\tLAST USED\tSTATUS")
+	for _, token := range tokens {
+		projects := "all"
+		if len(token.Projects) > 0 {
+			projects = strings.Join(token.Projects, ",")
+		}
+
+		status := "active"
+		switch {
+		case token.RevokedAt != nil:
+			status = "revoked"
+		case !token.Active(now):
+			status = "expired"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			token.Name,
+			strings.Join(token.Scopes, ","),
+			projects,
+			token.CreatedAt.Local().Format("2006-01-02"),
+			formatOptionalDate(token.ExpiresAt, "never"),
+			formatOptionalDate(token.LastUsedAt, "never"),
+			status,
+		)
+	}
+	return w.Flush()
+}
+
+func runTokensRevoke(cmd *cobra.Command, args []string) error {
+	hist, err := openTokensHistory()
+	if err != nil {
+		return err
+	}
+	defer hist.Close()
+
+	if err := hist.RevokeToken(context.Background(), args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked token '%s'\n", args[0])
+	return nil
+}
+
+// formatOptionalDate formats a nullable time for listings, or returns none
+func formatOptionalDate(t *time.Time, none string) string {
+	if t == nil {
+		return none
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
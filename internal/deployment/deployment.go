@@ -2,13 +2,21 @@ package deployment
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"deplobox/internal/project"
 	"deplobox/internal/security"
+	"deplobox/pkg/cmdutil"
+	"deplobox/pkg/fileutil"
 )
 
 const (
@@ -16,38 +24,90 @@ const (
 	DefaultSharedFilesTimeout = 30
 
 	// DefaultKeepReleases is the number of releases to keep after cleanup
-	DefaultKeepReleases = 5
+	DefaultKeepReleases = project.DefaultKeepReleases
 )
 
+// How a deployment was started, recorded in the history
+const (
+	TriggerWebhook = "webhook" // A push webhook (the default)
+	TriggerManual  = "manual"  // The manual deploy API
+	TriggerRestore = "restore" // A restore of an existing release
+)
+
+// ZeroCommit is the commit SHA providers send as the head of a deleted ref
+const ZeroCommit = "0000000000000000000000000000000000000000"
+
+// PushEvent is a provider-independent view of a webhook push.
+// Webhook providers extract it from their own payload formats.
+type PushEvent struct {
+	Ref     string // Full git ref, e.g. refs/heads/main
+	Commit  string // Head commit SHA after the push
+	Pusher  string // Username of whoever pushed, or the API token name for manual deployments
+	Trigger string // TriggerWebhook (if empty), TriggerManual or TriggerRestore
+}
+
+// DeletesRef reports whether the push deleted its ref, leaving nothing to deploy
+func (p *PushEvent) DeletesRef() bool {
+	return p.Commit == ZeroCommit
+}
+
+// TriggerName returns how the deployment was started, defaulting to TriggerWebhook
+func (p *PushEvent) TriggerName() string {
+	if p.Trigger == "" {
+		return TriggerWebhook
+	}
+	return p.Trigger
+}
+
 // Deployment manages the execution of a deployment for a project
 type Deployment struct {
-	Project      *project.Project
-	Payload      map[string]interface{}
-	ExposeOutput bool
-	Outputs      []string
-	Executor     *Executor
-	Logger       *slog.Logger
+	ID              string // Unique identifier, exposed to hooks as DEPLOBOX_DEPLOYMENT_ID
+	Project         *project.Project
+	Push            *PushEvent
+	CommitHash      string           // SHA actually checked out in the new release
+	OnCommit        func(sha string) // Called with CommitHash once the new release is checked out, if set
+	ReleaseDir      string           // Directory of the new release, once created
+	Manifest        *ReleaseManifest // Manifest written into the new release
+	PreviousRelease string           // Release current pointed to when the deployment started
+	RolledBack      bool             // Current was switched back to the previous release after activation
+	Restored        bool             // Current was switched to an existing release by Restore
+	ExposeOutput    bool
+	LockTimeout     time.Duration // How long to wait for the project lock held by another process
+	LockWait        time.Duration // How long the deployment waited for the project lock
+	LockRejected    bool          // The project lock was still held by another process after LockTimeout
+	Lock            *ProjectLock  // Project lock already taken by the caller; Restore takes its own if nil
+	Outputs         []string
+	Executor        *Executor
+	Logger          *slog.Logger
+	projectEnv      []string // env_file and env entries, loaded when the deployment starts
 }
 
 // NewDeployment creates a new deployment instance
-func NewDeployment(proj *project.Project, payload map[string]interface{}, exposeOutput bool, logger *slog.Logger) *Deployment {
+func NewDeployment(proj *project.Project, push *PushEvent, exposeOutput bool, logger *slog.Logger) *Deployment {
+	executor := NewExecutor(proj.Path)
+	executor.ExcludeGitDir = proj.ExcludeGitDir
+	executor.Shell = proj.Shell
+	executor.CleanEnv = proj.CleanEnv
+	executor.Logger = logger
+
 	return &Deployment{
+		ID:           newDeploymentID(),
 		Project:      proj,
-		Payload:      payload,
+		Push:         push,
 		ExposeOutput: exposeOutput,
+		LockTimeout:  DefaultLockTimeout,
 		Outputs:      []string{},
-		Executor:     NewExecutor(proj.Path),
+		Executor:     executor,
 		Logger:       logger,
 	}
 }
 
-// ShouldDeploy checks if deployment should proceed based on payload
+// ShouldDeploy checks if deployment should proceed based on the pushed ref
 func (d *Deployment) ShouldDeploy() bool {
-	ref, ok := d.Payload["ref"].(string)
-	if !ok {
+	if d.Push == nil || d.Push.Ref == "" {
 		return false
 	}
-	return d.Project.MatchesRef(ref)
+	return d.Project.MatchesRef(d.Push.Ref)
 }
 
 // log logs a message if logger is available
@@ -105,11 +165,90 @@ func (d *Deployment) Execute(ctx context.Context) (map[string]interface{}, int)
 		return d.errorResponse(fmt.Sprintf("Invalid project name: %v", err), nil), http.StatusBadRequest
 	}
 
+	// Serialise with deployments and restores run by other processes
+	lock, response, statusCode := d.lockProject(ctx, "deploy")
+	if lock == nil {
+		return response, statusCode
+	}
+	defer lock.Release()
+
 	d.log(slog.LevelInfo, "starting deployment", "project", d.Project.Name, "branch", d.Project.Branch)
 
-	// Step 1: Fresh clone into new release directory
-	d.log(slog.LevelInfo, "step 1: cloning repository", "project", d.Project.Name, "branch", d.Project.Branch)
-	releaseDir, createResult, err := d.Executor.CreateRelease(ctx, d.Project.Branch, d.Project.PullTimeout)
+	// on_success / on_failure always run once the deployment has started
+	response, statusCode = d.run(ctx)
+	d.runOutcomeHooks(ctx, response, statusCode)
+
+	// Garbage-collect the half-built release after on_failure had a chance to inspect it
+	if statusCode != http.StatusOK {
+		d.cleanupFailedReleases()
+	}
+
+	return response, statusCode
+}
+
+// lockProject takes the cross-process project lock, waiting up to LockTimeout.
+// On failure it returns a nil lock and the error response.
+func (d *Deployment) lockProject(ctx context.Context, operation string) (*ProjectLock, map[string]interface{}, int) {
+	start := time.Now()
+	lock, err := LockProject(ctx, d.Project.Path, operation, d.LockTimeout)
+	d.LockWait = time.Since(start)
+	if err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			d.LockRejected = true
+			d.log(slog.LevelWarn, "project locked by another process", "project", d.Project.Name, "error", err)
+			response := d.errorResponse(fmt.Sprintf("Deployment in progress: %v", err), nil)
+			if locked.Holder != nil {
+				response["lock_holder"] = locked.Holder
+			}
+			return nil, response, http.StatusConflict
+		}
+		d.log(slog.LevelError, "failed to lock project", "project", d.Project.Name, "error", err)
+		return nil, d.errorResponse(fmt.Sprintf("Failed to lock project: %v", err), nil), http.StatusInternalServerError
+	}
+
+	if lock.Stale != nil {
+		d.log(slog.LevelWarn, "took over stale project lock", "project", d.Project.Name, "stale_pid", lock.Stale.PID,
+			"stale_operation", lock.Stale.Operation, "stale_since", lock.Stale.StartedAt)
+	}
+	return lock, nil, 0
+}
+
+// run executes the deployment steps after validation
+func (d *Deployment) run(ctx context.Context) (map[string]interface{}, int) {
+	// Load the project environment and expose the deployment context to hooks
+	if err := d.loadProjectEnv(); err != nil {
+		d.log(slog.LevelError, "failed to load hook environment", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("Failed to load hook environment: %v", err), nil), http.StatusInternalServerError
+	}
+	if currentPath, err := fileutil.ResolveSymlink(filepath.Join(d.Project.Path, "current")); err == nil {
+		d.PreviousRelease = currentPath
+	}
+	d.updateHookEnv()
+
+	// Step 0: Execute pre-deploy commands in the current release if present
+	if len(d.Project.PreDeploy) > 0 {
+		d.log(slog.LevelInfo, "step 0: running pre-deploy commands", "project", d.Project.Name, "command_count", len(d.Project.PreDeploy))
+		preResults, err := d.Executor.RunPreDeployCommands(ctx, d.Project.PreDeploy, d.Project.PreDeployTimeout)
+
+		// Collect and log all outputs
+		for i, result := range preResults {
+			d.Outputs = append(d.Outputs, result.Stdout, result.Stderr)
+			d.logOutput(fmt.Sprintf("pre_deploy[%d]", i), result)
+		}
+
+		if err != nil {
+			d.log(slog.LevelError, "pre-deploy command failed", "project", d.Project.Name, "error", err)
+			return d.errorResponse(fmt.Sprintf("Pre-deploy command failed: %v", err), nil), http.StatusInternalServerError
+		}
+		d.log(slog.LevelInfo, "pre-deploy commands completed", "project", d.Project.Name, "commands_run", len(preResults))
+	}
+
+	// Step 1: Fetch into the repository cache and build the release at the pushed commit
+	d.log(slog.LevelInfo, "step 1: creating release", "project", d.Project.Name, "branch", d.Project.Branch, "commit", d.Push.Commit)
+	d.Executor.startStep("git_clone", "git fetch && git clone --branch "+d.Project.Branch)
+	releaseDir, commitHash, createResult, err := d.Executor.CreateRelease(ctx, d.Project.Branch, d.Push.Commit, d.Project.PullTimeout)
+	d.Executor.finishStep(createResult, resultOutput(createResult), err)
 	if err != nil {
 		if createResult != nil {
 			d.Outputs = append(d.Outputs, createResult.Stdout, createResult.Stderr)
@@ -120,7 +259,28 @@ func (d *Deployment) Execute(ctx context.Context) (map[string]interface{}, int)
 	}
 	d.Outputs = append(d.Outputs, createResult.Stdout, createResult.Stderr)
 	d.logOutput("git_clone", createResult)
-	d.log(slog.LevelInfo, "repository cloned", "project", d.Project.Name, "release_dir", releaseDir)
+
+	d.CommitHash = commitHash
+	d.ReleaseDir = releaseDir
+	d.updateHookEnv()
+	d.log(slog.LevelInfo, "repository cloned", "project", d.Project.Name, "release_dir", releaseDir, "commit", commitHash)
+	if d.OnCommit != nil {
+		d.OnCommit(commitHash)
+	}
+
+	// Record what the release contains for restore, cleanup and status
+	d.Manifest = &ReleaseManifest{
+		Release:      filepath.Base(releaseDir),
+		Commit:       commitHash,
+		Branch:       d.Project.Branch,
+		Pusher:       d.Push.Pusher,
+		DeploymentID: d.ID,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := WriteReleaseManifest(releaseDir, d.Manifest); err != nil {
+		d.log(slog.LevelError, "failed to write release manifest", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("Failed to write release manifest: %v", err), nil), http.StatusInternalServerError
+	}
 
 	// Check for cancellation before copying shared files
 	select {
@@ -130,24 +290,43 @@ func (d *Deployment) Execute(ctx context.Context) (map[string]interface{}, int)
 	default:
 	}
 
-	// Step 2: Copy shared files to release
-	d.log(slog.LevelInfo, "step 2: copying shared files", "project", d.Project.Name)
-	sharedResult, err := d.Executor.CopySharedFiles(ctx, releaseDir, DefaultSharedFilesTimeout)
-	if err != nil || !sharedResult.OK() {
-		d.Outputs = append(d.Outputs, sharedResult.Stdout, sharedResult.Stderr)
-		d.logOutput("copy_shared", sharedResult)
-		errMsg := "Failed to copy shared files"
-		if err != nil {
-			errMsg = fmt.Sprintf("%s: %v", errMsg, err)
+	// Step 2: Copy shared files to release (rsync), if enabled
+	if d.Project.CopyFiles {
+		d.log(slog.LevelInfo, "step 2: copying shared files", "project", d.Project.Name)
+		d.Executor.startStep("copy_shared", "rsync -a shared/ "+filepath.Base(releaseDir)+"/")
+		sharedResult, err := d.Executor.CopySharedFiles(ctx, releaseDir, DefaultSharedFilesTimeout)
+		d.Executor.finishStep(sharedResult, resultOutput(sharedResult), err)
+		if err != nil || !sharedResult.OK() {
+			if sharedResult != nil {
+				d.Outputs = append(d.Outputs, sharedResult.Stdout, sharedResult.Stderr)
+				d.logOutput("copy_shared", sharedResult)
+			}
+			errMsg := "Failed to copy shared files"
+			if err != nil {
+				errMsg = fmt.Sprintf("%s: %v", errMsg, err)
+			}
+			d.log(slog.LevelError, "failed to copy shared files", "project", d.Project.Name, "error", err)
+			return d.errorResponse(errMsg, sharedResult), http.StatusInternalServerError
 		}
-		d.log(slog.LevelError, "failed to copy shared files", "project", d.Project.Name, "error", err)
-		return d.errorResponse(errMsg, sharedResult), http.StatusInternalServerError
+		if sharedResult.Stdout != "" || sharedResult.Stderr != "" {
+			d.Outputs = append(d.Outputs, sharedResult.Stdout, sharedResult.Stderr)
+			d.logOutput("copy_shared", sharedResult)
+		}
+		d.log(slog.LevelInfo, "shared files copied", "project", d.Project.Name)
 	}
-	if sharedResult.Stdout != "" || sharedResult.Stderr != "" {
-		d.Outputs = append(d.Outputs, sharedResult.Stdout, sharedResult.Stderr)
-		d.logOutput("copy_shared", sharedResult)
+
+	// Link shared files and directories into the release
+	if len(d.Project.LinkedFiles) > 0 || len(d.Project.LinkedDirs) > 0 {
+		d.log(slog.LevelInfo, "step 2: linking shared paths", "project", d.Project.Name, "linked_files", len(d.Project.LinkedFiles), "linked_dirs", len(d.Project.LinkedDirs))
+		d.Executor.startStep("link_shared", "link shared paths")
+		err := d.Executor.LinkSharedPaths(releaseDir, d.Project.LinkedFiles, d.Project.LinkedDirs)
+		d.Executor.finishStep(nil, "", err)
+		if err != nil {
+			d.log(slog.LevelError, "failed to link shared paths", "project", d.Project.Name, "error", err)
+			return d.errorResponse(fmt.Sprintf("Failed to link shared paths: %v", err), nil), http.StatusInternalServerError
+		}
+		d.log(slog.LevelInfo, "shared paths linked", "project", d.Project.Name)
 	}
-	d.log(slog.LevelInfo, "shared files copied", "project", d.Project.Name)
 
 	// Step 3: Execute post-deploy commands if present
 	if len(d.Project.PostDeploy) > 0 {
@@ -169,6 +348,12 @@ func (d *Deployment) Execute(ctx context.Context) (map[string]interface{}, int)
 		d.log(slog.LevelInfo, "step 3: no post-deploy commands configured", "project", d.Project.Name)
 	}
 
+	// The release is fully built; from here on it is a regular release
+	if err := d.Executor.MarkReleaseComplete(releaseDir); err != nil {
+		d.log(slog.LevelError, "failed to mark release complete", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("Failed to mark release complete: %v", err), nil), http.StatusInternalServerError
+	}
+
 	// Step 4: Update current symlink (atomic cutover)
 	d.log(slog.LevelInfo, "step 4: updating current symlink", "project", d.Project.Name, "release_dir", releaseDir)
 	if err := d.Executor.UpdateCurrentSymlink(releaseDir); err != nil {
@@ -177,6 +362,14 @@ func (d *Deployment) Execute(ctx context.Context) (map[string]interface{}, int)
 	}
 	d.log(slog.LevelInfo, "current symlink updated", "project", d.Project.Name)
 
+	activatedAt := time.Now().UTC()
+	d.Manifest.ActivatedAt = &activatedAt
+	if err := WriteReleaseManifest(releaseDir, d.Manifest); err != nil {
+		// The release is live, so only warn
+		d.log(slog.LevelWarn, "failed to update release manifest", "project", d.Project.Name, "error", err)
+		d.Outputs = append(d.Outputs, fmt.Sprintf("Warning: failed to update release manifest: %v", err))
+	}
+
 	// Step 5: Execute post-activate commands if present
 	// These run after the deployment is activated (current symlink updated)
 	if len(d.Project.PostActivate) > 0 {
@@ -191,28 +384,241 @@ func (d *Deployment) Execute(ctx context.Context) (map[string]interface{}, int)
 
 		if err != nil {
 			d.log(slog.LevelError, "post-activate command failed", "project", d.Project.Name, "error", err)
-			return d.errorResponse(fmt.Sprintf("Post-activate command failed: %v", err), nil), http.StatusInternalServerError
+			errMsg := fmt.Sprintf("Post-activate command failed: %v", err)
+			if d.Project.RollbackOnFailure {
+				return d.rollback(ctx, errMsg), http.StatusInternalServerError
+			}
+			return d.errorResponse(errMsg, nil), http.StatusInternalServerError
 		}
 		d.log(slog.LevelInfo, "post-activate commands completed", "project", d.Project.Name, "commands_run", len(postActivateResults))
 	} else {
 		d.log(slog.LevelInfo, "step 5: no post-activate commands configured", "project", d.Project.Name)
 	}
 
-	// Step 6: Cleanup old releases
-	d.log(slog.LevelInfo, "step 6: cleaning up old releases", "project", d.Project.Name, "keep_releases", DefaultKeepReleases)
-	if err := d.Executor.CleanupOldReleases(DefaultKeepReleases); err != nil {
+	// Step 6: Health check the activated release, rolling back if it does not come up
+	if d.Project.HealthCheck != nil {
+		d.log(slog.LevelInfo, "step 6: running health check", "project", d.Project.Name, "retries", d.Project.HealthCheck.Retries)
+		d.Executor.startStep("healthcheck", healthCheckLabel(d.Project.HealthCheck))
+		healthResults, err := d.Executor.RunHealthCheck(ctx, d.Project.HealthCheck)
+		var healthOutput strings.Builder
+		for _, result := range healthResults {
+			// One line per attempt, as URL checks print no newline
+			output := resultOutput(result)
+			healthOutput.WriteString(output)
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				healthOutput.WriteString("\n")
+			}
+		}
+		var lastHealth *ExecutionResult
+		if len(healthResults) > 0 {
+			lastHealth = healthResults[len(healthResults)-1]
+		}
+		d.Executor.finishStep(lastHealth, healthOutput.String(), err)
+
+		for i, result := range healthResults {
+			d.Outputs = append(d.Outputs, result.Stdout, result.Stderr)
+			d.logOutput(fmt.Sprintf("healthcheck[%d]", i), result)
+		}
+
+		if err != nil {
+			d.log(slog.LevelError, "health check failed", "project", d.Project.Name, "error", err)
+			return d.rollback(ctx, fmt.Sprintf("Health check failed: %v", err)), http.StatusInternalServerError
+		}
+		d.log(slog.LevelInfo, "health check passed", "project", d.Project.Name, "attempts", len(healthResults))
+	} else {
+		d.log(slog.LevelInfo, "step 6: no health check configured", "project", d.Project.Name)
+	}
+
+	// Step 7: Cleanup old releases
+	policy := d.retentionPolicy()
+	d.log(slog.LevelInfo, "step 7: cleaning up old releases", "project", d.Project.Name, "keep_releases", policy.KeepReleases, "max_release_age", policy.MaxAge, "max_releases_size", policy.MaxSize)
+	removed, err := d.Executor.CleanupReleases(policy)
+	if err != nil {
 		// Log warning but don't fail
 		d.log(slog.LevelWarn, "cleanup failed", "project", d.Project.Name, "error", err)
 		d.Outputs = append(d.Outputs, fmt.Sprintf("Warning: cleanup failed: %v", err))
 	} else {
-		d.log(slog.LevelInfo, "cleanup completed", "project", d.Project.Name)
+		d.log(slog.LevelInfo, "cleanup completed", "project", d.Project.Name, "removed", removed)
 	}
+	d.cleanupFailedReleases()
 
 	// Success
 	d.log(slog.LevelInfo, "deployment completed successfully", "project", d.Project.Name)
 	return d.successResponse(), http.StatusOK
 }
 
+// resultOutput returns the combined output of a command result, if any:
+// stdout followed by stderr, where e.g. health check errors end up. Commands
+// run with combined output already carry it in both fields.
+func resultOutput(result *ExecutionResult) string {
+	if result == nil {
+		return ""
+	}
+	if result.Stderr == result.Stdout {
+		return result.Stdout
+	}
+	if result.Stdout == "" || result.Stderr == "" || strings.HasSuffix(result.Stdout, "\n") {
+		return result.Stdout + result.Stderr
+	}
+	return result.Stdout + "\n" + result.Stderr
+}
+
+// loadProjectEnv reads the project's env_file and env settings.
+// env_file is read on every deployment so edits apply without a restart.
+func (d *Deployment) loadProjectEnv() error {
+	var env []string
+
+	if d.Project.EnvFile != "" {
+		fileEnv, err := cmdutil.ParseEnvFile(d.Project.EnvFile)
+		if err != nil {
+			return fmt.Errorf("env_file %s: %w", d.Project.EnvFile, err)
+		}
+		env = append(env, fileEnv...)
+	}
+
+	// env entries override env_file
+	keys := make([]string, 0, len(d.Project.Env))
+	for key := range d.Project.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+d.Project.Env[key])
+	}
+
+	d.projectEnv = env
+	return nil
+}
+
+// updateHookEnv sets the executor's hook environment from the project env
+// and the current deployment state
+func (d *Deployment) updateHookEnv() {
+	commit := d.CommitHash
+	if commit == "" {
+		commit = d.Push.Commit
+	}
+
+	d.Executor.HookEnv = append(append([]string{}, d.projectEnv...),
+		"DEPLOBOX_PROJECT="+d.Project.Name,
+		"DEPLOBOX_RELEASE_DIR="+d.ReleaseDir,
+		"DEPLOBOX_PREVIOUS_RELEASE="+d.PreviousRelease,
+		"DEPLOBOX_COMMIT="+commit,
+		"DEPLOBOX_BRANCH="+d.Project.Branch,
+		"DEPLOBOX_DEPLOYMENT_ID="+d.ID,
+		"DEPLOBOX_PUSHER="+d.Push.Pusher,
+		"DEPLOBOX_TRIGGER="+d.Push.TriggerName(),
+	)
+}
+
+// newDeploymentID returns a unique deployment identifier: UTC timestamp plus random suffix
+func newDeploymentID() string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000Z")
+	}
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// runOutcomeHooks runs on_success or on_failure with the outcome in DEPLOBOX_STATUS
+// (success, failed or rolled_back) and DEPLOBOX_ERROR. Hook failures are logged
+// but do not change the deployment result.
+func (d *Deployment) runOutcomeHooks(ctx context.Context, response map[string]interface{}, statusCode int) {
+	stage, commands, status := "on_success", d.Project.OnSuccess, "success"
+	if statusCode != http.StatusOK {
+		stage, commands, status = "on_failure", d.Project.OnFailure, "failed"
+		if d.RolledBack {
+			status = "rolled_back"
+		}
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	errorMsg, _ := response["error"].(string)
+	env := []string{
+		"DEPLOBOX_STATUS=" + status,
+		"DEPLOBOX_ERROR=" + errorMsg,
+	}
+
+	// Run even if the deployment itself was cancelled
+	d.log(slog.LevelInfo, "running outcome hooks", "project", d.Project.Name, "hook", stage, "status", status)
+	results, err := d.Executor.RunOutcomeCommands(context.WithoutCancel(ctx), stage, commands, d.Project.PostActivateTimeout, env)
+	for i, result := range results {
+		d.Outputs = append(d.Outputs, result.Stdout, result.Stderr)
+		d.logOutput(fmt.Sprintf("%s[%d]", stage, i), result)
+	}
+	if err != nil {
+		d.log(slog.LevelWarn, "outcome hook failed", "project", d.Project.Name, "hook", stage, "error", err)
+		d.Outputs = append(d.Outputs, fmt.Sprintf("Warning: %s hook failed: %v", stage, err))
+	}
+
+	// Include hook output in the response
+	if d.ExposeOutput {
+		response["output"] = strings.Join(d.Outputs, "\n")
+	}
+}
+
+// retentionPolicy returns the project's release retention policy
+func (d *Deployment) retentionPolicy() RetentionPolicy {
+	keep := d.Project.KeepReleases
+	if keep == 0 {
+		keep = DefaultKeepReleases
+	}
+	return RetentionPolicy{
+		KeepReleases: keep,
+		MaxAge:       d.Project.MaxReleaseAge,
+		MaxSize:      d.Project.MaxReleasesSize,
+	}
+}
+
+// cleanupFailedReleases removes releases whose build never completed, keeping
+// the last one if keep_failed is set. Failures are logged but never fail the deployment.
+func (d *Deployment) cleanupFailedReleases() {
+	removed, err := d.Executor.CleanupFailedReleases(d.Project.KeepFailed)
+	if err != nil {
+		d.log(slog.LevelWarn, "failed release cleanup failed", "project", d.Project.Name, "error", err)
+		d.Outputs = append(d.Outputs, fmt.Sprintf("Warning: failed release cleanup failed: %v", err))
+		return
+	}
+	if len(removed) > 0 {
+		d.log(slog.LevelInfo, "removed failed releases", "project", d.Project.Name, "removed", removed)
+	}
+}
+
+// rollback switches current back to the previous release after a failed activation,
+// runs the on_rollback commands, and builds the error response describing both
+// the failure and the rollback
+func (d *Deployment) rollback(ctx context.Context, errorMsg string) map[string]interface{} {
+	d.log(slog.LevelWarn, "rolling back to previous release", "project", d.Project.Name)
+
+	failedRelease, restoredRelease, err := d.Executor.RestorePreviousRelease(d.PreviousRelease)
+	if err != nil {
+		d.log(slog.LevelError, "rollback failed", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("%s; rollback failed: %v", errorMsg, err), nil)
+	}
+
+	d.RolledBack = true
+	d.log(slog.LevelInfo, "rolled back to previous release", "project", d.Project.Name, "failed_release", failedRelease, "restored_release", restoredRelease)
+
+	// Reload the restored release; on_rollback runs in current/ like post_activate
+	if len(d.Project.OnRollback) > 0 {
+		rollbackResults, err := d.Executor.RunOnRollbackCommands(ctx, d.Project.OnRollback, d.Project.PostActivateTimeout)
+		for i, result := range rollbackResults {
+			d.Outputs = append(d.Outputs, result.Stdout, result.Stderr)
+			d.logOutput(fmt.Sprintf("on_rollback[%d]", i), result)
+		}
+		if err != nil {
+			d.log(slog.LevelError, "on_rollback command failed", "project", d.Project.Name, "error", err)
+			errorMsg = fmt.Sprintf("%s; on_rollback command failed: %v", errorMsg, err)
+		}
+	}
+
+	response := d.errorResponse(fmt.Sprintf("%s; rolled back to %s", errorMsg, restoredRelease), nil)
+	response["rolled_back"] = true
+	response["restored_release"] = restoredRelease
+	return response
+}
+
 // errorResponse builds an error response
 func (d *Deployment) errorResponse(errorMsg string, result *ExecutionResult) map[string]interface{} {
 	response := map[string]interface{}{
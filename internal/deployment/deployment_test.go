@@ -17,39 +17,39 @@ func TestDeployment_ShouldDeploy(t *testing.T) {
 
 	testCases := []struct {
 		name     string
-		payload  map[string]interface{}
+		push     *PushEvent
 		expected bool
 	}{
 		{
 			name:     "matching branch",
-			payload:  map[string]interface{}{"ref": "refs/heads/main"},
+			push:     &PushEvent{Ref: "refs/heads/main"},
 			expected: true,
 		},
 		{
 			name:     "non-matching branch",
-			payload:  map[string]interface{}{"ref": "refs/heads/develop"},
+			push:     &PushEvent{Ref: "refs/heads/develop"},
 			expected: false,
 		},
 		{
 			name:     "tag ref",
-			payload:  map[string]interface{}{"ref": "refs/tags/v1.0"},
+			push:     &PushEvent{Ref: "refs/tags/v1.0"},
 			expected: false,
 		},
 		{
 			name:     "missing ref",
-			payload:  map[string]interface{}{},
+			push:     &PushEvent{},
 			expected: false,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			deploy := NewDeployment(testProject, tc.payload, false, nil)
+			deploy := NewDeployment(testProject, tc.push, false, nil)
 			result := deploy.ShouldDeploy()
 
 			if result != tc.expected {
-				t.Errorf("ShouldDeploy() = %v, expected %v for payload %v",
-					result, tc.expected, tc.payload)
+				t.Errorf("ShouldDeploy() = %v, expected %v for push %+v",
+					result, tc.expected, tc.push)
 			}
 		})
 	}
@@ -66,11 +66,11 @@ func TestDeployment_Execute_SkipNonTargetBranch(t *testing.T) {
 		Branch: "main",
 	}
 
-	payload := map[string]interface{}{
-		"ref": "refs/heads/develop", // Not main
+	push := &PushEvent{
+		Ref: "refs/heads/develop", // Not main
 	}
 
-	deploy := NewDeployment(testProject, payload, false, nil)
+	deploy := NewDeployment(testProject, push, false, nil)
 	response, statusCode := deploy.Execute(context.Background())
 
 	if statusCode != 200 {
@@ -94,12 +94,12 @@ func TestDeployment_Execute_OutputVisibility(t *testing.T) {
 		PostDeploy: []interface{}{"echo test"},
 	}
 
-	payload := map[string]interface{}{
-		"ref": "refs/heads/develop",
+	push := &PushEvent{
+		Ref: "refs/heads/develop",
 	}
 
 	// Test with output hidden (default)
-	deployHidden := NewDeployment(testProject, payload, false, nil)
+	deployHidden := NewDeployment(testProject, push, false, nil)
 	responseHidden, _ := deployHidden.Execute(context.Background())
 
 	if _, hasOutput := responseHidden["output"]; hasOutput {
@@ -107,10 +107,30 @@ func TestDeployment_Execute_OutputVisibility(t *testing.T) {
 	}
 
 	// Test with output exposed
-	deployExposed := NewDeployment(testProject, payload, true, nil)
+	deployExposed := NewDeployment(testProject, push, true, nil)
 	responseExposed, _ := deployExposed.Execute(context.Background())
 
 	if _, hasOutput := responseExposed["output"]; !hasOutput {
 		t.Error("Expected output to be present when ExposeOutput=true")
 	}
 }
+
+func TestResultOutput(t *testing.T) {
+	tests := []struct {
+		result *ExecutionResult
+		want   string
+	}{
+		{nil, ""},
+		{&ExecutionResult{Stdout: "built\n"}, "built\n"},
+		{&ExecutionResult{Stderr: "connection refused"}, "connection refused"},
+		{&ExecutionResult{Stdout: "built\n", Stderr: "warning\n"}, "built\nwarning\n"},
+		{&ExecutionResult{Stdout: "built", Stderr: "warning"}, "built\nwarning"},
+		{&ExecutionResult{Stdout: "combined\n", Stderr: "combined\n"}, "combined\n"},
+	}
+
+	for _, tt := range tests {
+		if got := resultOutput(tt.result); got != tt.want {
+			t.Errorf("resultOutput(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package deployment
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,6 +20,7 @@ type ExecutionResult struct {
 	Stdout     string
 	Stderr     string
 	Duration   time.Duration
+	Attempts   int // Times a configured command ran, counting retries
 }
 
 // OK checks if the execution was successful
@@ -28,8 +30,15 @@ func (r *ExecutionResult) OK() bool {
 
 // Executor handles command execution with timeouts
 type Executor struct {
-	ProjectRoot string // Root of project (contains shared/, releases/, current)
-	executor    *security.SandboxedExecutor
+	ProjectRoot   string       // Root of project (contains shared/, releases/, repo/, current)
+	ExcludeGitDir bool         // Build releases with git archive instead of a clone (no .git)
+	Shell         bool         // Run string hook commands through /bin/sh -c
+	CleanEnv      bool         // Start hook commands from a clean environment instead of the server's
+	HookEnv       []string     // Project env and DEPLOBOX_* variables added for hook commands
+	Observer      StepObserver // Told about each step as it runs, if set
+	Logger        *slog.Logger
+	step          *StepResult // Step being run, while an observer is set
+	executor      *security.SandboxedExecutor
 }
 
 // NewExecutor creates a new executor
@@ -42,12 +51,33 @@ func NewExecutor(projectRoot string) *Executor {
 
 // RunCommand executes a command with a timeout in a specific directory
 func (e *Executor) RunCommand(ctx context.Context, command []string, timeout int, workingDir string) (*ExecutionResult, error) {
+	return e.runCommand(ctx, command, timeout, workingDir, nil)
+}
+
+// RunHookCommand executes a hook command with the hook environment: the server's
+// environment (or a clean one if CleanEnv is set), then HookEnv, then extraEnv
+func (e *Executor) RunHookCommand(ctx context.Context, command []string, timeout int, workingDir string, extraEnv []string) (*ExecutionResult, error) {
+	var env []string
+	if e.CleanEnv {
+		env = []string{"PATH=" + cmdutil.DefaultPath}
+	} else {
+		env = os.Environ()
+	}
+	env = append(env, e.HookEnv...)
+	env = append(env, extraEnv...)
+
+	return e.runCommand(ctx, command, timeout, workingDir, env)
+}
+
+// runCommand executes a command; a nil env inherits the server's environment
+func (e *Executor) runCommand(ctx context.Context, command []string, timeout int, workingDir string, env []string) (*ExecutionResult, error) {
 	// Use pkg/cmdutil for command execution
 	result, err := cmdutil.Run(
 		ctx,
 		cmdutil.ExecOptions{
 			Dir:            workingDir,
 			Timeout:        time.Duration(timeout) * time.Second,
+			Env:            env,
 			CombinedOutput: true,
 		},
 		command,
@@ -104,54 +134,67 @@ func ParseCommand(cmd interface{}) ([]string, error) {
 	return cmdutil.ParseCommandList(cmd)
 }
 
-// CreateRelease creates a new timestamped release directory via fresh git clone
-func (e *Executor) CreateRelease(ctx context.Context, branch string, timeout int) (string, *ExecutionResult, error) {
+// CreateRelease creates a new release directory from the repository cache, named
+// after the UTC time and the short commit SHA (e.g. 20251207T130803Z-1a2b3c4).
+//
+// The cache is fetched first, then the release is built at commit (which must be
+// reachable from the branch) or at the branch tip if commit is empty. Releases get
+// a local clone with .git unless ExcludeGitDir is set, in which case the tree is
+// exported with git archive.
+//
+// The release is marked incomplete until MarkReleaseComplete is called; a failed
+// build is left for CleanupFailedReleases.
+//
+// Returns the release directory and the full SHA of the commit it contains.
+func (e *Executor) CreateRelease(ctx context.Context, branch, commit string, timeout int) (string, string, *ExecutionResult, error) {
 	// Validate branch name
 	if err := security.ValidateBranchName(branch); err != nil {
-		return "", nil, fmt.Errorf("invalid branch name: %w", err)
+		return "", "", nil, fmt.Errorf("invalid branch name: %w", err)
 	}
 
-	releasesDir := filepath.Join(e.ProjectRoot, "releases")
+	// Validate commit hash
+	if commit != "" {
+		if err := security.ValidateCommitSHA(commit); err != nil {
+			return "", "", nil, fmt.Errorf("invalid commit hash: %w", err)
+		}
+	}
 
-	// Generate timestamp for new release
-	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	releaseDir := filepath.Join(releasesDir, timestamp)
+	created := time.Now()
 
-	// Check if current exists to get the remote URL
-	currentLink := filepath.Join(e.ProjectRoot, "current")
-	if !fileutil.SymlinkExists(currentLink) {
-		return "", nil, fmt.Errorf("no current release found - initial deployment must be done via installer")
+	// Fetch the branch into the repository cache
+	remoteURL, fetchResult, err := e.SyncRepoCache(ctx, branch, timeout)
+	if err != nil {
+		return "", "", fetchResult, err
 	}
 
-	// Get the actual path that current points to
-	currentPath, err := fileutil.ResolveSymlink(currentLink)
+	// Resolve the commit to deploy, checking it belongs to the branch
+	sha, resolveResult, err := e.resolveCommit(ctx, branch, commit, timeout)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to resolve current symlink: %w", err)
+		return "", "", resolveResult, err
 	}
 
-	// Validate paths to prevent path traversal
-	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, currentPath); err != nil {
-		return "", nil, fmt.Errorf("current symlink points outside project root: %w", err)
+	// Name the release after the UTC time and commit, and keep it out of
+	// ListReleases until the deployment marks it complete
+	releaseDir, err := e.reserveRelease(created, sha)
+	if err != nil {
+		return "", "", nil, err
 	}
 
-	// Get the git remote URL from the current release
-	remoteResult, err := e.RunCommand(ctx, []string{"git", "remote", "get-url", "origin"}, timeout, currentPath)
-	if err != nil || !remoteResult.OK() {
-		return "", remoteResult, fmt.Errorf("failed to get git remote URL: %w", err)
+	var result *ExecutionResult
+	if e.ExcludeGitDir {
+		result, err = e.exportRelease(ctx, sha, releaseDir, timeout)
+	} else {
+		result, err = e.cloneRelease(ctx, branch, sha, remoteURL, releaseDir, timeout)
 	}
-	remoteURL := strings.TrimSpace(remoteResult.Stdout)
-	if remoteURL == "" {
-		return "", nil, fmt.Errorf("git remote URL is empty")
+	if err != nil {
+		return "", "", result, err
 	}
 
-	// Fresh clone into new release directory
-	cloneCmd := []string{"git", "clone", "--branch", branch, "--single-branch", remoteURL, releaseDir}
-	result, err := e.RunCommand(ctx, cloneCmd, timeout, releasesDir)
-	if err != nil || !result.OK() {
-		return "", result, fmt.Errorf("failed to clone repository: %w", err)
-	}
+	// Report the fetch output alongside the release output
+	result.Stdout = fetchResult.Stdout + result.Stdout
+	result.Stderr = fetchResult.Stderr + result.Stderr
 
-	return releaseDir, result, nil
+	return releaseDir, sha, result, nil
 }
 
 // CopySharedFiles copies files from shared directory to release
@@ -184,71 +227,199 @@ func (e *Executor) CopySharedFiles(ctx context.Context, releaseDir string, timeo
 	return e.RunCommand(ctx, cmd, timeout, e.ProjectRoot)
 }
 
-// RunPostDeployCommands executes all post-deploy commands sequentially in the release directory
-func (e *Executor) RunPostDeployCommands(ctx context.Context, releaseDir string, commands []interface{}, timeout int) ([]*ExecutionResult, error) {
-	results := make([]*ExecutionResult, 0, len(commands))
+// LinkSharedPaths symlinks release paths to their counterparts in shared/.
+//
+// Linked directories are created in shared/ if missing; linked files must already
+// exist there. Anything the release already has at a linked path (e.g. a committed
+// storage/ placeholder) is replaced by the symlink.
+func (e *Executor) LinkSharedPaths(releaseDir string, files, dirs []string) error {
+	sharedDir := filepath.Join(e.ProjectRoot, "shared")
 
-	// Validate release directory path
+	// Validate paths to prevent path traversal
+	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, sharedDir); err != nil {
+		return fmt.Errorf("shared directory outside project root: %w", err)
+	}
 	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, releaseDir); err != nil {
-		return results, fmt.Errorf("release directory outside project root: %w", err)
+		return fmt.Errorf("release directory outside project root: %w", err)
 	}
 
-	for i, cmdInterface := range commands {
-		// Parse command using pkg/cmdutil
-		cmd, err := ParseCommand(cmdInterface)
-		if err != nil {
-			return results, fmt.Errorf("failed to parse post_deploy command %d: %w", i, err)
+	for _, dir := range dirs {
+		// Only create missing directories; existing ones keep their permissions
+		if sharedPath := filepath.Join(sharedDir, dir); !fileutil.DirExists(sharedPath) {
+			if err := security.CreateSecureDir(sharedPath, security.PermDirectory); err != nil {
+				return fmt.Errorf("failed to create shared directory '%s': %w", dir, err)
+			}
 		}
+		if err := e.linkSharedPath(sharedDir, releaseDir, dir); err != nil {
+			return err
+		}
+	}
 
-		// Run command in release directory
-		// Using RunCommand (not RunCommandSecure) to allow all post-deploy commands
-		// Security validation happens at config load time
-		result, err := e.RunCommand(ctx, cmd, timeout, releaseDir)
-		results = append(results, result)
-
-		if err != nil {
-			return results, fmt.Errorf("post_deploy command %d failed: %w (command: %s)",
-				i, err, cmdutil.FormatCommand(cmd))
+	for _, file := range files {
+		if !fileutil.FileExists(filepath.Join(sharedDir, file)) {
+			return fmt.Errorf("linked file '%s' does not exist in shared directory", file)
 		}
+		if err := e.linkSharedPath(sharedDir, releaseDir, file); err != nil {
+			return err
+		}
+	}
 
-		if !result.OK() {
-			return results, fmt.Errorf("post_deploy command %d exited with code %d (command: %s)",
-				i, result.ReturnCode, cmdutil.FormatCommand(cmd))
+	return nil
+}
+
+// linkSharedPath replaces releaseDir/path with a relative symlink to sharedDir/path
+func (e *Executor) linkSharedPath(sharedDir, releaseDir, path string) error {
+	sharedPath := filepath.Join(sharedDir, path)
+	releasePath := filepath.Join(releaseDir, path)
+
+	// The shared target must stay inside shared/
+	if _, err := security.SanitizePathForSymlink(sharedDir, sharedPath); err != nil {
+		return fmt.Errorf("linked path '%s' outside shared directory: %w", path, err)
+	}
+
+	// The link must be created inside the release
+	parentDir := filepath.Dir(releasePath)
+	if !fileutil.DirExists(parentDir) {
+		if err := security.CreateSecureDir(parentDir, security.PermDirectory); err != nil {
+			return fmt.Errorf("failed to create parent directory for '%s': %w", path, err)
 		}
 	}
+	if _, err := security.SanitizePathForSymlink(releaseDir, parentDir); err != nil {
+		return fmt.Errorf("linked path '%s' outside release directory: %w", path, err)
+	}
 
-	return results, nil
+	if err := os.RemoveAll(releasePath); err != nil {
+		return fmt.Errorf("failed to remove '%s' from release: %w", path, err)
+	}
+
+	relTarget, err := filepath.Rel(parentDir, sharedPath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate relative path for '%s': %w", path, err)
+	}
+	if err := os.Symlink(relTarget, releasePath); err != nil {
+		return fmt.Errorf("failed to link '%s': %w", path, err)
+	}
+
+	// Verify the link resolves into shared/
+	if _, err := security.SanitizePathForSymlink(sharedDir, releasePath); err != nil {
+		return fmt.Errorf("linked path '%s' resolves outside shared directory: %w", path, err)
+	}
+
+	return nil
+}
+
+// RunPostDeployCommands executes all post-deploy commands sequentially in the release directory
+func (e *Executor) RunPostDeployCommands(ctx context.Context, releaseDir string, commands []interface{}, timeout int) ([]*ExecutionResult, error) {
+	// Validate release directory path
+	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, releaseDir); err != nil {
+		return []*ExecutionResult{}, fmt.Errorf("release directory outside project root: %w", err)
+	}
+
+	return e.runCommands(ctx, "post_deploy", commands, timeout, releaseDir, nil)
 }
 
 // RunPostActivateCommands executes all post-activate commands sequentially in the current directory
 // These commands run after the deployment has been activated (current symlink updated)
 func (e *Executor) RunPostActivateCommands(ctx context.Context, commands []interface{}, timeout int) ([]*ExecutionResult, error) {
-	results := make([]*ExecutionResult, 0, len(commands))
-
 	// Run commands in the current directory (which now points to the new release)
-	currentDir := filepath.Join(e.ProjectRoot, "current")
+	return e.runCommands(ctx, "post_activate", commands, timeout, filepath.Join(e.ProjectRoot, "current"), nil)
+}
+
+// RunOnRollbackCommands executes all on_rollback commands sequentially in the current directory
+// These commands run after current has been switched back to the previous release
+func (e *Executor) RunOnRollbackCommands(ctx context.Context, commands []interface{}, timeout int) ([]*ExecutionResult, error) {
+	return e.runCommands(ctx, "on_rollback", commands, timeout, filepath.Join(e.ProjectRoot, "current"), nil)
+}
+
+// RunPreDeployCommands executes all pre-deploy commands sequentially in the current directory,
+// or the project root on the first deployment, when there is no current release yet.
+// These commands run before the new release is created (e.g. maintenance mode, backups)
+func (e *Executor) RunPreDeployCommands(ctx context.Context, commands []interface{}, timeout int) ([]*ExecutionResult, error) {
+	return e.runCommands(ctx, "pre_deploy", commands, timeout, e.currentOrRoot(), nil)
+}
+
+// RunOutcomeCommands executes on_success or on_failure commands sequentially in the current directory
+// (the project root if a first deployment failed before activating). The stage selects the label used in errors; env carries the deployment outcome.
+func (e *Executor) RunOutcomeCommands(ctx context.Context, stage string, commands []interface{}, timeout int, env []string) ([]*ExecutionResult, error) {
+	return e.runCommands(ctx, stage, commands, timeout, e.currentOrRoot(), env)
+}
+
+// currentOrRoot returns the current directory for hooks that run outside the
+// new release, or the project root before the first release is activated
+func (e *Executor) currentOrRoot() string {
+	current := filepath.Join(e.ProjectRoot, "current")
+	if !fileutil.DirExists(current) {
+		return e.ProjectRoot
+	}
+	return current
+}
+
+// runCommands executes configured commands sequentially in workingDir, stopping at the first failure.
+// The stage name (e.g. "post_deploy") is used in error messages; env is added to the environment.
+// Per-command options (timeout, env, workdir, retries, allow_failure) override the defaults.
+// Results are one per command run, in config order: the last attempt of retried commands.
+func (e *Executor) runCommands(ctx context.Context, stage string, commands []interface{}, timeout int, workingDir string, env []string) ([]*ExecutionResult, error) {
+	results := make([]*ExecutionResult, 0, len(commands))
 
 	for i, cmdInterface := range commands {
 		// Parse command using pkg/cmdutil
-		cmd, err := ParseCommand(cmdInterface)
+		spec, err := cmdutil.ParseCommandSpecWithShell(cmdInterface, e.Shell)
 		if err != nil {
-			return results, fmt.Errorf("failed to parse post_activate command %d: %w", i, err)
+			return results, fmt.Errorf("failed to parse %s command %d: %w", stage, i, err)
+		}
+
+		// Shell execution is opt-in; leave an audit trail of every script run
+		if spec.Shell && e.Logger != nil {
+			e.Logger.Info("running command through shell", "audit", true, "project_root", e.ProjectRoot, "stage", stage, "index", i, "shell", cmdutil.ShellPath, "script", spec.Script)
+		}
+
+		cmdTimeout := timeout
+		if spec.Timeout > 0 {
+			cmdTimeout = spec.Timeout
 		}
 
-		// Run command in current directory
-		// Using RunCommand (not RunCommandSecure) to allow all post-activate commands
+		cmdDir := workingDir
+		if spec.Workdir != "" {
+			cmdDir = filepath.Join(workingDir, spec.Workdir)
+		}
+
+		cmdEnv := append(append([]string{}, env...), spec.Env...)
+
+		// Using RunHookCommand (not RunCommandSecure) to allow all configured commands
 		// Security validation happens at config load time
-		result, err := e.RunCommand(ctx, cmd, timeout, currentDir)
+		e.startStep(fmt.Sprintf("%s[%d]", stage, i), spec.Label())
+		var result *ExecutionResult
+		var output strings.Builder
+		for attempt := 1; attempt <= spec.Retries+1; attempt++ {
+			result, err = e.RunHookCommand(ctx, spec.Args, cmdTimeout, cmdDir, cmdEnv)
+			result.Attempts = attempt
+			output.WriteString(result.Stdout)
+			if err == nil && result.OK() {
+				break
+			}
+			if attempt <= spec.Retries && e.Logger != nil {
+				e.Logger.Warn("command failed, retrying", "project_root", e.ProjectRoot, "stage", stage, "index", i, "attempt", attempt, "exit_code", result.ReturnCode, "output", strings.TrimSpace(result.Stdout))
+			}
+		}
 		results = append(results, result)
+		if err == nil && !result.OK() {
+			e.finishStep(result, output.String(), fmt.Errorf("exited with code %d", result.ReturnCode))
+		} else {
+			e.finishStep(result, output.String(), err)
+		}
+
+		if spec.AllowFailure {
+			continue
+		}
 
 		if err != nil {
-			return results, fmt.Errorf("post_activate command %d failed: %w (command: %s)",
-				i, err, cmdutil.FormatCommand(cmd))
+			return results, fmt.Errorf("%s command %d failed: %w (command: %s)",
+				stage, i, err, spec.Label())
 		}
 
 		if !result.OK() {
-			return results, fmt.Errorf("post_activate command %d exited with code %d (command: %s)",
-				i, result.ReturnCode, cmdutil.FormatCommand(cmd))
+			return results, fmt.Errorf("%s command %d exited with code %d (command: %s)",
+				stage, i, result.ReturnCode, spec.Label())
 		}
 	}
 
@@ -278,145 +449,29 @@ func (e *Executor) UpdateCurrentSymlink(releaseDir string) error {
 	return nil
 }
 
-// CleanupOldReleases removes old releases, keeping the specified number of most recent
-func (e *Executor) CleanupOldReleases(keepCount int) error {
-	releasesDir := filepath.Join(e.ProjectRoot, "releases")
-
-	entries, err := os.ReadDir(releasesDir)
-	if err != nil {
-		return fmt.Errorf("failed to read releases directory: %w", err)
+// RestorePreviousRelease switches the current symlink back to previousRelease,
+// the release that was live before a deployment. This is not necessarily the
+// release created before the new one: that may be an earlier rolled-back build,
+// or current may have been restored to an older release.
+// Returns the names of the release that was current and the restored release.
+func (e *Executor) RestorePreviousRelease(previousRelease string) (string, string, error) {
+	if previousRelease == "" {
+		return "", "", fmt.Errorf("cannot restore: no release was live before this deployment")
 	}
 
-	// Filter only directories
-	var releases []os.DirEntry
-	for _, entry := range entries {
-		if entry.IsDir() {
-			releases = append(releases, entry)
-		}
-	}
-
-	// Keep only if we have more than keepCount releases
-	if len(releases) <= keepCount {
-		return nil
-	}
-
-	// Sort by name (timestamp) in descending order
-	// Since names are timestamps in YYYY-MM-DD-HH-MM-SS format, alphabetical sort works
-	for i := 0; i < len(releases)-1; i++ {
-		for j := i + 1; j < len(releases); j++ {
-			if releases[i].Name() < releases[j].Name() {
-				releases[i], releases[j] = releases[j], releases[i]
-			}
-		}
-	}
-
-	// Remove old releases
-	for i := keepCount; i < len(releases); i++ {
-		releasePath := filepath.Join(releasesDir, releases[i].Name())
-
-		// Validate path before deletion
-		if _, err := security.SanitizePathForSymlink(e.ProjectRoot, releasePath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: skipping deletion of release outside project root: %s\n", releases[i].Name())
-			continue
-		}
-
-		if err := os.RemoveAll(releasePath); err != nil {
-			// Log error but continue
-			fmt.Fprintf(os.Stderr, "Warning: failed to remove old release %s: %v\n", releases[i].Name(), err)
-		}
-	}
-
-	return nil
-}
-
-// RestorePreviousRelease switches the current symlink to the previous release
-func (e *Executor) RestorePreviousRelease() (string, string, error) {
-	currentLink := filepath.Join(e.ProjectRoot, "current")
-	releasesDir := filepath.Join(e.ProjectRoot, "releases")
-
-	// Check if current symlink exists
-	if !fileutil.SymlinkExists(currentLink) {
-		return "", "", fmt.Errorf("no current release found (current symlink missing)")
-	}
-
-	// Get the current release path
-	currentPath, err := fileutil.ResolveSymlink(currentLink)
+	target := Release{Name: filepath.Base(previousRelease), Path: previousRelease}
+	current, err := e.CurrentRelease()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to resolve current symlink: %w", err)
+		return "", "", err
 	}
-
-	// Validate current path
-	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, currentPath); err != nil {
-		return "", "", fmt.Errorf("current symlink points outside project root: %w", err)
+	if current.Name == target.Name {
+		return "", "", fmt.Errorf("cannot restore: release '%s' is already current", target.Name)
 	}
 
-	// Get current release name (basename)
-	currentReleaseName := filepath.Base(currentPath)
-
-	// Read all releases
-	entries, err := os.ReadDir(releasesDir)
+	failed, err := e.RestoreRelease(target)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read releases directory: %w", err)
-	}
-
-	// Filter only directories and collect release names
-	var releaseNames []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			releaseNames = append(releaseNames, entry.Name())
-		}
-	}
-
-	// Need at least 2 releases to restore
-	if len(releaseNames) < 2 {
-		return "", "", fmt.Errorf("cannot restore: only one release exists (need at least 2 releases)")
-	}
-
-	// Sort releases by name (timestamp) in descending order (newest first)
-	for i := 0; i < len(releaseNames)-1; i++ {
-		for j := i + 1; j < len(releaseNames); j++ {
-			if releaseNames[i] < releaseNames[j] {
-				releaseNames[i], releaseNames[j] = releaseNames[j], releaseNames[i]
-			}
-		}
-	}
-
-	// Find the current release in the sorted list
-	currentIndex := -1
-	for i, name := range releaseNames {
-		if name == currentReleaseName {
-			currentIndex = i
-			break
-		}
-	}
-
-	if currentIndex == -1 {
-		return "", "", fmt.Errorf("current release '%s' not found in releases directory", currentReleaseName)
-	}
-
-	// Check if there's a previous release
-	if currentIndex >= len(releaseNames)-1 {
-		return "", "", fmt.Errorf("cannot restore: current release '%s' is already the oldest", currentReleaseName)
-	}
-
-	// Get the previous release (next in the sorted list)
-	previousReleaseName := releaseNames[currentIndex+1]
-	previousReleasePath := filepath.Join(releasesDir, previousReleaseName)
-
-	// Validate previous release path exists
-	if !fileutil.DirExists(previousReleasePath) {
-		return "", "", fmt.Errorf("previous release directory does not exist: %s", previousReleasePath)
-	}
-
-	// Validate previous release path
-	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, previousReleasePath); err != nil {
-		return "", "", fmt.Errorf("previous release directory outside project root: %w", err)
-	}
-
-	// Update the current symlink to point to previous release
-	if err := e.UpdateCurrentSymlink(previousReleasePath); err != nil {
-		return "", "", fmt.Errorf("failed to update current symlink: %w", err)
+		return "", "", err
 	}
 
-	return currentReleaseName, previousReleaseName, nil
+	return failed, target.Name, nil
 }
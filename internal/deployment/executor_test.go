@@ -3,6 +3,8 @@ package deployment
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -173,39 +175,30 @@ func TestExecutionResult_OK(t *testing.T) {
 	}
 }
 
-func TestExecutor_RestorePreviousRelease_Success(t *testing.T) {
+// setupReleases creates releases (newest first) with current pointing at live
+func setupReleases(t *testing.T, releases []string, live string) string {
 	tmpDir := t.TempDir()
-
-	// Create releases directory
-	releasesDir := tmpDir + "/releases"
-	if err := os.MkdirAll(releasesDir, 0755); err != nil {
-		t.Fatalf("Failed to create releases dir: %v", err)
+	for _, release := range releases {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "releases", release), 0755); err != nil {
+			t.Fatalf("Failed to create release dir %s: %v", release, err)
+		}
 	}
+	if err := os.Symlink("releases/"+live, filepath.Join(tmpDir, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+	return tmpDir
+}
 
-	// Create multiple releases with timestamp names (newest to oldest)
+func TestExecutor_RestorePreviousRelease_Success(t *testing.T) {
 	releases := []string{
 		"2024-12-09-15-30-00", // newest
 		"2024-12-09-14-00-00",
 		"2024-12-09-12-00-00", // oldest
 	}
+	tmpDir := setupReleases(t, releases, releases[0])
 
-	for _, release := range releases {
-		releaseDir := releasesDir + "/" + release
-		if err := os.MkdirAll(releaseDir, 0755); err != nil {
-			t.Fatalf("Failed to create release dir %s: %v", release, err)
-		}
-	}
-
-	// Create current symlink pointing to the newest release
-	currentLink := tmpDir + "/current"
-	if err := os.Symlink("releases/"+releases[0], currentLink); err != nil {
-		t.Fatalf("Failed to create current symlink: %v", err)
-	}
-
-	// Create executor and restore
 	executor := NewExecutor(tmpDir)
-	oldRelease, newRelease, err := executor.RestorePreviousRelease()
-
+	oldRelease, newRelease, err := executor.RestorePreviousRelease(filepath.Join(tmpDir, "releases", releases[1]))
 	if err != nil {
 		t.Fatalf("RestorePreviousRelease error: %v", err)
 	}
@@ -214,102 +207,76 @@ func TestExecutor_RestorePreviousRelease_Success(t *testing.T) {
 	if oldRelease != releases[0] {
 		t.Errorf("Expected old release %s, got %s", releases[0], oldRelease)
 	}
-
 	if newRelease != releases[1] {
 		t.Errorf("Expected new release %s, got %s", releases[1], newRelease)
 	}
 
 	// Verify current symlink points to previous release
-	linkTarget, err := os.Readlink(currentLink)
+	linkTarget, err := os.Readlink(filepath.Join(tmpDir, "current"))
 	if err != nil {
 		t.Fatalf("Failed to read current symlink: %v", err)
 	}
-
-	expectedTarget := "releases/" + releases[1]
-	if linkTarget != expectedTarget {
+	if expectedTarget := "releases/" + releases[1]; linkTarget != expectedTarget {
 		t.Errorf("Expected symlink target %s, got %s", expectedTarget, linkTarget)
 	}
 }
 
-func TestExecutor_RestorePreviousRelease_OnlyOneRelease(t *testing.T) {
-	tmpDir := t.TempDir()
-
-	// Create releases directory with only one release
-	releasesDir := tmpDir + "/releases"
-	releaseDir := releasesDir + "/2024-12-09-15-00-00"
-	if err := os.MkdirAll(releaseDir, 0755); err != nil {
-		t.Fatalf("Failed to create release dir: %v", err)
-	}
-
-	// Create current symlink
-	currentLink := tmpDir + "/current"
-	if err := os.Symlink("releases/2024-12-09-15-00-00", currentLink); err != nil {
-		t.Fatalf("Failed to create current symlink: %v", err)
+func TestExecutor_RestorePreviousRelease_LiveReleaseNotNewestButOne(t *testing.T) {
+	// The middle release is a build that was rolled back earlier; the oldest
+	// one was live when the newest was deployed
+	releases := []string{
+		"2024-12-09-15-30-00", // newest, failed
+		"2024-12-09-14-00-00", // rolled back earlier
+		"2024-12-09-12-00-00", // live before the deployment
 	}
+	tmpDir := setupReleases(t, releases, releases[0])
 
-	// Attempt to restore
 	executor := NewExecutor(tmpDir)
-	_, _, err := executor.RestorePreviousRelease()
-
-	// Should fail because there's only one release
-	if err == nil {
-		t.Error("Expected error when only one release exists")
+	_, newRelease, err := executor.RestorePreviousRelease(filepath.Join(tmpDir, "releases", releases[2]))
+	if err != nil {
+		t.Fatalf("RestorePreviousRelease error: %v", err)
 	}
-}
-
-func TestExecutor_RestorePreviousRelease_AlreadyOldest(t *testing.T) {
-	tmpDir := t.TempDir()
-
-	// Create releases directory
-	releasesDir := tmpDir + "/releases"
-	if err := os.MkdirAll(releasesDir, 0755); err != nil {
-		t.Fatalf("Failed to create releases dir: %v", err)
+	if newRelease != releases[2] {
+		t.Errorf("Expected the previously live release %s, got %s", releases[2], newRelease)
 	}
 
-	// Create multiple releases
-	releases := []string{
-		"2024-12-09-15-00-00", // newest
-		"2024-12-09-12-00-00", // oldest
+	linkTarget, err := os.Readlink(filepath.Join(tmpDir, "current"))
+	if err != nil {
+		t.Fatalf("Failed to read current symlink: %v", err)
 	}
-
-	for _, release := range releases {
-		releaseDir := releasesDir + "/" + release
-		if err := os.MkdirAll(releaseDir, 0755); err != nil {
-			t.Fatalf("Failed to create release dir %s: %v", release, err)
-		}
+	if expectedTarget := "releases/" + releases[2]; linkTarget != expectedTarget {
+		t.Errorf("Expected symlink target %s, got %s", expectedTarget, linkTarget)
 	}
+}
 
-	// Create current symlink pointing to the OLDEST release
-	currentLink := tmpDir + "/current"
-	if err := os.Symlink("releases/"+releases[1], currentLink); err != nil {
-		t.Fatalf("Failed to create current symlink: %v", err)
-	}
+func TestExecutor_RestorePreviousRelease_NoPreviousRelease(t *testing.T) {
+	// First deployment: nothing was live before it
+	tmpDir := setupReleases(t, []string{"2024-12-09-15-00-00"}, "2024-12-09-15-00-00")
 
-	// Attempt to restore
 	executor := NewExecutor(tmpDir)
-	_, _, err := executor.RestorePreviousRelease()
+	if _, _, err := executor.RestorePreviousRelease(""); err == nil {
+		t.Error("Expected error when no release was live before the deployment")
+	}
+}
 
-	// Should fail because current is already the oldest
-	if err == nil {
-		t.Error("Expected error when current release is already the oldest")
+func TestExecutor_RestorePreviousRelease_AlreadyCurrent(t *testing.T) {
+	releases := []string{"2024-12-09-15-00-00", "2024-12-09-12-00-00"}
+	tmpDir := setupReleases(t, releases, releases[1])
+
+	executor := NewExecutor(tmpDir)
+	if _, _, err := executor.RestorePreviousRelease(filepath.Join(tmpDir, "releases", releases[1])); err == nil {
+		t.Error("Expected error when the previous release is already current")
 	}
 }
 
 func TestExecutor_RestorePreviousRelease_NoCurrentSymlink(t *testing.T) {
 	tmpDir := t.TempDir()
-
-	// Create releases directory but no current symlink
-	releasesDir := tmpDir + "/releases"
-	if err := os.MkdirAll(releasesDir, 0755); err != nil {
-		t.Fatalf("Failed to create releases dir: %v", err)
+	if err := os.MkdirAll(filepath.Join(tmpDir, "releases", "2024-12-09-12-00-00"), 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
 	}
 
-	// Attempt to restore
 	executor := NewExecutor(tmpDir)
-	_, _, err := executor.RestorePreviousRelease()
-
-	// Should fail because current symlink doesn't exist
-	if err == nil {
+	if _, _, err := executor.RestorePreviousRelease(filepath.Join(tmpDir, "releases", "2024-12-09-12-00-00")); err == nil {
 		t.Error("Expected error when current symlink doesn't exist")
 	}
 }
@@ -398,3 +365,236 @@ func TestExecutor_RunPostActivateCommands_Failure(t *testing.T) {
 		t.Error("Expected error for failing command")
 	}
 }
+
+func TestExecutor_RunPostDeployCommands_StructuredOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	releaseDir := tmpDir + "/releases/2024-12-09-15-30-00"
+	if err := os.MkdirAll(releaseDir+"/backend", 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+	commands := []interface{}{
+		// Failure is tolerated
+		map[string]interface{}{"name": "restart queue", "command": "false", "allow_failure": true},
+		// Runs in workdir with extra env
+		map[string]interface{}{
+			"command": []interface{}{"sh", "-c", "echo $GREETING > greeting"},
+			"workdir": "backend",
+			"env":     map[string]interface{}{"GREETING": "hello"},
+		},
+	}
+
+	results, err := executor.RunPostDeployCommands(context.Background(), releaseDir, commands, 5)
+	if err != nil {
+		t.Fatalf("RunPostDeployCommands error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+
+	content, err := os.ReadFile(releaseDir + "/backend/greeting")
+	if err != nil {
+		t.Fatalf("Expected command to write into workdir: %v", err)
+	}
+	if string(content) != "hello\n" {
+		t.Errorf("Expected env to be passed, got %q", content)
+	}
+}
+
+func TestExecutor_RunPostDeployCommands_Retries(t *testing.T) {
+	tmpDir := t.TempDir()
+	releaseDir := tmpDir + "/releases/2024-12-09-15-30-00"
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+
+	// Fails on the first attempt, succeeds on the second
+	flaky := map[string]interface{}{
+		"name":    "flaky",
+		"command": []interface{}{"sh", "-c", "if [ -f attempted ]; then exit 0; fi; touch attempted; exit 1"},
+		"retries": 1,
+	}
+	results, err := executor.RunPostDeployCommands(context.Background(), releaseDir, []interface{}{flaky}, 5)
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got: %v", err)
+	}
+	if len(results) != 1 || results[0].Attempts != 2 || !results[0].OK() {
+		t.Errorf("Expected one successful result after 2 attempts, got %+v", results)
+	}
+
+	// Error messages use the command name
+	failing := map[string]interface{}{"name": "always fails", "command": "false", "retries": 2}
+	results, err = executor.RunPostDeployCommands(context.Background(), releaseDir, []interface{}{failing}, 5)
+	if err == nil {
+		t.Fatal("Expected command to fail after retries")
+	}
+	if len(results) != 1 || results[0].Attempts != 3 || results[0].OK() {
+		t.Errorf("Expected one failed result after 3 attempts, got %+v", results)
+	}
+	if !strings.Contains(err.Error(), "always fails") {
+		t.Errorf("Expected error to mention command name, got: %v", err)
+	}
+
+	// Results stay one per command, so their indexes match the config
+	results, err = executor.RunPostDeployCommands(context.Background(), releaseDir, []interface{}{
+		map[string]interface{}{"command": "false", "retries": 1, "allow_failure": true},
+		"true",
+	}, 5)
+	if err != nil {
+		t.Fatalf("Expected allowed failure to be ignored, got: %v", err)
+	}
+	if len(results) != 2 || results[0].Attempts != 2 || results[1].Attempts != 1 || !results[1].OK() {
+		t.Errorf("Expected one result per command, got %+v", results)
+	}
+}
+
+func TestExecutor_RunPostDeployCommands_Shell(t *testing.T) {
+	tmpDir := t.TempDir()
+	releaseDir := tmpDir + "/releases/2024-12-09-15-30-00"
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+
+	// Without a shell "||" is a literal argument to false, which still fails
+	if _, err := executor.RunPostDeployCommands(context.Background(), releaseDir, []interface{}{"false || true"}, 5); err == nil {
+		t.Error("Expected command without shell to fail")
+	}
+
+	// Per-command opt-in
+	shellCmd := map[string]interface{}{"command": "false || true", "shell": true}
+	if _, err := executor.RunPostDeployCommands(context.Background(), releaseDir, []interface{}{shellCmd}, 5); err != nil {
+		t.Errorf("Expected shell command to succeed, got: %v", err)
+	}
+
+	// Project-level opt-in
+	executor.Shell = true
+	if _, err := executor.RunPostDeployCommands(context.Background(), releaseDir, []interface{}{"false || true"}, 5); err != nil {
+		t.Errorf("Expected project-level shell command to succeed, got: %v", err)
+	}
+}
+
+func TestExecutor_RunHookCommand_Environment(t *testing.T) {
+	t.Setenv("DEPLOBOX_TEST_SERVER_VAR", "inherited")
+
+	executor := NewExecutor(t.TempDir())
+	executor.HookEnv = []string{"APP_ENV=production"}
+	printEnv := []string{"sh", "-c", "echo \"$DEPLOBOX_TEST_SERVER_VAR|$APP_ENV|$EXTRA\""}
+
+	result, err := executor.RunHookCommand(context.Background(), printEnv, 5, executor.ProjectRoot, []string{"EXTRA=1"})
+	if err != nil {
+		t.Fatalf("RunHookCommand error: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "inherited|production|1" {
+		t.Errorf("Expected inherited environment plus hook env, got %q", result.Stdout)
+	}
+
+	// Clean environment drops the server's variables but keeps a usable PATH
+	executor.CleanEnv = true
+	result, err = executor.RunHookCommand(context.Background(), printEnv, 5, executor.ProjectRoot, nil)
+	if err != nil {
+		t.Fatalf("RunHookCommand error: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "|production|" {
+		t.Errorf("Expected clean environment with hook env only, got %q", result.Stdout)
+	}
+}
+
+func TestExecutor_LinkSharedPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	sharedDir := tmpDir + "/shared"
+	releaseDir := tmpDir + "/releases/2024-12-09-15-30-00"
+
+	if err := os.MkdirAll(releaseDir+"/storage/logs", 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
+	}
+	if err := os.MkdirAll(sharedDir+"/config", 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+	if err := os.WriteFile(sharedDir+"/.env", []byte("APP_ENV=production\n"), 0600); err != nil {
+		t.Fatalf("Failed to create shared file: %v", err)
+	}
+	if err := os.WriteFile(sharedDir+"/config/database.yml", []byte("db\n"), 0600); err != nil {
+		t.Fatalf("Failed to create shared file: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+	err := executor.LinkSharedPaths(releaseDir, []string{".env", "config/database.yml"}, []string{"storage", "public/uploads"})
+	if err != nil {
+		t.Fatalf("LinkSharedPaths error: %v", err)
+	}
+
+	// Existing release directory is replaced by a link; missing shared dirs are created
+	for _, path := range []string{".env", "config/database.yml", "storage", "public/uploads"} {
+		info, err := os.Lstat(releaseDir + "/" + path)
+		if err != nil {
+			t.Fatalf("Expected %s in release: %v", path, err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("Expected %s to be a symlink", path)
+		}
+	}
+	if _, err := os.Stat(sharedDir + "/public/uploads"); err != nil {
+		t.Errorf("Expected missing shared directory to be created: %v", err)
+	}
+
+	// Writes through the release end up in shared/
+	if err := os.WriteFile(releaseDir+"/storage/app.log", []byte("log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write through link: %v", err)
+	}
+	if _, err := os.Stat(sharedDir + "/storage/app.log"); err != nil {
+		t.Errorf("Expected write to land in shared/: %v", err)
+	}
+
+	// Links are relative so the project root can move
+	target, err := os.Readlink(releaseDir + "/storage")
+	if err != nil {
+		t.Fatalf("Failed to read link: %v", err)
+	}
+	if target != "../../shared/storage" {
+		t.Errorf("Expected relative link target, got %s", target)
+	}
+}
+
+func TestExecutor_LinkSharedPaths_MissingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	releaseDir := tmpDir + "/releases/2024-12-09-15-30-00"
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
+	}
+	if err := os.MkdirAll(tmpDir+"/shared", 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+	err := executor.LinkSharedPaths(releaseDir, []string{".env"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not exist in shared directory") {
+		t.Errorf("Expected missing linked file error, got: %v", err)
+	}
+}
+
+func TestExecutor_LinkSharedPaths_RejectsEscape(t *testing.T) {
+	tmpDir := t.TempDir()
+	outside := t.TempDir()
+	releaseDir := tmpDir + "/releases/2024-12-09-15-30-00"
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		t.Fatalf("Failed to create release dir: %v", err)
+	}
+	if err := os.MkdirAll(tmpDir+"/shared", 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	// A symlink inside shared/ pointing outside must not be linked into the release
+	if err := os.Symlink(outside, tmpDir+"/shared/uploads"); err != nil {
+		t.Fatalf("Failed to create escaping symlink: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+	if err := executor.LinkSharedPaths(releaseDir, nil, []string{"uploads"}); err == nil {
+		t.Error("Expected linked path escaping shared/ to be rejected")
+	}
+}
@@ -0,0 +1,200 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"deplobox/internal/security"
+)
+
+const (
+	// ProjectLockFile is the per-project lock file in the project root, shared
+	// by the server and every CLI command that changes releases or current
+	ProjectLockFile = ".deplobox.lock"
+
+	// DefaultLockTimeout is how long a deployment waits for the project lock
+	DefaultLockTimeout = 2 * time.Minute
+
+	// lockPollInterval is how often LockProject retries a busy lock
+	lockPollInterval = 250 * time.Millisecond
+)
+
+// LockInfo describes the holder of a project lock
+type LockInfo struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	Operation string    `json:"operation"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// String describes the holder, e.g. "PID 1234 on web1 (deploy) since 2025-12-07T13:08:03Z"
+func (i *LockInfo) String() string {
+	return fmt.Sprintf("PID %d on %s (%s) since %s", i.PID, i.Hostname, i.Operation, i.StartedAt.Format(time.RFC3339))
+}
+
+// LockedError is returned when another process holds the project lock
+type LockedError struct {
+	Holder *LockInfo // nil if the holder has not written its details yet
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder == nil {
+		return "project is locked by another process"
+	}
+	return fmt.Sprintf("project is locked by PID %d (%s)", e.Holder.PID, e.Holder.Operation)
+}
+
+// ProjectLock is an exclusive flock on a project's lock file
+type ProjectLock struct {
+	Info  LockInfo
+	Stale *LockInfo // Details left by a previous holder that exited without releasing
+	file  *os.File
+}
+
+// TryLockProject takes the project lock without waiting.
+// Returns a *LockedError describing the holder if the project is locked.
+//
+// The lock is an flock, so the kernel releases it when the holder exits,
+// even if it crashes; the details it leaves behind are reported as Stale.
+func TryLockProject(projectRoot, operation string) (*ProjectLock, error) {
+	path := filepath.Join(projectRoot, ProjectLockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, security.PermConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := readLockInfo(file)
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &LockedError{Holder: holder}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	lock := &ProjectLock{file: file}
+	lock.Stale, _ = readLockInfo(file)
+
+	hostname, _ := os.Hostname()
+	lock.Info = LockInfo{
+		PID:       os.Getpid(),
+		Hostname:  hostname,
+		Operation: operation,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := lock.writeInfo(&lock.Info); err != nil {
+		lock.Release()
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// LockProject takes the project lock, waiting up to timeout for the current
+// holder to finish. A zero timeout does not wait.
+func LockProject(ctx context.Context, projectRoot, operation string, timeout time.Duration) (*ProjectLock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err := TryLockProject(projectRoot, operation)
+		var locked *LockedError
+		if !errors.As(err, &locked) || time.Now().Add(lockPollInterval).After(deadline) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Release clears the holder details and unlocks. The file is kept, since
+// removing it could let two processes lock different files.
+func (l *ProjectLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	truncErr := l.file.Truncate(0)
+	err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.file = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if truncErr != nil {
+		return fmt.Errorf("failed to clear lock file: %w", truncErr)
+	}
+	return nil
+}
+
+// writeInfo replaces the lock file contents with the holder details
+func (l *ProjectLock) writeInfo(info *LockInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock info: %w", err)
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := l.file.WriteAt(append(data, '\n'), 0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// ReadProjectLock returns the details of the current holder of the project
+// lock, or nil if the project is not locked. It only probes the lock and
+// never writes to the lock file.
+func ReadProjectLock(projectRoot string) (*LockInfo, error) {
+	file, err := os.Open(filepath.Join(projectRoot, ProjectLockFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer file.Close()
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err == nil {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		return nil, nil
+	}
+	if !errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, fmt.Errorf("failed to probe lock file: %w", err)
+	}
+
+	holder, err := readLockInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	if holder == nil {
+		holder = &LockInfo{} // Locked, but the holder has not written its details yet
+	}
+	return holder, nil
+}
+
+// readLockInfo reads holder details from an open lock file; nil if it is empty
+func readLockInfo(file *os.File) (*LockInfo, error) {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<16))
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	}
+	return &info, nil
+}
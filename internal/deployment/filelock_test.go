@@ -0,0 +1,137 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"deplobox/internal/project"
+)
+
+func TestTryLockProject(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	lock, err := TryLockProject(tmpDir, "deploy")
+	if err != nil {
+		t.Fatalf("TryLockProject error: %v", err)
+	}
+	if lock.Stale != nil {
+		t.Errorf("Expected no stale holder, got %v", lock.Stale)
+	}
+
+	// A second lock fails, naming the holder
+	_, err = TryLockProject(tmpDir, "restore")
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Expected LockedError, got %v", err)
+	}
+	if locked.Holder == nil || locked.Holder.PID != os.Getpid() || locked.Holder.Operation != "deploy" {
+		t.Errorf("Unexpected holder: %+v", locked.Holder)
+	}
+	if want := fmt.Sprintf("project is locked by PID %d (deploy)", os.Getpid()); err.Error() != want {
+		t.Errorf("Expected error %q, got %q", want, err.Error())
+	}
+
+	holder, err := ReadProjectLock(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadProjectLock error: %v", err)
+	}
+	if holder == nil || holder.Operation != "deploy" {
+		t.Errorf("Expected ReadProjectLock to report the deploy, got %+v", holder)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release error: %v", err)
+	}
+
+	// Released locks leave an empty file behind and are free again
+	if holder, err := ReadProjectLock(tmpDir); err != nil || holder != nil {
+		t.Errorf("Expected unlocked project, got %+v, %v", holder, err)
+	}
+	lock, err = TryLockProject(tmpDir, "restore")
+	if err != nil {
+		t.Fatalf("TryLockProject after release error: %v", err)
+	}
+	if lock.Stale != nil {
+		t.Errorf("Expected no stale holder after a clean release, got %v", lock.Stale)
+	}
+	lock.Release()
+}
+
+func TestTryLockProject_Stale(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Details left behind by a holder that crashed without releasing
+	stale := LockInfo{PID: 999999, Hostname: "web1", Operation: "deploy", StartedAt: time.Now().Add(-time.Hour).UTC()}
+	data, _ := json.Marshal(stale)
+	if err := os.WriteFile(filepath.Join(tmpDir, ProjectLockFile), data, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if holder, err := ReadProjectLock(tmpDir); err != nil || holder != nil {
+		t.Errorf("Expected stale lock file to be unlocked, got %+v, %v", holder, err)
+	}
+
+	lock, err := TryLockProject(tmpDir, "restore")
+	if err != nil {
+		t.Fatalf("TryLockProject error: %v", err)
+	}
+	defer lock.Release()
+
+	if lock.Stale == nil || lock.Stale.PID != stale.PID || lock.Stale.Operation != "deploy" {
+		t.Errorf("Expected stale holder %+v, got %+v", stale, lock.Stale)
+	}
+}
+
+func TestLockProject_Timeout(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	lock, err := TryLockProject(tmpDir, "deploy")
+	if err != nil {
+		t.Fatalf("TryLockProject error: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := LockProject(context.Background(), tmpDir, "deploy", 500*time.Millisecond); err == nil {
+		t.Fatal("Expected LockProject to time out")
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("Expected LockProject to wait, returned after %v", elapsed)
+	}
+
+	// Waiting succeeds once the holder releases
+	time.AfterFunc(300*time.Millisecond, func() { lock.Release() })
+	second, err := LockProject(context.Background(), tmpDir, "deploy", 5*time.Second)
+	if err != nil {
+		t.Fatalf("LockProject error: %v", err)
+	}
+	second.Release()
+}
+
+func TestDeployment_Execute_ProjectLocked(t *testing.T) {
+	tmpDir := t.TempDir()
+	proj := &project.Project{Name: "test-project", Path: tmpDir, Branch: "main"}
+
+	lock, err := TryLockProject(tmpDir, "restore")
+	if err != nil {
+		t.Fatalf("TryLockProject error: %v", err)
+	}
+	defer lock.Release()
+
+	deploy := NewDeployment(proj, &PushEvent{Ref: "refs/heads/main"}, false, nil)
+	deploy.LockTimeout = 0
+
+	response, statusCode := deploy.Execute(context.Background())
+	if statusCode != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d (%v)", http.StatusConflict, statusCode, response)
+	}
+	if holder, ok := response["lock_holder"].(*LockInfo); !ok || holder.Operation != "restore" {
+		t.Errorf("Expected lock_holder in response, got %v", response["lock_holder"])
+	}
+}
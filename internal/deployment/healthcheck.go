@@ -0,0 +1,105 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"deplobox/internal/project"
+	"deplobox/pkg/cmdutil"
+)
+
+// RunHealthCheck checks the activated release, retrying until an attempt succeeds
+// or retries are exhausted. Returns the result of every attempt.
+func (e *Executor) RunHealthCheck(ctx context.Context, hc *project.HealthCheck) ([]*ExecutionResult, error) {
+	attempts := hc.Retries + 1
+	results := make([]*ExecutionResult, 0, attempts)
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return results, fmt.Errorf("health check cancelled: %w", ctx.Err())
+			case <-time.After(time.Duration(hc.Interval) * time.Second):
+			}
+		}
+
+		var result *ExecutionResult
+		if hc.URL != "" {
+			result, lastErr = e.checkURL(ctx, hc)
+		} else {
+			result, lastErr = e.checkCommand(ctx, hc)
+		}
+		if result != nil {
+			results = append(results, result)
+		}
+
+		if lastErr == nil {
+			return results, nil
+		}
+	}
+
+	return results, fmt.Errorf("health check failed after %d attempts: %w", attempts, lastErr)
+}
+
+// healthCheckLabel describes a health check for step logs
+func healthCheckLabel(hc *project.HealthCheck) string {
+	if hc.URL != "" {
+		return "GET " + hc.URL
+	}
+	if cmd, err := ParseCommand(hc.Command); err == nil {
+		return cmdutil.FormatCommand(cmd)
+	}
+	return "health check command"
+}
+
+// checkURL performs a single HTTP health check attempt
+func (e *Executor) checkURL(ctx context.Context, hc *project.HealthCheck) (*ExecutionResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(hc.Timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create health check request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &ExecutionResult{ReturnCode: -1, Stderr: err.Error(), Duration: time.Since(start)}, fmt.Errorf("GET %s: %w", hc.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	output := fmt.Sprintf("GET %s: %s", hc.URL, resp.Status)
+	result := &ExecutionResult{Stdout: output, Duration: time.Since(start)}
+	if resp.StatusCode != hc.ExpectedStatus {
+		result.ReturnCode = 1
+		return result, fmt.Errorf("GET %s returned %d, expected %d", hc.URL, resp.StatusCode, hc.ExpectedStatus)
+	}
+
+	return result, nil
+}
+
+// checkCommand runs a single command health check attempt in the current directory
+func (e *Executor) checkCommand(ctx context.Context, hc *project.HealthCheck) (*ExecutionResult, error) {
+	cmd, err := ParseCommand(hc.Command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse healthcheck command: %w", err)
+	}
+
+	currentDir := filepath.Join(e.ProjectRoot, "current")
+	result, err := e.RunHookCommand(ctx, cmd, hc.Timeout, currentDir, nil)
+	if err != nil {
+		return result, fmt.Errorf("healthcheck command failed: %w (command: %s)", err, cmdutil.FormatCommand(cmd))
+	}
+	if !result.OK() {
+		return result, fmt.Errorf("healthcheck command exited with code %d (command: %s)", result.ReturnCode, cmdutil.FormatCommand(cmd))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package deployment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"deplobox/internal/project"
+)
+
+func TestExecutor_RunHealthCheck_URLSuccessAfterRetry(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// App needs two attempts to come up
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	executor := NewExecutor(t.TempDir())
+	hc := &project.HealthCheck{URL: srv.URL, ExpectedStatus: http.StatusOK, Retries: 3, Timeout: 5}
+
+	results, err := executor.RunHealthCheck(context.Background(), hc)
+	if err != nil {
+		t.Fatalf("RunHealthCheck error: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Errorf("Expected 3 attempts, got %d", len(results))
+	}
+}
+
+func TestExecutor_RunHealthCheck_URLExhaustsRetries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	executor := NewExecutor(t.TempDir())
+	hc := &project.HealthCheck{URL: srv.URL, ExpectedStatus: http.StatusOK, Retries: 2, Timeout: 5}
+
+	_, err := executor.RunHealthCheck(context.Background(), hc)
+	if err == nil {
+		t.Fatal("Expected health check to fail")
+	}
+
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests (1 + 2 retries), got %d", requests.Load())
+	}
+}
+
+func TestExecutor_RunHealthCheck_ExpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	executor := NewExecutor(t.TempDir())
+	hc := &project.HealthCheck{URL: srv.URL, ExpectedStatus: http.StatusNoContent, Timeout: 5}
+
+	if _, err := executor.RunHealthCheck(context.Background(), hc); err != nil {
+		t.Errorf("Expected 204 to satisfy expected_status 204, got: %v", err)
+	}
+}
+
+func TestExecutor_RunHealthCheck_Command(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(tmpDir+"/current", 0755); err != nil {
+		t.Fatalf("Failed to create current dir: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+
+	if _, err := executor.RunHealthCheck(context.Background(), &project.HealthCheck{Command: "true", Timeout: 5}); err != nil {
+		t.Errorf("Expected passing command health check, got: %v", err)
+	}
+
+	results, err := executor.RunHealthCheck(context.Background(), &project.HealthCheck{Command: []interface{}{"false"}, Retries: 1, Timeout: 5})
+	if err == nil {
+		t.Error("Expected failing command health check")
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", len(results))
+	}
+}
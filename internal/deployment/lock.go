@@ -10,6 +10,10 @@ import "sync"
 //
 // This design allows different projects to deploy concurrently while ensuring
 // that only one deployment can run for a given project at a time.
+//
+// The locks only cover this process. Deployments and restores also take the
+// project's file lock (see TryLockProject), which serialises them with the
+// CLI and other server instances.
 type LockManager struct {
 	mu    sync.Mutex            // Protects the locks map
 	locks map[string]*sync.Mutex // Per-project locks
@@ -0,0 +1,141 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"deplobox/internal/security"
+	"deplobox/pkg/fileutil"
+)
+
+const (
+	// ManifestFile describes a release and is written into its directory
+	ManifestFile = ".deplobox-release.json"
+
+	// releaseNameLayout is the UTC timestamp prefix of release directory names
+	releaseNameLayout = "20060102T150405Z"
+
+	// legacyReleaseNameLayout is the local-time prefix used by older releases
+	legacyReleaseNameLayout = "2006-01-02-15-04-05"
+
+	// shortSHALength is the length of the commit SHA suffix in release names
+	shortSHALength = 7
+)
+
+// ReleaseManifest records what a release contains and when it was deployed
+type ReleaseManifest struct {
+	Release      string     `json:"release"`
+	Commit       string     `json:"commit"`
+	Branch       string     `json:"branch"`
+	Pusher       string     `json:"pusher,omitempty"`
+	DeploymentID string     `json:"deployment_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ActivatedAt  *time.Time `json:"activated_at,omitempty"`
+}
+
+// releaseName returns the directory name for a release of the given commit,
+// e.g. 20251207T130803Z-1a2b3c4
+func releaseName(created time.Time, sha string) string {
+	if len(sha) > shortSHALength {
+		sha = sha[:shortSHALength]
+	}
+	return created.UTC().Format(releaseNameLayout) + "-" + sha
+}
+
+// parseReleaseName returns the creation time encoded in a release name
+func parseReleaseName(name string) (time.Time, bool) {
+	if len(name) >= len(releaseNameLayout) {
+		if t, err := time.Parse(releaseNameLayout, name[:len(releaseNameLayout)]); err == nil {
+			return t, true
+		}
+	}
+	if len(name) >= len(legacyReleaseNameLayout) {
+		if t, err := time.ParseInLocation(legacyReleaseNameLayout, name[:len(legacyReleaseNameLayout)], time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// WriteReleaseManifest writes the manifest into a release directory
+func WriteReleaseManifest(releaseDir string, manifest *ReleaseManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode release manifest: %w", err)
+	}
+
+	manifestPath := filepath.Join(releaseDir, ManifestFile)
+	tmpPath := manifestPath + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), security.PermPublicFile); err != nil {
+		return fmt.Errorf("failed to write release manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, manifestPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write release manifest: %w", err)
+	}
+
+	return nil
+}
+
+// ReadReleaseManifest reads the manifest of a release directory.
+// Releases created before manifests were introduced return an error
+// satisfying errors.Is(err, fs.ErrNotExist).
+func ReadReleaseManifest(releaseDir string) (*ReleaseManifest, error) {
+	data, err := os.ReadFile(filepath.Join(releaseDir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release manifest: %w", err)
+	}
+
+	var manifest ReleaseManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// markActivated records in a release's manifest that it just became current.
+// Releases without a manifest are left alone.
+func markActivated(releaseDir string) error {
+	manifest, err := ReadReleaseManifest(releaseDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	activatedAt := time.Now().UTC()
+	manifest.ActivatedAt = &activatedAt
+	return WriteReleaseManifest(releaseDir, manifest)
+}
+
+// CurrentRelease returns the release the current symlink points to
+func (e *Executor) CurrentRelease() (*Release, error) {
+	currentLink := filepath.Join(e.ProjectRoot, "current")
+	if !fileutil.SymlinkExists(currentLink) {
+		return nil, fmt.Errorf("no current release found (current symlink missing)")
+	}
+
+	currentPath, err := fileutil.ResolveSymlink(currentLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve current symlink: %w", err)
+	}
+
+	releases, err := e.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		if releases[i].Name == filepath.Base(currentPath) {
+			return &releases[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("current release '%s' not found in releases directory", filepath.Base(currentPath))
+}
@@ -0,0 +1,94 @@
+package deployment
+
+import (
+	"sync"
+	"time"
+)
+
+// QueuedDeployment is a push waiting for the running deployment of its project to finish
+type QueuedDeployment struct {
+	Push     *PushEvent
+	QueuedAt time.Time
+	RecordID int64 // History record of the push, 0 if none was made
+}
+
+// DeployQueue coalesces pushes that arrive while a project is already deploying.
+//
+// Each project has a single pending slot on top of its LockManager lock:
+//   - If the project is idle, Submit acquires the lock and the caller deploys immediately
+//   - If a deployment is running, the push takes the pending slot, replacing
+//     (superseding) any push that was already waiting
+//   - When the running deployment finishes, Done hands over the pending push
+//     with the lock still held, or releases the lock if nothing is waiting
+//
+// The queue mutex covers both the lock attempt and the pending slot, so a push
+// can never be parked just after the running deployment checked for work.
+type DeployQueue struct {
+	mu      sync.Mutex
+	locks   *LockManager
+	pending map[string]*QueuedDeployment
+}
+
+// NewDeployQueue creates a deploy queue on top of the given lock manager
+func NewDeployQueue(locks *LockManager) *DeployQueue {
+	return &DeployQueue{
+		locks:   locks,
+		pending: make(map[string]*QueuedDeployment),
+	}
+}
+
+// Submit starts or queues a deployment for the given project.
+//
+// Returns started=true if the project lock was acquired and the caller must
+// run the deployment (and call Done afterwards). Otherwise the item is now
+// pending, and superseded is the previously pending item it replaced, if any.
+func (q *DeployQueue) Submit(projectName string, item *QueuedDeployment) (started bool, superseded *QueuedDeployment) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.locks.TryLock(projectName) {
+		return true, nil
+	}
+
+	superseded = q.pending[projectName]
+	q.pending[projectName] = item
+	return false, superseded
+}
+
+// TryAcquire takes the project lock without queueing a push, for operations
+// such as restores that must not overlap a deployment. Returns false if the
+// project is busy. On success the caller must call Done afterwards, which
+// hands over any push that arrived in the meantime.
+func (q *DeployQueue) TryAcquire(projectName string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.locks.TryLock(projectName)
+}
+
+// Done is called when a deployment finishes.
+//
+// Returns the pending item for the project, keeping the project lock held so
+// the caller can deploy it next. If nothing is pending, the lock is released
+// and nil is returned.
+func (q *DeployQueue) Done(projectName string) *QueuedDeployment {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	next := q.pending[projectName]
+	if next == nil {
+		q.locks.Unlock(projectName)
+		return nil
+	}
+
+	delete(q.pending, projectName)
+	return next
+}
+
+// Pending returns the push currently waiting for the given project, if any
+func (q *DeployQueue) Pending(projectName string) *QueuedDeployment {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.pending[projectName]
+}
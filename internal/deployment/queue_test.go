@@ -0,0 +1,155 @@
+package deployment
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDeployQueue_StartsWhenIdle(t *testing.T) {
+	q := NewDeployQueue(NewLockManager())
+
+	started, superseded := q.Submit("project1", &QueuedDeployment{Push: &PushEvent{Commit: "a"}})
+	if !started {
+		t.Fatal("Expected first submit to start immediately")
+	}
+	if superseded != nil {
+		t.Errorf("Expected nothing superseded, got %+v", superseded)
+	}
+
+	if next := q.Done("project1"); next != nil {
+		t.Errorf("Expected no pending deployment, got %+v", next)
+	}
+
+	// Lock must have been released
+	started, _ = q.Submit("project1", &QueuedDeployment{Push: &PushEvent{Commit: "b"}})
+	if !started {
+		t.Error("Expected submit to start after Done released the lock")
+	}
+	q.Done("project1")
+}
+
+func TestDeployQueue_CoalescesPendingPushes(t *testing.T) {
+	q := NewDeployQueue(NewLockManager())
+
+	if started, _ := q.Submit("project1", &QueuedDeployment{Push: &PushEvent{Commit: "a"}}); !started {
+		t.Fatal("Expected first submit to start")
+	}
+
+	second := &QueuedDeployment{Push: &PushEvent{Commit: "b"}}
+	started, superseded := q.Submit("project1", second)
+	if started {
+		t.Fatal("Expected second submit to be queued")
+	}
+	if superseded != nil {
+		t.Errorf("Expected nothing superseded yet, got %+v", superseded)
+	}
+
+	third := &QueuedDeployment{Push: &PushEvent{Commit: "c"}}
+	started, superseded = q.Submit("project1", third)
+	if started {
+		t.Fatal("Expected third submit to be queued")
+	}
+	if superseded != second {
+		t.Errorf("Expected second push to be superseded, got %+v", superseded)
+	}
+
+	if pending := q.Pending("project1"); pending != third {
+		t.Errorf("Expected newest push to be pending, got %+v", pending)
+	}
+
+	// Finishing the running deployment hands over the newest push with the lock held
+	next := q.Done("project1")
+	if next != third {
+		t.Fatalf("Expected newest push to run next, got %+v", next)
+	}
+	if started, _ := q.Submit("project1", &QueuedDeployment{Push: &PushEvent{Commit: "d"}}); started {
+		t.Error("Expected lock to stay held while the queued push runs")
+	}
+
+	// Drain: "d" runs, then the project goes idle
+	if next := q.Done("project1"); next == nil || next.Push.Commit != "d" {
+		t.Errorf("Expected push d to run next, got %+v", next)
+	}
+	if next := q.Done("project1"); next != nil {
+		t.Errorf("Expected queue to be empty, got %+v", next)
+	}
+}
+
+func TestDeployQueue_ProjectsAreIndependent(t *testing.T) {
+	q := NewDeployQueue(NewLockManager())
+
+	if started, _ := q.Submit("project1", &QueuedDeployment{}); !started {
+		t.Error("project1 should start")
+	}
+	if started, _ := q.Submit("project2", &QueuedDeployment{}); !started {
+		t.Error("project2 should start independently of project1")
+	}
+
+	q.Done("project1")
+	q.Done("project2")
+}
+
+func TestDeployQueue_NoLostPushes(t *testing.T) {
+	q := NewDeployQueue(NewLockManager())
+
+	const pushes = 200
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ran := 0
+	superseded := 0
+
+	// Each submitter that acquires the lock drains the queue like the server does
+	for i := 0; i < pushes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started, old := q.Submit("project1", &QueuedDeployment{})
+			mu.Lock()
+			if old != nil {
+				superseded++
+			}
+			mu.Unlock()
+			if !started {
+				return
+			}
+			for item := (&QueuedDeployment{}); item != nil; item = q.Done("project1") {
+				mu.Lock()
+				ran++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if q.Pending("project1") != nil {
+		t.Error("Expected no push left pending after all runners finished")
+	}
+	if ran+superseded != pushes {
+		t.Errorf("Expected every push to run or be superseded: ran=%d superseded=%d total=%d", ran, superseded, pushes)
+	}
+}
+
+func TestDeployQueue_TryAcquire(t *testing.T) {
+	q := NewDeployQueue(NewLockManager())
+
+	if !q.TryAcquire("project1") {
+		t.Fatal("Expected TryAcquire to succeed when idle")
+	}
+	if q.TryAcquire("project1") {
+		t.Error("Expected TryAcquire to fail while the project is busy")
+	}
+
+	// A push arriving meanwhile is queued and handed over by Done
+	if started, _ := q.Submit("project1", &QueuedDeployment{Push: &PushEvent{Commit: "a"}}); started {
+		t.Error("Expected submit to queue while the project is busy")
+	}
+	if next := q.Done("project1"); next == nil || next.Push.Commit != "a" {
+		t.Errorf("Expected queued push to be handed over, got %+v", next)
+	}
+	if next := q.Done("project1"); next != nil {
+		t.Errorf("Expected no pending deployment, got %+v", next)
+	}
+	if !q.TryAcquire("project1") {
+		t.Error("Expected TryAcquire to succeed after Done released the lock")
+	}
+}
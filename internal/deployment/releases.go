@@ -0,0 +1,94 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"deplobox/internal/security"
+	"deplobox/pkg/fileutil"
+)
+
+// FindRelease returns the complete release with the given name
+func (e *Executor) FindRelease(name string) (*Release, error) {
+	releases, err := e.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		if releases[i].Name == name {
+			return &releases[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release '%s' not found", name)
+}
+
+// ReleaseSize returns the disk usage of a release in bytes, not counting linked shared paths
+func ReleaseSize(release Release) int64 {
+	return dirSize(release.Path)
+}
+
+// ReleaseCommit returns the commit a release contains, from its manifest or,
+// for older releases, from the release's own .git
+func (e *Executor) ReleaseCommit(ctx context.Context, release Release, timeout int) (string, error) {
+	if release.Manifest != nil && release.Manifest.Commit != "" {
+		if err := security.ValidateCommitSHA(release.Manifest.Commit); err != nil {
+			return "", fmt.Errorf("invalid commit in manifest of release '%s': %w", release.Name, err)
+		}
+		return release.Manifest.Commit, nil
+	}
+
+	if !fileutil.DirExists(filepath.Join(release.Path, ".git")) {
+		return "", fmt.Errorf("release '%s' has no manifest or .git to read its commit from", release.Name)
+	}
+
+	cmd := []string{"git", "-C", release.Path, "rev-parse", "--verify", "HEAD"}
+	result, err := e.RunCommand(ctx, cmd, timeout, e.ProjectRoot)
+	if err != nil || !result.OK() {
+		return "", fmt.Errorf("failed to read commit of release '%s': %w", release.Name, err)
+	}
+
+	sha := strings.TrimSpace(result.Stdout)
+	if err := security.ValidateCommitSHA(sha); err != nil {
+		return "", fmt.Errorf("unexpected git rev-parse output %q: %w", sha, err)
+	}
+
+	return sha, nil
+}
+
+// CompareReleases returns the git log (one line per commit) and the diffstat
+// between the commits of two releases. The repository cache is used when
+// present, otherwise the .git of the to release.
+func (e *Executor) CompareReleases(ctx context.Context, from, to Release, timeout int) (string, string, error) {
+	fromCommit, err := e.ReleaseCommit(ctx, from, timeout)
+	if err != nil {
+		return "", "", err
+	}
+	toCommit, err := e.ReleaseCommit(ctx, to, timeout)
+	if err != nil {
+		return "", "", err
+	}
+
+	gitDir := RepoCachePath(e.ProjectRoot)
+	if !HasRepoCache(e.ProjectRoot) {
+		gitDir = filepath.Join(to.Path, ".git")
+		if !fileutil.DirExists(gitDir) {
+			return "", "", fmt.Errorf("no repository cache and release '%s' has no .git to compare with", to.Name)
+		}
+	}
+
+	logCmd := []string{"git", "--git-dir", gitDir, "log", "--oneline", "--no-decorate", fromCommit + ".." + toCommit}
+	logResult, err := e.RunCommand(ctx, logCmd, timeout, e.ProjectRoot)
+	if err != nil || !logResult.OK() {
+		return "", "", fmt.Errorf("failed to list commits between releases: %w", err)
+	}
+
+	statCmd := []string{"git", "--git-dir", gitDir, "diff", "--stat", fromCommit, toCommit}
+	statResult, err := e.RunCommand(ctx, statCmd, timeout, e.ProjectRoot)
+	if err != nil || !statResult.OK() {
+		return "", "", fmt.Errorf("failed to diff releases: %w", err)
+	}
+
+	return logResult.Stdout, statResult.Stdout, nil
+}
@@ -0,0 +1,222 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"deplobox/internal/security"
+	"deplobox/pkg/fileutil"
+)
+
+// RepoCacheDir is the name of the bare repository cache inside the project root
+const RepoCacheDir = "repo"
+
+// RepoCachePath returns the path of the bare repository cache for a project
+func RepoCachePath(projectRoot string) string {
+	return filepath.Join(projectRoot, RepoCacheDir)
+}
+
+// HasRepoCache checks if a project has an initialized bare repository cache
+func HasRepoCache(projectRoot string) bool {
+	return fileutil.FileExists(filepath.Join(RepoCachePath(projectRoot), "HEAD"))
+}
+
+// SyncRepoCache makes sure the bare repository cache exists and fetches the branch into it.
+//
+// On first use the cache is created with a bare clone of the remote used by the
+// current release. Later fetches only transfer new objects, which is what makes
+// release creation cheap compared to a fresh clone.
+//
+// Returns the remote URL so releases can point their origin at it.
+func (e *Executor) SyncRepoCache(ctx context.Context, branch string, timeout int) (string, *ExecutionResult, error) {
+	if err := security.ValidateBranchName(branch); err != nil {
+		return "", nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	repoDir := RepoCachePath(e.ProjectRoot)
+
+	remoteURL, remoteResult, err := e.remoteURL(ctx, timeout)
+	if err != nil {
+		return "", remoteResult, err
+	}
+
+	if !HasRepoCache(e.ProjectRoot) {
+		// Create the cache with a bare clone of the remote
+		cloneCmd := []string{"git", "clone", "--bare", "--single-branch", "--branch", branch, remoteURL, repoDir}
+		result, err := e.RunCommand(ctx, cloneCmd, timeout, e.ProjectRoot)
+		if err != nil || !result.OK() {
+			return "", result, fmt.Errorf("failed to create repository cache: %w", err)
+		}
+	}
+
+	// Fetch the branch, force-updating it so rewritten history is picked up
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/heads/%s", branch, branch)
+	fetchCmd := []string{"git", "--git-dir", repoDir, "fetch", "--prune", "origin", refspec}
+	result, err := e.RunCommand(ctx, fetchCmd, timeout, e.ProjectRoot)
+	if err != nil || !result.OK() {
+		return "", result, fmt.Errorf("failed to fetch into repository cache: %w", err)
+	}
+
+	return remoteURL, result, nil
+}
+
+// remoteURL returns the origin URL from the repository cache, falling back to
+// the current release's .git on the first deployment after installation
+func (e *Executor) remoteURL(ctx context.Context, timeout int) (string, *ExecutionResult, error) {
+	var cmd []string
+
+	if HasRepoCache(e.ProjectRoot) {
+		cmd = []string{"git", "--git-dir", RepoCachePath(e.ProjectRoot), "remote", "get-url", "origin"}
+	} else {
+		// Check if current exists to get the remote URL
+		currentLink := filepath.Join(e.ProjectRoot, "current")
+		if !fileutil.SymlinkExists(currentLink) {
+			return "", nil, fmt.Errorf("no current release found - initial deployment must be done via installer")
+		}
+
+		// Get the actual path that current points to
+		currentPath, err := fileutil.ResolveSymlink(currentLink)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to resolve current symlink: %w", err)
+		}
+
+		// Validate paths to prevent path traversal
+		if _, err := security.SanitizePathForSymlink(e.ProjectRoot, currentPath); err != nil {
+			return "", nil, fmt.Errorf("current symlink points outside project root: %w", err)
+		}
+
+		if !fileutil.DirExists(filepath.Join(currentPath, ".git")) {
+			return "", nil, fmt.Errorf("no repository cache and current release has no .git to read the remote URL from")
+		}
+
+		cmd = []string{"git", "-C", currentPath, "remote", "get-url", "origin"}
+	}
+
+	result, err := e.RunCommand(ctx, cmd, timeout, e.ProjectRoot)
+	if err != nil || !result.OK() {
+		return "", result, fmt.Errorf("failed to get git remote URL: %w", err)
+	}
+
+	remoteURL := strings.TrimSpace(result.Stdout)
+	if remoteURL == "" {
+		return "", nil, fmt.Errorf("git remote URL is empty")
+	}
+
+	return remoteURL, result, nil
+}
+
+// resolveCommit returns the full SHA to deploy from the repository cache.
+// An empty commit resolves to the branch tip; otherwise the commit must be
+// reachable from the branch.
+func (e *Executor) resolveCommit(ctx context.Context, branch, commit string, timeout int) (string, *ExecutionResult, error) {
+	repoDir := RepoCachePath(e.ProjectRoot)
+	branchRef := "refs/heads/" + branch
+
+	rev := branchRef
+	if commit != "" {
+		// The pushed commit must belong to the configured branch
+		ancestorCmd := []string{"git", "--git-dir", repoDir, "merge-base", "--is-ancestor", commit, branchRef}
+		ancestorResult, err := e.RunCommand(ctx, ancestorCmd, timeout, e.ProjectRoot)
+		if err != nil || !ancestorResult.OK() {
+			return "", ancestorResult, fmt.Errorf("commit %s is not on branch %s: %w", commit, branch, err)
+		}
+		rev = commit
+	}
+
+	revParseCmd := []string{"git", "--git-dir", repoDir, "rev-parse", "--verify", "--quiet", rev + "^{commit}"}
+	result, err := e.RunCommand(ctx, revParseCmd, timeout, e.ProjectRoot)
+	if err != nil || !result.OK() {
+		return "", result, fmt.Errorf("failed to resolve commit %s: %w", rev, err)
+	}
+
+	sha := strings.TrimSpace(result.Stdout)
+	if err := security.ValidateCommitSHA(sha); err != nil {
+		return "", result, fmt.Errorf("unexpected git rev-parse output %q: %w", sha, err)
+	}
+
+	return sha, result, nil
+}
+
+// cloneRelease creates a release with a .git directory by locally cloning the
+// cache (objects are hardlinked) and detaching HEAD at the given commit
+func (e *Executor) cloneRelease(ctx context.Context, branch, sha, remoteURL, releaseDir string, timeout int) (*ExecutionResult, error) {
+	repoDir := RepoCachePath(e.ProjectRoot)
+
+	cloneCmd := []string{"git", "clone", "--quiet", "--no-checkout", "--branch", branch, "--single-branch", repoDir, releaseDir}
+	result, err := e.RunCommand(ctx, cloneCmd, timeout, e.ProjectRoot)
+	if err != nil || !result.OK() {
+		return result, fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	// Check out the pushed commit instead of whatever the branch tip is now
+	checkoutCmd := []string{"git", "-C", releaseDir, "checkout", "--quiet", "--detach", sha}
+	checkoutResult, err := e.RunCommand(ctx, checkoutCmd, timeout, e.ProjectRoot)
+	if err != nil || !checkoutResult.OK() {
+		return checkoutResult, fmt.Errorf("failed to check out commit %s: %w", sha, err)
+	}
+
+	// Point origin back at the real remote rather than the local cache
+	remoteCmd := []string{"git", "-C", releaseDir, "remote", "set-url", "origin", remoteURL}
+	remoteResult, err := e.RunCommand(ctx, remoteCmd, timeout, e.ProjectRoot)
+	if err != nil || !remoteResult.OK() {
+		return remoteResult, fmt.Errorf("failed to set release origin: %w", err)
+	}
+
+	// Keep the release manifest out of git status
+	excludePath := filepath.Join(releaseDir, ".git", "info", "exclude")
+	if err := appendLine(excludePath, "/"+ManifestFile); err != nil {
+		return nil, fmt.Errorf("failed to exclude release manifest: %w", err)
+	}
+
+	return result, nil
+}
+
+// exportRelease creates a release without .git by extracting a git archive of the commit
+func (e *Executor) exportRelease(ctx context.Context, sha, releaseDir string, timeout int) (*ExecutionResult, error) {
+	repoDir := RepoCachePath(e.ProjectRoot)
+
+	archive, err := os.CreateTemp(e.ProjectRoot, ".release-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive file: %w", err)
+	}
+	archivePath := archive.Name()
+	archive.Close()
+	defer os.Remove(archivePath)
+
+	archiveCmd := []string{"git", "--git-dir", repoDir, "archive", "--format=tar", "--output", archivePath, sha}
+	result, err := e.RunCommand(ctx, archiveCmd, timeout, e.ProjectRoot)
+	if err != nil || !result.OK() {
+		return result, fmt.Errorf("failed to archive commit %s: %w", sha, err)
+	}
+
+	if err := security.CreateSecureDir(releaseDir, security.PermDirectory); err != nil {
+		return nil, fmt.Errorf("failed to create release directory: %w", err)
+	}
+
+	extractCmd := []string{"tar", "-xf", archivePath, "-C", releaseDir}
+	extractResult, err := e.RunCommand(ctx, extractCmd, timeout, e.ProjectRoot)
+	if err != nil || !extractResult.OK() {
+		return extractResult, fmt.Errorf("failed to extract release archive: %w", err)
+	}
+
+	return result, nil
+}
+
+// appendLine appends a line to a file, creating it and its directory if needed
+func appendLine(path, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), security.PermDirectory); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, security.PermPublicFile)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,199 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"deplobox/internal/project"
+	"deplobox/internal/security"
+	"deplobox/pkg/fileutil"
+)
+
+// FindRestoreTarget returns the release a restore should switch current to.
+//
+// With to set, that is the release with that name or, failing that, the newest
+// release whose commit starts with to (a full or abbreviated SHA). Otherwise it
+// is the release steps releases before current (one if steps is zero).
+func (e *Executor) FindRestoreTarget(ctx context.Context, to string, steps int, timeout int) (*Release, error) {
+	current, err := e.CurrentRelease()
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := e.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	var target *Release
+	if to != "" {
+		target, err = e.findReleaseByRef(ctx, releases, to, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if target.Name == current.Name {
+			return nil, fmt.Errorf("cannot restore: release '%s' is already current", target.Name)
+		}
+		return target, nil
+	}
+
+	if steps < 0 {
+		return nil, fmt.Errorf("steps must be a positive number, got %d", steps)
+	}
+	if steps == 0 {
+		steps = 1
+	}
+
+	// Need at least 2 releases to restore
+	if len(releases) < 2 {
+		return nil, fmt.Errorf("cannot restore: only one release exists (need at least 2 releases)")
+	}
+
+	for i, release := range releases {
+		if release.Name != current.Name {
+			continue
+		}
+		if i+steps >= len(releases) {
+			if i == len(releases)-1 {
+				return nil, fmt.Errorf("cannot restore: current release '%s' is already the oldest", current.Name)
+			}
+			return nil, fmt.Errorf("cannot restore: only %d releases are older than current release '%s'", len(releases)-1-i, current.Name)
+		}
+		return &releases[i+steps], nil
+	}
+
+	return nil, fmt.Errorf("current release '%s' not found in releases directory", current.Name)
+}
+
+// findReleaseByRef finds a release by name or by (abbreviated) commit SHA
+func (e *Executor) findReleaseByRef(ctx context.Context, releases []Release, ref string, timeout int) (*Release, error) {
+	for i := range releases {
+		if releases[i].Name == ref {
+			return &releases[i], nil
+		}
+	}
+
+	if err := security.ValidateCommitSHA(ref); err != nil {
+		return nil, fmt.Errorf("release '%s' not found", ref)
+	}
+
+	// Releases are newest first, so the newest release of the commit wins
+	ref = strings.ToLower(ref)
+	for i := range releases {
+		if commit, err := e.ReleaseCommit(ctx, releases[i], timeout); err == nil && strings.HasPrefix(commit, ref) {
+			return &releases[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no release found with name or commit '%s'", ref)
+}
+
+// RestoreRelease switches the current symlink to the given release and
+// records its activation time in its manifest, if it has one.
+// Returns the name of the release that was current before.
+func (e *Executor) RestoreRelease(release Release) (string, error) {
+	currentPath, err := fileutil.ResolveSymlink(filepath.Join(e.ProjectRoot, "current"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve current symlink: %w", err)
+	}
+
+	// Validate release path exists
+	if !fileutil.DirExists(release.Path) {
+		return "", fmt.Errorf("release directory does not exist: %s", release.Path)
+	}
+
+	// Validate release path
+	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, release.Path); err != nil {
+		return "", fmt.Errorf("release directory outside project root: %w", err)
+	}
+
+	if err := e.UpdateCurrentSymlink(release.Path); err != nil {
+		return "", fmt.Errorf("failed to update current symlink: %w", err)
+	}
+
+	// The release is live, so only warn if its activation cannot be recorded
+	if err := markActivated(release.Path); err != nil && e.Logger != nil {
+		e.Logger.Warn("failed to update release manifest", "project_root", e.ProjectRoot, "release", release.Name, "error", err)
+	}
+
+	return filepath.Base(currentPath), nil
+}
+
+// NewRestore creates a deployment that restores an existing release of the
+// project instead of building a new one. Run it with Restore.
+func NewRestore(proj *project.Project, exposeOutput bool, logger *slog.Logger) *Deployment {
+	return NewDeployment(proj, &PushEvent{Ref: "refs/heads/" + proj.Branch, Trigger: TriggerRestore}, exposeOutput, logger)
+}
+
+// Restore switches current to the target release and, if runPostActivate is
+// set, runs the project's post_activate commands in it with the usual hook
+// environment. Restored is set once current points at the target, even if a
+// post_activate command fails afterwards. The project lock is taken unless
+// the caller already holds it in Lock.
+func (d *Deployment) Restore(ctx context.Context, target *Release, runPostActivate bool) (map[string]interface{}, int) {
+	if err := security.ValidateProjectName(d.Project.Name); err != nil {
+		d.log(slog.LevelError, "invalid project name", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("Invalid project name: %v", err), nil), http.StatusBadRequest
+	}
+
+	if d.Lock == nil {
+		lock, response, statusCode := d.lockProject(ctx, "restore")
+		if lock == nil {
+			return response, statusCode
+		}
+		defer lock.Release()
+	}
+
+	if err := d.loadProjectEnv(); err != nil {
+		d.log(slog.LevelError, "failed to load hook environment", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("Failed to load hook environment: %v", err), nil), http.StatusInternalServerError
+	}
+	if currentPath, err := fileutil.ResolveSymlink(filepath.Join(d.Project.Path, "current")); err == nil {
+		d.PreviousRelease = currentPath
+	}
+	if commit, err := d.Executor.ReleaseCommit(ctx, *target, d.Project.PullTimeout); err == nil {
+		d.CommitHash = commit
+	}
+	d.ReleaseDir = target.Path
+	d.Manifest = target.Manifest
+	d.updateHookEnv()
+
+	d.log(slog.LevelInfo, "restoring release", "project", d.Project.Name, "release", target.Name, "commit", d.CommitHash)
+	previous, err := d.Executor.RestoreRelease(*target)
+	if err != nil {
+		d.log(slog.LevelError, "restore failed", "project", d.Project.Name, "error", err)
+		return d.errorResponse(fmt.Sprintf("Restore failed: %v", err), nil), http.StatusInternalServerError
+	}
+	d.Restored = true
+	d.log(slog.LevelInfo, "release restored", "project", d.Project.Name, "previous_release", previous, "restored_release", target.Name)
+
+	if runPostActivate && len(d.Project.PostActivate) > 0 {
+		d.log(slog.LevelInfo, "running post-activate commands", "project", d.Project.Name, "command_count", len(d.Project.PostActivate))
+		results, err := d.Executor.RunPostActivateCommands(ctx, d.Project.PostActivate, d.Project.PostActivateTimeout)
+		for i, result := range results {
+			d.Outputs = append(d.Outputs, result.Stdout, result.Stderr)
+			d.logOutput(fmt.Sprintf("post_activate[%d]", i), result)
+		}
+		if err != nil {
+			d.log(slog.LevelError, "post-activate command failed", "project", d.Project.Name, "error", err)
+			response := d.errorResponse(fmt.Sprintf("Restored to %s; post-activate command failed: %v", target.Name, err), nil)
+			response["restored_release"] = target.Name
+			response["previous_release"] = previous
+			return response, http.StatusInternalServerError
+		}
+	}
+
+	response := map[string]interface{}{
+		"message":          "Restore successful",
+		"restored_release": target.Name,
+		"previous_release": previous,
+	}
+	if d.ExposeOutput {
+		response["output"] = strings.Join(d.Outputs, "\n")
+	}
+	return response, http.StatusOK
+}
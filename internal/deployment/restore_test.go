@@ -0,0 +1,97 @@
+package deployment
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"deplobox/internal/project"
+)
+
+func TestExecutor_FindRestoreTarget(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{
+		"2024-12-09-10-00-00",
+		"2024-12-09-11-00-00",
+		"2024-12-09-12-00-00",
+		"2024-12-09-13-00-00",
+	}, "2024-12-09-13-00-00")
+
+	manifest := &ReleaseManifest{Release: "2024-12-09-11-00-00", Commit: "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"}
+	if err := WriteReleaseManifest(filepath.Join(tmpDir, "releases", "2024-12-09-11-00-00"), manifest); err != nil {
+		t.Fatalf("WriteReleaseManifest error: %v", err)
+	}
+
+	executor := NewExecutor(tmpDir)
+	tests := []struct {
+		name     string
+		to       string
+		steps    int
+		expected string
+	}{
+		{"default steps back one", "", 0, "2024-12-09-12-00-00"},
+		{"steps back two", "", 2, "2024-12-09-11-00-00"},
+		{"by release name", "2024-12-09-10-00-00", 0, "2024-12-09-10-00-00"},
+		{"by abbreviated commit", "1a2b3c4", 0, "2024-12-09-11-00-00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := executor.FindRestoreTarget(context.Background(), tt.to, tt.steps, 30)
+			if err != nil {
+				t.Fatalf("FindRestoreTarget error: %v", err)
+			}
+			if target.Name != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, target.Name)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name  string
+		to    string
+		steps int
+	}{
+		{"too many steps", "", 4},
+		{"negative steps", "", -1},
+		{"current release", "2024-12-09-13-00-00", 0},
+		{"unknown commit", "deadbeef", 0},
+		{"unknown name", "not-a-release", 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := executor.FindRestoreTarget(context.Background(), tt.to, tt.steps, 30); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestDeployment_Restore_HeldLock(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{"2024-12-09-10-00-00", "2024-12-09-11-00-00"}, "2024-12-09-11-00-00")
+	proj := &project.Project{Name: "test-project", Path: tmpDir, Branch: "main"}
+
+	// The caller takes the lock before choosing the release
+	lock, err := TryLockProject(tmpDir, "restore")
+	if err != nil {
+		t.Fatalf("TryLockProject error: %v", err)
+	}
+	defer lock.Release()
+
+	restore := NewRestore(proj, false, nil)
+	restore.Lock = lock
+	target, err := restore.Executor.FindRestoreTarget(context.Background(), "", 1, 30)
+	if err != nil {
+		t.Fatalf("FindRestoreTarget error: %v", err)
+	}
+
+	response, statusCode := restore.Restore(context.Background(), target, false)
+	if statusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d (%v)", http.StatusOK, statusCode, response)
+	}
+
+	// Restore leaves the caller's lock held
+	if _, err := TryLockProject(tmpDir, "deploy"); err == nil {
+		t.Error("Expected the caller's lock to still be held")
+	}
+}
@@ -0,0 +1,438 @@
+package deployment
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"deplobox/internal/security"
+	"deplobox/pkg/fileutil"
+)
+
+const (
+	// PinsFile lists pinned release names, one per line, in the project root
+	PinsFile = ".deplobox-pins"
+
+	// IncompleteSuffix marks a release that is still being built: releases/<name>.incomplete
+	// exists from the start of the build until the release is ready to activate
+	IncompleteSuffix = ".incomplete"
+)
+
+// RetentionPolicy controls which releases CleanupReleases removes.
+// Zero values disable the corresponding limit.
+type RetentionPolicy struct {
+	KeepReleases int           // Maximum number of releases to keep
+	MaxAge       time.Duration // Remove releases older than this
+	MaxSize      int64         // Remove the oldest releases while the total size exceeds this (bytes)
+}
+
+// Release describes a release directory
+type Release struct {
+	Name     string
+	Path     string
+	Created  time.Time
+	Manifest *ReleaseManifest // nil for releases created before manifests
+}
+
+// ListReleases returns the project's complete releases, newest first.
+// Releases that are still being built or whose build failed are skipped.
+func (e *Executor) ListReleases() ([]Release, error) {
+	releasesDir := filepath.Join(e.ProjectRoot, "releases")
+
+	entries, err := os.ReadDir(releasesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read releases directory: %w", err)
+	}
+
+	var releases []Release
+	for _, entry := range entries {
+		if !entry.IsDir() || fileutil.FileExists(incompleteMarker(filepath.Join(releasesDir, entry.Name()))) {
+			continue
+		}
+		release := Release{
+			Name: entry.Name(),
+			Path: filepath.Join(releasesDir, entry.Name()),
+		}
+		if manifest, err := ReadReleaseManifest(release.Path); err == nil {
+			release.Manifest = manifest
+		}
+		release.Created = releaseTime(entry, release.Manifest)
+		releases = append(releases, release)
+	}
+
+	// Order by creation time, so legacy local-time names sort correctly among UTC ones
+	sort.Slice(releases, func(i, j int) bool {
+		if !releases[i].Created.Equal(releases[j].Created) {
+			return releases[i].Created.After(releases[j].Created)
+		}
+		return releases[i].Name > releases[j].Name
+	})
+
+	return releases, nil
+}
+
+// releaseTime returns the creation time from the release manifest or the
+// release name, falling back to the directory modification time
+func releaseTime(entry fs.DirEntry, manifest *ReleaseManifest) time.Time {
+	if manifest != nil && !manifest.CreatedAt.IsZero() {
+		return manifest.CreatedAt
+	}
+	if t, ok := parseReleaseName(entry.Name()); ok {
+		return t
+	}
+	if info, err := entry.Info(); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// protectedReleases returns the releases that cleanup must never remove:
+// the current release, the one live before it and every pinned release
+func (e *Executor) protectedReleases(releases []Release) (map[string]bool, error) {
+	protected, err := e.PinnedReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	currentLink := filepath.Join(e.ProjectRoot, "current")
+	if !fileutil.SymlinkExists(currentLink) {
+		return protected, nil
+	}
+	currentPath, err := fileutil.ResolveSymlink(currentLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve current symlink: %w", err)
+	}
+
+	current := filepath.Base(currentPath)
+	protected[current] = true
+	if previous := PreviousRelease(releases, current); previous != "" {
+		protected[previous] = true
+	}
+
+	return protected, nil
+}
+
+// PreviousRelease returns the name of the release that was live before the
+// current one: the most recently activated other release, by the activation
+// times in the manifests. Without any, e.g. for releases made before
+// manifests, it is the release created just before current. Returns "" if
+// there is none.
+func PreviousRelease(releases []Release, current string) string {
+	var previous string
+	var previousActivated time.Time
+	for _, release := range releases {
+		if release.Name == current || release.Manifest == nil || release.Manifest.ActivatedAt == nil {
+			continue
+		}
+		if activated := *release.Manifest.ActivatedAt; previous == "" || activated.After(previousActivated) {
+			previous, previousActivated = release.Name, activated
+		}
+	}
+	if previous != "" {
+		return previous
+	}
+
+	for i, release := range releases {
+		if release.Name == current && i+1 < len(releases) {
+			return releases[i+1].Name
+		}
+	}
+	return ""
+}
+
+// CleanupReleases removes releases according to the retention policy.
+// Releases beyond KeepReleases or older than MaxAge are removed, then the
+// oldest remaining releases are removed while the total exceeds MaxSize.
+// The current, previous (see PreviousRelease) and pinned releases are always kept.
+// Returns the names of the removed releases.
+func (e *Executor) CleanupReleases(policy RetentionPolicy) ([]string, error) {
+	releases, err := e.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	protected, err := e.protectedReleases(releases)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var kept []Release
+	var removed []string
+	for i, release := range releases {
+		expired := (policy.KeepReleases > 0 && i >= policy.KeepReleases) ||
+			(policy.MaxAge > 0 && now.Sub(release.Created) > policy.MaxAge)
+		if !expired || protected[release.Name] {
+			kept = append(kept, release)
+			continue
+		}
+		if e.removeRelease(release) {
+			removed = append(removed, release.Name)
+		}
+	}
+
+	if policy.MaxSize <= 0 {
+		return removed, nil
+	}
+
+	// Enforce the disk budget, oldest releases first
+	sizes := make([]int64, len(kept))
+	var total int64
+	for i, release := range kept {
+		sizes[i] = dirSize(release.Path)
+		total += sizes[i]
+	}
+	for i := len(kept) - 1; i >= 0 && total > policy.MaxSize; i-- {
+		if protected[kept[i].Name] {
+			continue
+		}
+		if e.removeRelease(kept[i]) {
+			removed = append(removed, kept[i].Name)
+			total -= sizes[i]
+		}
+	}
+	if total > policy.MaxSize {
+		fmt.Fprintf(os.Stderr, "Warning: releases use %d bytes, over the %d byte budget, but the rest are protected\n", total, policy.MaxSize)
+	}
+
+	return removed, nil
+}
+
+// CleanupOldReleases removes old releases, keeping the specified number of most recent
+func (e *Executor) CleanupOldReleases(keepCount int) error {
+	_, err := e.CleanupReleases(RetentionPolicy{KeepReleases: keepCount})
+	return err
+}
+
+// removeRelease deletes a release directory, reporting whether it was removed
+func (e *Executor) removeRelease(release Release) bool {
+	// Validate path before deletion
+	if _, err := security.SanitizePathForSymlink(e.ProjectRoot, release.Path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping deletion of release outside project root: %s\n", release.Name)
+		return false
+	}
+
+	if err := os.RemoveAll(release.Path); err != nil {
+		// Log error but continue
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove old release %s: %v\n", release.Name, err)
+		return false
+	}
+
+	return true
+}
+
+// dirSize returns the total size of the regular files under path.
+// Symlinks are not followed, so linked shared paths are not counted.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// incompleteMarker returns the path of the marker file for a release directory
+func incompleteMarker(releaseDir string) string {
+	return releaseDir + IncompleteSuffix
+}
+
+// markReleaseIncomplete creates the marker that excludes a release from
+// ListReleases until MarkReleaseComplete is called. It fails with fs.ErrExist
+// if the release is already taken, so the marker also reserves the name.
+func (e *Executor) markReleaseIncomplete(releaseDir string) error {
+	if err := os.MkdirAll(filepath.Dir(releaseDir), security.PermDirectory); err != nil {
+		return fmt.Errorf("failed to create releases directory: %w", err)
+	}
+	if fileutil.PathExists(releaseDir) {
+		return fmt.Errorf("release %s already exists: %w", filepath.Base(releaseDir), fs.ErrExist)
+	}
+	marker, err := os.OpenFile(incompleteMarker(releaseDir), os.O_CREATE|os.O_EXCL|os.O_WRONLY, security.PermPublicFile)
+	if err != nil {
+		return fmt.Errorf("failed to mark release incomplete: %w", err)
+	}
+	return marker.Close()
+}
+
+// reserveRelease picks an unused directory for a new release of the given
+// commit and marks it incomplete. A numeric suffix is added if two deployments
+// of the same commit start within the same second.
+func (e *Executor) reserveRelease(created time.Time, sha string) (string, error) {
+	releasesDir := filepath.Join(e.ProjectRoot, "releases")
+	base := releaseName(created, sha)
+
+	for i := 1; i <= 100; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		releaseDir := filepath.Join(releasesDir, name)
+
+		err := e.markReleaseIncomplete(releaseDir)
+		if err == nil {
+			return releaseDir, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("failed to find an unused release name for %s", base)
+}
+
+// MarkReleaseComplete removes the incomplete marker once a release is fully built
+func (e *Executor) MarkReleaseComplete(releaseDir string) error {
+	if err := os.Remove(incompleteMarker(releaseDir)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to mark release complete: %w", err)
+	}
+	return nil
+}
+
+// CleanupFailedReleases removes releases whose build never completed, along
+// with their markers. With keepLast the newest failed build is kept for debugging.
+// Only call this while holding the project lock, since a
+// release being built looks the same as a failed one.
+// Returns the names of the removed releases.
+func (e *Executor) CleanupFailedReleases(keepLast bool) ([]string, error) {
+	releasesDir := filepath.Join(e.ProjectRoot, "releases")
+
+	entries, err := os.ReadDir(releasesDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read releases directory: %w", err)
+	}
+
+	var failed []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), IncompleteSuffix); ok && !entry.IsDir() && name != "" {
+			failed = append(failed, name)
+		}
+	}
+
+	// Newest first, so the first one is the last failed build
+	sort.Sort(sort.Reverse(sort.StringSlice(failed)))
+	if keepLast && len(failed) > 0 {
+		failed = failed[1:]
+	}
+
+	var removed []string
+	for _, name := range failed {
+		release := Release{Name: name, Path: filepath.Join(releasesDir, name)}
+		if fileutil.PathExists(release.Path) && !e.removeRelease(release) {
+			continue
+		}
+		if err := os.Remove(incompleteMarker(release.Path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove marker of failed release %s: %v\n", name, err)
+		}
+		removed = append(removed, name)
+	}
+
+	return removed, nil
+}
+
+// PinnedReleases returns the set of pinned release names
+func (e *Executor) PinnedReleases() (map[string]bool, error) {
+	pinned := make(map[string]bool)
+
+	file, err := os.Open(filepath.Join(e.ProjectRoot, PinsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return pinned, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pins file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			pinned[name] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pins file: %w", err)
+	}
+
+	return pinned, nil
+}
+
+// PinRelease protects a release from cleanup
+func (e *Executor) PinRelease(name string) error {
+	if err := e.validateReleaseName(name); err != nil {
+		return err
+	}
+
+	pinned, err := e.PinnedReleases()
+	if err != nil {
+		return err
+	}
+	pinned[name] = true
+
+	return e.writePins(pinned)
+}
+
+// UnpinRelease makes a pinned release subject to cleanup again
+func (e *Executor) UnpinRelease(name string) error {
+	pinned, err := e.PinnedReleases()
+	if err != nil {
+		return err
+	}
+	if !pinned[name] {
+		return fmt.Errorf("release '%s' is not pinned", name)
+	}
+	delete(pinned, name)
+
+	return e.writePins(pinned)
+}
+
+// validateReleaseName checks that name is an existing release directory
+func (e *Executor) validateReleaseName(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("invalid release name '%s'", name)
+	}
+	if !fileutil.DirExists(filepath.Join(e.ProjectRoot, "releases", name)) {
+		return fmt.Errorf("release '%s' not found", name)
+	}
+	return nil
+}
+
+// writePins replaces the pins file with the given release names
+func (e *Executor) writePins(pinned map[string]bool) error {
+	names := make([]string, 0, len(pinned))
+	for name := range pinned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var content string
+	if len(names) > 0 {
+		content = strings.Join(names, "\n") + "\n"
+	}
+
+	pinsPath := filepath.Join(e.ProjectRoot, PinsFile)
+	tmpPath := pinsPath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write pins file: %w", err)
+	}
+	if err := os.Rename(tmpPath, pinsPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write pins file: %w", err)
+	}
+
+	return nil
+}
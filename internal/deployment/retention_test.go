@@ -0,0 +1,318 @@
+package deployment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"deplobox/pkg/fileutil"
+)
+
+// createReleases creates empty release directories and points current at currentRelease
+func createReleases(t *testing.T, root string, names []string, currentRelease string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(root, "releases", name), 0755); err != nil {
+			t.Fatalf("Failed to create release dir %s: %v", name, err)
+		}
+	}
+	if currentRelease != "" {
+		if err := os.Symlink("releases/"+currentRelease, filepath.Join(root, "current")); err != nil {
+			t.Fatalf("Failed to create current symlink: %v", err)
+		}
+	}
+}
+
+// remainingReleases returns the names of the releases left on disk
+func remainingReleases(t *testing.T, executor *Executor) []string {
+	t.Helper()
+
+	releases, err := executor.ListReleases()
+	if err != nil {
+		t.Fatalf("ListReleases error: %v", err)
+	}
+	var names []string
+	for _, release := range releases {
+		names = append(names, release.Name)
+	}
+	return names
+}
+
+func TestExecutor_CleanupReleases_KeepReleases(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{
+		"2024-12-09-10-00-00",
+		"2024-12-09-11-00-00",
+		"2024-12-09-12-00-00",
+		"2024-12-09-13-00-00",
+	}, "2024-12-09-13-00-00")
+
+	executor := NewExecutor(tmpDir)
+	removed, err := executor.CleanupReleases(RetentionPolicy{KeepReleases: 2})
+	if err != nil {
+		t.Fatalf("CleanupReleases error: %v", err)
+	}
+
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 removed releases, got %v", removed)
+	}
+	got := strings.Join(remainingReleases(t, executor), ",")
+	if got != "2024-12-09-13-00-00,2024-12-09-12-00-00" {
+		t.Errorf("Unexpected remaining releases: %s", got)
+	}
+}
+
+func TestExecutor_CleanupReleases_KeepsCurrentPreviousAndPinned(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{
+		"2024-12-09-10-00-00",
+		"2024-12-09-11-00-00",
+		"2024-12-09-12-00-00",
+		"2024-12-09-13-00-00",
+		"2024-12-09-14-00-00",
+	}, "2024-12-09-12-00-00") // e.g. after restore
+
+	executor := NewExecutor(tmpDir)
+	if err := executor.PinRelease("2024-12-09-10-00-00"); err != nil {
+		t.Fatalf("PinRelease error: %v", err)
+	}
+
+	// Every release is expired by age, only protected releases survive
+	if _, err := executor.CleanupReleases(RetentionPolicy{KeepReleases: 1, MaxAge: time.Hour}); err != nil {
+		t.Fatalf("CleanupReleases error: %v", err)
+	}
+
+	got := strings.Join(remainingReleases(t, executor), ",")
+	expected := "2024-12-09-12-00-00,2024-12-09-11-00-00,2024-12-09-10-00-00"
+	if got != expected {
+		t.Errorf("Expected remaining releases %s, got %s", expected, got)
+	}
+}
+
+func TestExecutor_CleanupReleases_KeepsReleaseLiveBeforeRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	names := []string{"20241209T100000Z-aaaaaaa", "20241209T110000Z-bbbbbbb", "20241209T120000Z-ccccccc"}
+	createReleases(t, tmpDir, names, names[2])
+
+	activatedAt := time.Now().Add(-time.Hour).UTC()
+	for _, name := range names {
+		manifest := &ReleaseManifest{Release: name}
+		if name == names[2] {
+			manifest.ActivatedAt = &activatedAt
+		}
+		if err := WriteReleaseManifest(filepath.Join(tmpDir, "releases", name), manifest); err != nil {
+			t.Fatalf("WriteReleaseManifest error: %v", err)
+		}
+	}
+
+	// Restoring the oldest release makes the newest one the release live before it
+	executor := NewExecutor(tmpDir)
+	if _, err := executor.RestoreRelease(Release{Name: names[0], Path: filepath.Join(tmpDir, "releases", names[0])}); err != nil {
+		t.Fatalf("RestoreRelease error: %v", err)
+	}
+	releases, err := executor.ListReleases()
+	if err != nil {
+		t.Fatalf("ListReleases error: %v", err)
+	}
+	if previous := PreviousRelease(releases, names[0]); previous != names[2] {
+		t.Errorf("Expected previous release %s, got %s", names[2], previous)
+	}
+
+	if _, err := executor.CleanupReleases(RetentionPolicy{MaxAge: time.Hour}); err != nil {
+		t.Fatalf("CleanupReleases error: %v", err)
+	}
+
+	got := strings.Join(remainingReleases(t, executor), ",")
+	expected := names[2] + "," + names[0]
+	if got != expected {
+		t.Errorf("Expected remaining releases %s, got %s", expected, got)
+	}
+}
+
+func TestExecutor_CleanupReleases_MaxSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	names := []string{
+		"2024-12-09-10-00-00",
+		"2024-12-09-11-00-00",
+		"2024-12-09-12-00-00",
+		"2024-12-09-13-00-00",
+	}
+	createReleases(t, tmpDir, names, "2024-12-09-13-00-00")
+	for _, name := range names {
+		data := make([]byte, 1000)
+		if err := os.WriteFile(filepath.Join(tmpDir, "releases", name, "app.bin"), data, 0644); err != nil {
+			t.Fatalf("Failed to write release file: %v", err)
+		}
+	}
+
+	executor := NewExecutor(tmpDir)
+	if _, err := executor.CleanupReleases(RetentionPolicy{MaxSize: 2500}); err != nil {
+		t.Fatalf("CleanupReleases error: %v", err)
+	}
+
+	got := strings.Join(remainingReleases(t, executor), ",")
+	if got != "2024-12-09-13-00-00,2024-12-09-12-00-00" {
+		t.Errorf("Unexpected remaining releases: %s", got)
+	}
+}
+
+func TestExecutor_PinRelease(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{"2024-12-09-10-00-00"}, "")
+
+	executor := NewExecutor(tmpDir)
+	if err := executor.PinRelease("2024-12-09-10-00-00"); err != nil {
+		t.Fatalf("PinRelease error: %v", err)
+	}
+	if err := executor.PinRelease("missing"); err == nil {
+		t.Error("Expected error when pinning a missing release")
+	}
+	if err := executor.PinRelease("../releases"); err == nil {
+		t.Error("Expected error when pinning an invalid release name")
+	}
+
+	pinned, err := executor.PinnedReleases()
+	if err != nil {
+		t.Fatalf("PinnedReleases error: %v", err)
+	}
+	if !pinned["2024-12-09-10-00-00"] || len(pinned) != 1 {
+		t.Errorf("Unexpected pinned releases: %v", pinned)
+	}
+
+	if err := executor.UnpinRelease("2024-12-09-10-00-00"); err != nil {
+		t.Fatalf("UnpinRelease error: %v", err)
+	}
+	if err := executor.UnpinRelease("2024-12-09-10-00-00"); err == nil {
+		t.Error("Expected error when unpinning a release that is not pinned")
+	}
+}
+
+func TestExecutor_CleanupFailedReleases(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{
+		"2024-12-09-10-00-00",
+		"2024-12-09-11-00-00",
+		"2024-12-09-12-00-00",
+	}, "2024-12-09-10-00-00")
+
+	executor := NewExecutor(tmpDir)
+	for _, name := range []string{"2024-12-09-11-00-00", "2024-12-09-12-00-00", "2024-12-09-13-00-00"} {
+		// 2024-12-09-13-00-00 failed before its directory was created
+		if err := os.WriteFile(incompleteMarker(filepath.Join(tmpDir, "releases", name)), nil, 0644); err != nil {
+			t.Fatalf("Failed to write marker: %v", err)
+		}
+	}
+
+	// Incomplete releases are not listed
+	if got := strings.Join(remainingReleases(t, executor), ","); got != "2024-12-09-10-00-00" {
+		t.Errorf("Expected only the complete release to be listed, got %s", got)
+	}
+
+	removed, err := executor.CleanupFailedReleases(true)
+	if err != nil {
+		t.Fatalf("CleanupFailedReleases error: %v", err)
+	}
+	if strings.Join(removed, ",") != "2024-12-09-12-00-00,2024-12-09-11-00-00" {
+		t.Errorf("Unexpected removed releases: %v", removed)
+	}
+	if !fileutil.FileExists(filepath.Join(tmpDir, "releases", "2024-12-09-13-00-00"+IncompleteSuffix)) {
+		t.Error("Expected the last failed build to be kept")
+	}
+
+	if _, err := executor.CleanupFailedReleases(false); err != nil {
+		t.Fatalf("CleanupFailedReleases error: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "releases"))
+	if err != nil {
+		t.Fatalf("Failed to read releases dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "2024-12-09-10-00-00" {
+		t.Errorf("Expected only the complete release to remain, got %v", entries)
+	}
+}
+
+func TestExecutor_MarkReleaseComplete(t *testing.T) {
+	tmpDir := t.TempDir()
+	releaseDir := filepath.Join(tmpDir, "releases", "2024-12-09-10-00-00")
+
+	executor := NewExecutor(tmpDir)
+	if err := executor.markReleaseIncomplete(releaseDir); err != nil {
+		t.Fatalf("markReleaseIncomplete error: %v", err)
+	}
+	if err := executor.markReleaseIncomplete(releaseDir); err == nil {
+		t.Error("Expected marking a reserved release to fail")
+	}
+	createReleases(t, tmpDir, []string{"2024-12-09-10-00-00"}, "")
+	if got := remainingReleases(t, executor); len(got) != 0 {
+		t.Errorf("Expected incomplete release not to be listed, got %v", got)
+	}
+	if err := executor.MarkReleaseComplete(releaseDir); err != nil {
+		t.Fatalf("MarkReleaseComplete error: %v", err)
+	}
+
+	if got := strings.Join(remainingReleases(t, executor), ","); got != "2024-12-09-10-00-00" {
+		t.Errorf("Expected completed release to be listed, got %s", got)
+	}
+}
+
+func TestExecutor_ReserveRelease(t *testing.T) {
+	tmpDir := t.TempDir()
+	executor := NewExecutor(tmpDir)
+	created := time.Date(2025, 12, 7, 13, 8, 3, 0, time.UTC)
+	sha := "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+
+	first, err := executor.reserveRelease(created, sha)
+	if err != nil {
+		t.Fatalf("reserveRelease error: %v", err)
+	}
+	second, err := executor.reserveRelease(created, sha)
+	if err != nil {
+		t.Fatalf("reserveRelease error: %v", err)
+	}
+
+	if filepath.Base(first) != "20251207T130803Z-1a2b3c4" {
+		t.Errorf("Unexpected release name %s", filepath.Base(first))
+	}
+	if filepath.Base(second) != "20251207T130803Z-1a2b3c4-2" {
+		t.Errorf("Expected a suffixed name for a same-second deploy, got %s", filepath.Base(second))
+	}
+}
+
+func TestExecutor_ListReleases_OrdersByCreationTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	createReleases(t, tmpDir, []string{
+		"2024-12-09-10-00-00", // legacy local-time name
+		"20251207T130803Z-fff0000",
+		"20251207T130803Z-aaa0000",
+	}, "")
+
+	// Same second: the manifest timestamps decide the order
+	base := time.Date(2025, 12, 7, 13, 8, 3, 0, time.UTC)
+	for i, name := range []string{"20251207T130803Z-fff0000", "20251207T130803Z-aaa0000"} {
+		manifest := &ReleaseManifest{Release: name, CreatedAt: base.Add(time.Duration(i) * time.Millisecond)}
+		if err := WriteReleaseManifest(filepath.Join(tmpDir, "releases", name), manifest); err != nil {
+			t.Fatalf("WriteReleaseManifest error: %v", err)
+		}
+	}
+
+	executor := NewExecutor(tmpDir)
+	got := strings.Join(remainingReleases(t, executor), ",")
+	expected := "20251207T130803Z-aaa0000,20251207T130803Z-fff0000,2024-12-09-10-00-00"
+	if got != expected {
+		t.Errorf("Expected releases %s, got %s", expected, got)
+	}
+
+	releases, err := executor.ListReleases()
+	if err != nil {
+		t.Fatalf("ListReleases error: %v", err)
+	}
+	if releases[0].Manifest == nil || releases[0].Manifest.Release != "20251207T130803Z-aaa0000" {
+		t.Errorf("Expected manifest to be loaded, got %+v", releases[0].Manifest)
+	}
+	if releases[2].Manifest != nil {
+		t.Errorf("Expected no manifest for a legacy release, got %+v", releases[2].Manifest)
+	}
+}
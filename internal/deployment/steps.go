@@ -0,0 +1,54 @@
+package deployment
+
+import "time"
+
+// StepObserver is told about deployment steps as they run, for step logs.
+// Calls come from the goroutine running the deployment.
+type StepObserver interface {
+	StepStarted(step *StepResult)
+	StepFinished(step *StepResult)
+}
+
+// StepResult describes one step of a deployment: a hook command, the release
+// build or a shared files operation
+type StepResult struct {
+	Name      string // e.g. "git_clone", "post_deploy[0]"
+	Command   string // Command line, or a description for built-in steps
+	StartedAt time.Time
+	ExitCode  int
+	Duration  time.Duration
+	Output    string
+	Error     string // Set if the step failed
+}
+
+// startStep begins a step and notifies the observer, if any
+func (e *Executor) startStep(name, command string) {
+	if e.Observer == nil {
+		return
+	}
+	e.step = &StepResult{Name: name, Command: command, StartedAt: time.Now()}
+	e.Observer.StepStarted(e.step)
+}
+
+// finishStep ends the current step with the output and outcome of its
+// commands and notifies the observer, if any
+func (e *Executor) finishStep(result *ExecutionResult, output string, err error) {
+	if e.Observer == nil || e.step == nil {
+		return
+	}
+
+	step := e.step
+	e.step = nil
+	step.Duration = time.Since(step.StartedAt)
+	step.Output = output
+	if result != nil {
+		step.ExitCode = result.ReturnCode
+	}
+	if err != nil {
+		step.Error = err.Error()
+		if step.ExitCode == 0 {
+			step.ExitCode = -1
+		}
+	}
+	e.Observer.StepFinished(step)
+}
@@ -0,0 +1,60 @@
+package deployment
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordingObserver collects step notifications
+type recordingObserver struct {
+	started  []string
+	finished []StepResult
+}
+
+func (o *recordingObserver) StepStarted(step *StepResult) { o.started = append(o.started, step.Name) }
+
+func (o *recordingObserver) StepFinished(step *StepResult) { o.finished = append(o.finished, *step) }
+
+func TestExecutor_StepObserver(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "current"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	observer := &recordingObserver{}
+	executor := NewExecutor(tmpDir)
+	executor.Observer = observer
+
+	commands := []interface{}{
+		[]interface{}{"echo", "hello"},
+		[]interface{}{"sh", "-c", "echo failing; exit 3"},
+	}
+	if _, err := executor.RunPreDeployCommands(context.Background(), commands, 10); err == nil {
+		t.Fatal("Expected the second command to fail")
+	}
+
+	if len(observer.started) != 2 || observer.started[0] != "pre_deploy[0]" || observer.started[1] != "pre_deploy[1]" {
+		t.Errorf("Unexpected started steps: %v", observer.started)
+	}
+	if len(observer.finished) != 2 {
+		t.Fatalf("Expected 2 finished steps, got %d", len(observer.finished))
+	}
+
+	ok, failed := observer.finished[0], observer.finished[1]
+	if ok.ExitCode != 0 || ok.Error != "" || ok.Output != "hello\n" || ok.Command != "echo hello" {
+		t.Errorf("Unexpected successful step: %+v", ok)
+	}
+	if failed.ExitCode != 3 || failed.Error == "" || failed.Output != "failing\n" {
+		t.Errorf("Unexpected failed step: %+v", failed)
+	}
+
+	// Commands outside a step are not reported
+	if _, err := executor.RunCommand(context.Background(), []string{"echo", "quiet"}, 10, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(observer.started) != 2 || len(observer.finished) != 2 {
+		t.Errorf("Expected no steps outside step commands, got %v", observer.started)
+	}
+}
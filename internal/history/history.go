@@ -4,11 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// MaxStepOutputBytes is the most output stored per step. Longer output keeps
+// its end, where errors usually are.
+const MaxStepOutputBytes = 64 * 1024
+
 // History manages deployment history in SQLite
 type History struct {
 	db *sql.DB
@@ -54,13 +60,23 @@ func (h *History) initSchema() error {
 			completed_at TEXT,
 			duration_seconds REAL,
 			commit_hash TEXT,
-			error_message TEXT
+			error_message TEXT,
+			"trigger" TEXT NOT NULL DEFAULT 'webhook',
+			triggered_by TEXT
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
+	// Databases created by older versions lack the newer columns
+	if err := h.addMissingColumns("deployments", map[string]string{
+		"trigger":      `"trigger" TEXT NOT NULL DEFAULT 'webhook'`,
+		"triggered_by": "triggered_by TEXT",
+	}); err != nil {
+		return err
+	}
+
 	// Create index for efficient queries
 	_, err = h.db.Exec(`
 		CREATE INDEX IF NOT EXISTS idx_project_started
@@ -70,6 +86,71 @@ func (h *History) initSchema() error {
 		return fmt.Errorf("failed to create index: %w", err)
 	}
 
+	_, err = h.db.Exec(`
+		CREATE TABLE IF NOT EXISTS deployment_steps (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			deployment_id INTEGER NOT NULL REFERENCES deployments(id),
+			step TEXT NOT NULL,
+			command TEXT NOT NULL,
+			exit_code INTEGER NOT NULL,
+			started_at TEXT NOT NULL,
+			duration_seconds REAL NOT NULL,
+			output TEXT NOT NULL,
+			error_message TEXT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create steps table: %w", err)
+	}
+
+	_, err = h.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_steps_deployment
+		ON deployment_steps(deployment_id, id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create steps index: %w", err)
+	}
+
+	return h.initTokenSchema()
+}
+
+// addMissingColumns adds the given columns (name to definition) to a table
+// that does not have them yet
+func (h *History) addMissingColumns(table string, columns map[string]string) error {
+	rows, err := h.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan %s columns: %w", table, err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+		if _, err := h.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, columns[name])); err != nil {
+			return fmt.Errorf("failed to add column %s to %s: %w", name, table, err)
+		}
+	}
+
 	return nil
 }
 
@@ -77,29 +158,23 @@ func (h *History) initSchema() error {
 func (h *History) RecordDeployment(ctx context.Context, record *DeploymentRecord) (int64, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	var completedAt *string
-	if record.CompletedAt != nil {
-		formatted := record.CompletedAt.UTC().Format(time.RFC3339)
-		completedAt = &formatted
-	} else if record.Status != "in_progress" {
-		completedAt = &now
-	}
-
 	result, err := h.db.ExecContext(ctx, `
 		INSERT INTO deployments
 		(project, branch, ref, status, started_at, completed_at,
-		 duration_seconds, commit_hash, error_message)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 duration_seconds, commit_hash, error_message, "trigger", triggered_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		record.Project,
 		record.Branch,
 		record.Ref,
 		record.Status,
 		now,
-		completedAt,
+		completedAt(record),
 		record.DurationSeconds,
 		record.CommitHash,
 		record.ErrorMessage,
+		triggerOrDefault(record.Trigger),
+		record.TriggeredBy,
 	)
 
 	if err != nil {
@@ -114,11 +189,214 @@ func (h *History) RecordDeployment(ctx context.Context, record *DeploymentRecord
 	return id, nil
 }
 
+// triggerOrDefault returns the trigger to store, webhook if none is set
+func triggerOrDefault(trigger string) string {
+	if trigger == "" {
+		return "webhook"
+	}
+	return trigger
+}
+
+// completedAt returns the completion time to store for a record: its own, or
+// now once it is no longer in progress
+func completedAt(record *DeploymentRecord) *string {
+	if record.CompletedAt != nil {
+		formatted := record.CompletedAt.UTC().Format(time.RFC3339)
+		return &formatted
+	}
+	if record.Status == "in_progress" {
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	return &now
+}
+
+// UpdateDeployment updates the outcome of a recorded deployment (status,
+// completion time, duration, commit and error), typically an in_progress
+// record once the deployment finishes. A non-zero StartedAt replaces the
+// start time, for queued records once they start running.
+func (h *History) UpdateDeployment(ctx context.Context, record *DeploymentRecord) error {
+	var startedAt *string
+	if !record.StartedAt.IsZero() {
+		formatted := record.StartedAt.UTC().Format(time.RFC3339)
+		startedAt = &formatted
+	}
+
+	result, err := h.db.ExecContext(ctx, `
+		UPDATE deployments
+		SET status = ?, started_at = COALESCE(?, started_at), completed_at = ?,
+		    duration_seconds = ?, commit_hash = COALESCE(?, commit_hash), error_message = ?
+		WHERE id = ?
+	`,
+		record.Status,
+		startedAt,
+		completedAt(record),
+		record.DurationSeconds,
+		record.CommitHash,
+		record.ErrorMessage,
+		record.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update deployment record: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("deployment record %d not found", record.ID)
+	}
+
+	return nil
+}
+
+// AbortInProgressDeployments marks a project's in_progress deployments as
+// aborted with the given message. Used at startup for deployments that were
+// running when the server stopped.
+// Returns the number of deployments marked.
+func (h *History) AbortInProgressDeployments(ctx context.Context, project, message string) (int64, error) {
+	return h.abortDeployments(ctx, project, "in_progress", message)
+}
+
+// AbortQueuedDeployments marks a project's queued deployments as aborted with
+// the given message. Used at startup for pushes that were waiting when the
+// server stopped, since the queue does not outlive the process.
+// Returns the number of deployments marked.
+func (h *History) AbortQueuedDeployments(ctx context.Context, project, message string) (int64, error) {
+	return h.abortDeployments(ctx, project, "queued", message)
+}
+
+// abortDeployments marks a project's deployments with the given status as aborted
+func (h *History) abortDeployments(ctx context.Context, project, status, message string) (int64, error) {
+	result, err := h.db.ExecContext(ctx, `
+		UPDATE deployments
+		SET status = 'aborted', completed_at = ?, error_message = ?
+		WHERE project = ? AND status = ?
+	`, time.Now().UTC().Format(time.RFC3339), message, project, status)
+	if err != nil {
+		return 0, fmt.Errorf("failed to abort %s deployments: %w", status, err)
+	}
+
+	aborted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return aborted, nil
+}
+
+// RecordStep records a finished step of a deployment. Output longer than
+// MaxStepOutputBytes is cut down to its end.
+func (h *History) RecordStep(ctx context.Context, step *StepRecord) (int64, error) {
+	result, err := h.db.ExecContext(ctx, `
+		INSERT INTO deployment_steps
+		(deployment_id, step, command, exit_code, started_at,
+		 duration_seconds, output, error_message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		step.DeploymentID,
+		step.Step,
+		step.Command,
+		step.ExitCode,
+		step.StartedAt.UTC().Format(time.RFC3339Nano),
+		step.DurationSeconds,
+		truncateOutput(step.Output),
+		step.ErrorMessage,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert step record: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDeploymentSteps returns the recorded steps of a deployment in the order they ran
+func (h *History) GetDeploymentSteps(ctx context.Context, deploymentID int64) ([]StepRecord, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, deployment_id, step, command, exit_code, started_at,
+		       duration_seconds, output, error_message
+		FROM deployment_steps
+		WHERE deployment_id = ?
+		ORDER BY id
+	`, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployment steps: %w", err)
+	}
+	defer rows.Close()
+
+	steps := []StepRecord{}
+	for rows.Next() {
+		var step StepRecord
+		var startedAtStr string
+		if err := rows.Scan(
+			&step.ID,
+			&step.DeploymentID,
+			&step.Step,
+			&step.Command,
+			&step.ExitCode,
+			&startedAtStr,
+			&step.DurationSeconds,
+			&step.Output,
+			&step.ErrorMessage,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan step record: %w", err)
+		}
+
+		startedAt, err := time.Parse(time.RFC3339Nano, startedAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse step started_at timestamp: %w", err)
+		}
+		step.StartedAt = startedAt
+		steps = append(steps, step)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return steps, nil
+}
+
+// truncateOutput keeps the last MaxStepOutputBytes of output, noting how much was dropped
+func truncateOutput(output string) string {
+	if len(output) <= MaxStepOutputBytes {
+		return output
+	}
+	dropped := len(output) - MaxStepOutputBytes
+	return fmt.Sprintf("[... %d bytes truncated ...]\n", dropped) + strings.ToValidUTF8(output[dropped:], "")
+}
+
+// GetDeployment returns a deployment by ID, or nil if there is none
+func (h *History) GetDeployment(ctx context.Context, id int64) (*DeploymentRecord, error) {
+	row := h.db.QueryRowContext(ctx, `
+		SELECT id, project, branch, ref, status, started_at, completed_at,
+		       duration_seconds, commit_hash, error_message, "trigger", triggered_by
+		FROM deployments
+		WHERE id = ?
+	`, id)
+
+	record, err := scanDeploymentRecord(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployment: %w", err)
+	}
+
+	return record, nil
+}
+
 // GetLatestDeployment returns the most recent deployment for a project
 func (h *History) GetLatestDeployment(ctx context.Context, project string) (*DeploymentRecord, error) {
 	row := h.db.QueryRowContext(ctx, `
 		SELECT id, project, branch, ref, status, started_at, completed_at,
-		       duration_seconds, commit_hash, error_message
+		       duration_seconds, commit_hash, error_message, "trigger", triggered_by
 		FROM deployments
 		WHERE project = ?
 		ORDER BY id DESC
@@ -140,7 +418,7 @@ func (h *History) GetLatestDeployment(ctx context.Context, project string) (*Dep
 func (h *History) GetDeploymentHistory(ctx context.Context, project string, limit int) ([]DeploymentRecord, error) {
 	rows, err := h.db.QueryContext(ctx, `
 		SELECT id, project, branch, ref, status, started_at, completed_at,
-		       duration_seconds, commit_hash, error_message
+		       duration_seconds, commit_hash, error_message, "trigger", triggered_by
 		FROM deployments
 		WHERE project = ?
 		ORDER BY id DESC
@@ -171,7 +449,8 @@ func (h *History) GetDeploymentHistory(ctx context.Context, project string, limi
 func (h *History) GetAllProjectsStatus(ctx context.Context) (map[string]*DeploymentRecord, error) {
 	rows, err := h.db.QueryContext(ctx, `
 		SELECT d1.id, d1.project, d1.branch, d1.ref, d1.status, d1.started_at,
-		       d1.completed_at, d1.duration_seconds, d1.commit_hash, d1.error_message
+		       d1.completed_at, d1.duration_seconds, d1.commit_hash, d1.error_message,
+		       d1."trigger", d1.triggered_by
 		FROM deployments d1
 		INNER JOIN (
 			SELECT project, MAX(started_at) as max_started
@@ -224,6 +503,8 @@ func scanDeploymentRecord(s scanner) (*DeploymentRecord, error) {
 		&record.DurationSeconds,
 		&record.CommitHash,
 		&record.ErrorMessage,
+		&record.Trigger,
+		&record.TriggeredBy,
 	)
 
 	if err != nil {
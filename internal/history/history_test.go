@@ -2,7 +2,9 @@ package history
 
 import (
 	"context"
+	"database/sql"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -223,3 +225,280 @@ func TestHistory_GetAllProjectsStatus(t *testing.T) {
 		t.Errorf("Expected project2 status 'failed', got %q", status["project2"].Status)
 	}
 }
+
+func TestHistory_UpdateDeployment(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	commitHash := "abc123def456"
+	id, err := hist.RecordDeployment(ctx, &DeploymentRecord{
+		Project:    "test-project",
+		Branch:     "main",
+		Ref:        "refs/heads/main",
+		Status:     "in_progress",
+		CommitHash: &commitHash,
+	})
+	if err != nil {
+		t.Fatalf("Failed to record deployment: %v", err)
+	}
+
+	latest, err := hist.GetLatestDeployment(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to get latest deployment: %v", err)
+	}
+	if latest.Status != "in_progress" || latest.CompletedAt != nil {
+		t.Errorf("Expected in_progress without completed_at, got %s, %v", latest.Status, latest.CompletedAt)
+	}
+
+	duration := 3.5
+	errorMsg := "post_deploy failed"
+	if err := hist.UpdateDeployment(ctx, &DeploymentRecord{
+		ID:              id,
+		Status:          "failed",
+		DurationSeconds: &duration,
+		ErrorMessage:    &errorMsg,
+	}); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	latest, err = hist.GetLatestDeployment(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to get latest deployment: %v", err)
+	}
+	if latest.ID != id || latest.Status != "failed" {
+		t.Errorf("Expected record %d to be failed, got %d %s", id, latest.ID, latest.Status)
+	}
+	if latest.CompletedAt == nil {
+		t.Error("Expected completed_at to be set")
+	}
+	if latest.CommitHash == nil || *latest.CommitHash != commitHash {
+		t.Errorf("Expected commit hash to be kept, got %v", latest.CommitHash)
+	}
+	if latest.ErrorMessage == nil || *latest.ErrorMessage != errorMsg {
+		t.Errorf("Expected error message %q, got %v", errorMsg, latest.ErrorMessage)
+	}
+
+	if err := hist.UpdateDeployment(ctx, &DeploymentRecord{ID: id + 100, Status: "success"}); err == nil {
+		t.Error("Expected error updating unknown record")
+	}
+}
+
+func TestHistory_UpdateDeployment_StartedAt(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	id, err := hist.RecordDeployment(ctx, &DeploymentRecord{
+		Project: "test-project",
+		Branch:  "main",
+		Ref:     "refs/heads/main",
+		Status:  "queued",
+	})
+	if err != nil {
+		t.Fatalf("Failed to record deployment: %v", err)
+	}
+
+	// A queued record gets its start time when it starts running
+	startedAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := hist.UpdateDeployment(ctx, &DeploymentRecord{ID: id, Status: "in_progress", StartedAt: startedAt}); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	record, err := hist.GetDeployment(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if !record.StartedAt.Equal(startedAt) {
+		t.Errorf("Expected started_at %v, got %v", startedAt, record.StartedAt)
+	}
+
+	// Updates without a start time keep it
+	if err := hist.UpdateDeployment(ctx, &DeploymentRecord{ID: id, Status: "success"}); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	record, err = hist.GetDeployment(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if !record.StartedAt.Equal(startedAt) {
+		t.Errorf("Expected started_at %v to be kept, got %v", startedAt, record.StartedAt)
+	}
+}
+
+func TestHistory_AbortInProgressDeployments(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	for _, record := range []DeploymentRecord{
+		{Project: "project-a", Branch: "main", Ref: "refs/heads/main", Status: "success"},
+		{Project: "project-a", Branch: "main", Ref: "refs/heads/main", Status: "in_progress"},
+		{Project: "project-a", Branch: "main", Ref: "refs/heads/main", Status: "queued"},
+		{Project: "project-b", Branch: "main", Ref: "refs/heads/main", Status: "in_progress"},
+	} {
+		if _, err := hist.RecordDeployment(ctx, &record); err != nil {
+			t.Fatalf("Failed to record deployment: %v", err)
+		}
+	}
+
+	aborted, err := hist.AbortInProgressDeployments(ctx, "project-a", "interrupted")
+	if err != nil {
+		t.Fatalf("Failed to abort deployments: %v", err)
+	}
+	if aborted != 1 {
+		t.Errorf("Expected 1 aborted deployment, got %d", aborted)
+	}
+
+	// Queued deployments are aborted separately
+	latest, _ := hist.GetLatestDeployment(ctx, "project-a")
+	if latest.Status != "queued" {
+		t.Errorf("Expected the queued record to stay queued, got %s", latest.Status)
+	}
+
+	dropped, err := hist.AbortQueuedDeployments(ctx, "project-a", "dropped")
+	if err != nil {
+		t.Fatalf("Failed to abort queued deployments: %v", err)
+	}
+	if dropped != 1 {
+		t.Errorf("Expected 1 aborted queued deployment, got %d", dropped)
+	}
+
+	records, _ := hist.GetDeploymentHistory(ctx, "project-a", 10)
+	for i, message := range []string{"dropped", "interrupted"} {
+		record := records[i]
+		if record.Status != "aborted" || record.CompletedAt == nil || record.ErrorMessage == nil || *record.ErrorMessage != message {
+			t.Errorf("Expected aborted record with completed_at and error %q, got %+v", message, record)
+		}
+	}
+
+	// Other projects are left alone
+	latest, _ = hist.GetLatestDeployment(ctx, "project-b")
+	if latest.Status != "in_progress" {
+		t.Errorf("Expected project-b to stay in_progress, got %s", latest.Status)
+	}
+}
+
+func TestHistory_RecordStep(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	id, err := hist.RecordDeployment(ctx, &DeploymentRecord{
+		Project: "test-project", Branch: "main", Ref: "refs/heads/main", Status: "in_progress",
+	})
+	if err != nil {
+		t.Fatalf("Failed to record deployment: %v", err)
+	}
+
+	errorMsg := "exited with code 1"
+	longOutput := strings.Repeat("x", MaxStepOutputBytes) + "the end"
+	for _, step := range []StepRecord{
+		{DeploymentID: id, Step: "git_clone", Command: "git clone", StartedAt: time.Now(), DurationSeconds: 1.5, Output: "Cloning...\n"},
+		{DeploymentID: id, Step: "post_deploy[0]", Command: "make", ExitCode: 1, StartedAt: time.Now(), DurationSeconds: 0.2, Output: longOutput, ErrorMessage: &errorMsg},
+	} {
+		if _, err := hist.RecordStep(ctx, &step); err != nil {
+			t.Fatalf("Failed to record step: %v", err)
+		}
+	}
+
+	steps, err := hist.GetDeploymentSteps(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get steps: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %d", len(steps))
+	}
+	if steps[0].Step != "git_clone" || steps[0].Output != "Cloning...\n" || steps[0].ErrorMessage != nil {
+		t.Errorf("Unexpected first step: %+v", steps[0])
+	}
+
+	failed := steps[1]
+	if failed.ExitCode != 1 || failed.ErrorMessage == nil || *failed.ErrorMessage != errorMsg {
+		t.Errorf("Unexpected failed step: exit %d, error %v", failed.ExitCode, failed.ErrorMessage)
+	}
+	if len(failed.Output) > MaxStepOutputBytes+64 || !strings.HasSuffix(failed.Output, "the end") || !strings.HasPrefix(failed.Output, "[... ") {
+		t.Errorf("Expected output capped to its end, got %d bytes", len(failed.Output))
+	}
+
+	if steps, err := hist.GetDeploymentSteps(ctx, id+1); err != nil || len(steps) != 0 {
+		t.Errorf("Expected no steps for unknown deployment, got %v, %v", steps, err)
+	}
+}
+
+func TestHistory_Trigger(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Start from a database created before the trigger columns existed
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE deployments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project TEXT NOT NULL,
+			branch TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			status TEXT NOT NULL,
+			started_at TEXT NOT NULL,
+			completed_at TEXT,
+			duration_seconds REAL,
+			commit_hash TEXT,
+			error_message TEXT
+		);
+		INSERT INTO deployments (project, branch, ref, status, started_at)
+		VALUES ('test-project', 'main', 'refs/heads/main', 'success', '2024-12-09T10:00:00Z');
+	`); err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+	db.Close()
+
+	hist, err := NewHistory(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	tokenName := "ci"
+	id, err := hist.RecordDeployment(ctx, &DeploymentRecord{
+		Project:     "test-project",
+		Branch:      "hotfix",
+		Ref:         "refs/heads/hotfix",
+		Status:      "success",
+		Trigger:     "manual",
+		TriggeredBy: &tokenName,
+	})
+	if err != nil {
+		t.Fatalf("Failed to record deployment: %v", err)
+	}
+
+	record, err := hist.GetDeployment(ctx, id)
+	if err != nil || record == nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if record.Trigger != "manual" || record.TriggeredBy == nil || *record.TriggeredBy != tokenName {
+		t.Errorf("Expected manual deployment by %s, got %s/%v", tokenName, record.Trigger, record.TriggeredBy)
+	}
+
+	// Rows from before the migration count as webhook deployments
+	old, err := hist.GetDeployment(ctx, 1)
+	if err != nil || old == nil {
+		t.Fatalf("Failed to get old deployment: %v", err)
+	}
+	if old.Trigger != "webhook" || old.TriggeredBy != nil {
+		t.Errorf("Expected old row to be a webhook deployment, got %s/%v", old.Trigger, old.TriggeredBy)
+	}
+}
@@ -8,12 +8,28 @@ type DeploymentRecord struct {
 	Project         string
 	Branch          string
 	Ref             string
-	Status          string // success, failed, skipped, rejected, in_progress
+	Status          string // success, failed, skipped, rejected, queued, superseded, rolled_back, restored, in_progress, aborted
 	StartedAt       time.Time
 	CompletedAt     *time.Time // nullable
 	DurationSeconds *float64   // nullable
 	CommitHash      *string    // nullable
 	ErrorMessage    *string    // nullable
+	Trigger         string     // webhook (default), manual or restore
+	TriggeredBy     *string    // nullable; pusher, or admin token name for manual deployments
+}
+
+// StepRecord represents one step of a deployment (a command, the release
+// build or a shared files operation) in the database
+type StepRecord struct {
+	ID              int64
+	DeploymentID    int64
+	Step            string // e.g. git_clone, post_deploy[0]
+	Command         string
+	ExitCode        int
+	StartedAt       time.Time
+	DurationSeconds float64
+	Output          string  // Capped at MaxStepOutputBytes, keeping the end
+	ErrorMessage    *string // nullable
 }
 
 // DeploymentStatus represents the latest status of a project
@@ -22,3 +38,22 @@ type DeploymentStatus struct {
 	LatestDeployment *DeploymentRecord  `json:"latest_deployment,omitempty"`
 	RecentHistory    []DeploymentRecord `json:"recent_history"`
 }
+
+// APIToken represents an API token created with "deplobox tokens create".
+// Only the SHA-256 of the token is stored.
+type APIToken struct {
+	ID         int64
+	Name       string
+	TokenHash  string   `json:"-"`
+	Scopes     []string // read, deploy, rollback and/or admin
+	Projects   []string // Projects the token may act on, all if empty
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time // nullable; never expires if nil
+	LastUsedAt *time.Time // nullable
+	RevokedAt  *time.Time // nullable
+}
+
+// Active reports whether the token is neither revoked nor expired at now
+func (t *APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,219 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// initTokenSchema creates the API token table
+func (h *History) initTokenSchema() error {
+	_, err := h.db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL,
+			projects TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			expires_at TEXT,
+			last_used_at TEXT,
+			revoked_at TEXT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tokens table: %w", err)
+	}
+
+	// Names identify tokens in the history, so live tokens must not share one
+	_, err = h.db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_tokens_active_name
+		ON api_tokens(name) WHERE revoked_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tokens index: %w", err)
+	}
+
+	return nil
+}
+
+// CreateToken stores a new API token. The name must not belong to another
+// token that has not been revoked.
+func (h *History) CreateToken(ctx context.Context, token *APIToken) (int64, error) {
+	if existing, err := h.getToken(ctx, "name = ? AND revoked_at IS NULL", token.Name); err != nil {
+		return 0, err
+	} else if existing != nil {
+		return 0, fmt.Errorf("a token named '%s' already exists", token.Name)
+	}
+
+	createdAt := token.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	result, err := h.db.ExecContext(ctx, `
+		INSERT INTO api_tokens (name, token_hash, scopes, projects, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`,
+		token.Name,
+		token.TokenHash,
+		strings.Join(token.Scopes, ","),
+		strings.Join(token.Projects, ","),
+		createdAt.UTC().Format(time.RFC3339),
+		formatOptionalTime(token.ExpiresAt),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetTokenByHash returns the token with the given hash, revoked or not, or
+// nil if there is none
+func (h *History) GetTokenByHash(ctx context.Context, hash string) (*APIToken, error) {
+	return h.getToken(ctx, "token_hash = ?", hash)
+}
+
+// ListTokens returns all tokens, including revoked ones, oldest first
+func (h *History) ListTokens(ctx context.Context) ([]APIToken, error) {
+	rows, err := h.db.QueryContext(ctx, tokenSelect+" ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeToken revokes the live token with the given name
+func (h *History) RevokeToken(ctx context.Context, name string) error {
+	result, err := h.db.ExecContext(ctx, `
+		UPDATE api_tokens SET revoked_at = ?
+		WHERE name = ? AND revoked_at IS NULL
+	`, time.Now().UTC().Format(time.RFC3339), name)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no active token named '%s'", name)
+	}
+
+	return nil
+}
+
+// TouchToken records that a token was used at the given time
+func (h *History) TouchToken(ctx context.Context, id int64, usedAt time.Time) error {
+	if _, err := h.db.ExecContext(ctx, `
+		UPDATE api_tokens SET last_used_at = ? WHERE id = ?
+	`, usedAt.UTC().Format(time.RFC3339), id); err != nil {
+		return fmt.Errorf("failed to update token last use: %w", err)
+	}
+	return nil
+}
+
+const tokenSelect = `
+	SELECT id, name, token_hash, scopes, projects, created_at,
+	       expires_at, last_used_at, revoked_at
+	FROM api_tokens`
+
+// getToken returns the first token matching the WHERE clause, or nil
+func (h *History) getToken(ctx context.Context, where string, args ...any) (*APIToken, error) {
+	row := h.db.QueryRowContext(ctx, tokenSelect+" WHERE "+where+" LIMIT 1", args...)
+
+	token, err := scanToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	return token, nil
+}
+
+func scanToken(s scanner) (*APIToken, error) {
+	var token APIToken
+	var scopes, projects, createdAt string
+	var expiresAt, lastUsedAt, revokedAt sql.NullString
+
+	if err := s.Scan(
+		&token.ID,
+		&token.Name,
+		&token.TokenHash,
+		&scopes,
+		&projects,
+		&createdAt,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	token.Scopes = splitList(scopes)
+	token.Projects = splitList(projects)
+
+	var err error
+	if token.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+	for _, field := range []struct {
+		value sql.NullString
+		dest  **time.Time
+	}{
+		{expiresAt, &token.ExpiresAt},
+		{lastUsedAt, &token.LastUsedAt},
+		{revokedAt, &token.RevokedAt},
+	} {
+		if !field.value.Valid {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, field.value.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse token timestamp: %w", err)
+		}
+		*field.dest = &t
+	}
+
+	return &token, nil
+}
+
+// splitList splits a comma-separated column, returning nil for an empty one
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// formatOptionalTime formats a nullable timestamp for storage
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
@@ -0,0 +1,94 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory_Tokens(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	id, err := hist.CreateToken(ctx, &APIToken{
+		Name:      "ci",
+		TokenHash: "hash-1",
+		Scopes:    []string{"read", "deploy"},
+		Projects:  []string{"myapp"},
+		ExpiresAt: &expires,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	if _, err := hist.CreateToken(ctx, &APIToken{Name: "ci", TokenHash: "hash-2", Scopes: []string{"read"}}); err == nil {
+		t.Error("Expected error creating a second live token named ci")
+	}
+
+	token, err := hist.GetTokenByHash(ctx, "hash-1")
+	if err != nil || token == nil {
+		t.Fatalf("Failed to get token: %v", err)
+	}
+	if token.ID != id || len(token.Scopes) != 2 || len(token.Projects) != 1 || token.Projects[0] != "myapp" {
+		t.Errorf("Unexpected token %+v", token)
+	}
+	if token.ExpiresAt == nil || !token.ExpiresAt.Equal(expires) {
+		t.Errorf("Expected expiry %v, got %v", expires, token.ExpiresAt)
+	}
+	if !token.Active(time.Now()) || token.Active(expires.Add(time.Second)) {
+		t.Error("Expected token to be active until it expires")
+	}
+
+	usedAt := time.Now().Truncate(time.Second)
+	if err := hist.TouchToken(ctx, id, usedAt); err != nil {
+		t.Fatalf("Failed to touch token: %v", err)
+	}
+	if err := hist.RevokeToken(ctx, "ci"); err != nil {
+		t.Fatalf("Failed to revoke token: %v", err)
+	}
+	if err := hist.RevokeToken(ctx, "ci"); err == nil {
+		t.Error("Expected error revoking an already revoked token")
+	}
+
+	// The name is free again once revoked
+	if _, err := hist.CreateToken(ctx, &APIToken{Name: "ci", TokenHash: "hash-3", Scopes: []string{"admin"}}); err != nil {
+		t.Errorf("Failed to reuse revoked token name: %v", err)
+	}
+
+	tokens, err := hist.ListTokens(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list tokens: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("Expected 2 tokens, got %d", len(tokens))
+	}
+	revoked := tokens[0]
+	if revoked.RevokedAt == nil || revoked.Active(time.Now()) {
+		t.Error("Expected first token to be revoked")
+	}
+	if revoked.LastUsedAt == nil || !revoked.LastUsedAt.Equal(usedAt) {
+		t.Errorf("Expected last use %v, got %v", usedAt, revoked.LastUsedAt)
+	}
+	if tokens[1].Projects != nil || tokens[1].ExpiresAt != nil {
+		t.Errorf("Expected unrestricted token without expiry, got %+v", tokens[1])
+	}
+}
+
+func TestHistory_GetTokenByHash_Unknown(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	token, err := hist.GetTokenByHash(context.Background(), "missing")
+	if err != nil || token != nil {
+		t.Errorf("Expected nil token without error, got %v, %v", token, err)
+	}
+}
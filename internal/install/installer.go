@@ -107,6 +107,7 @@ func (i *Installer) printSuccessSummary() {
 	fmt.Println("Next Steps:")
 	fmt.Printf("  1. Test webhook: Push to %s\n", c.OwnerRepo)
 	fmt.Printf("  2. Check logs: journalctl -u deplobox -f\n")
-	fmt.Printf("  3. View status: curl %s/status/%s\n", c.WebhookURL, c.ProjectName)
+	fmt.Printf("  3. Create an API token: deplobox tokens create %s-read --project %s\n", c.ProjectName, c.ProjectName)
+	fmt.Printf("  4. View status: curl -H \"Authorization: Bearer <token>\" %s/status/%s\n", c.WebhookURL, c.ProjectName)
 	fmt.Println()
 }
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	registry := NewRegistry()
+
+	deployments := registry.NewCounter("test_deployments_total", "Deployments by project and status.", "project", "status")
+	deployments.Inc("web", "success")
+	deployments.Inc("web", "success")
+	deployments.Inc("api", "failed")
+
+	registry.NewCounter("test_failures_total", "Failures.")
+	registry.NewGaugeFunc("test_in_flight", "In-flight work.", func() float64 { return 2 })
+
+	durations := registry.NewHistogram("test_duration_seconds", "Durations.", []float64{1, 5}, "step")
+	durations.Observe(0.5, "clone")
+	durations.Observe(3, "clone")
+	durations.Observe(10, "clone")
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+
+	expected := `# HELP test_deployments_total Deployments by project and status.
+# TYPE test_deployments_total counter
+test_deployments_total{project="api",status="failed"} 1
+test_deployments_total{project="web",status="success"} 2
+# HELP test_failures_total Failures.
+# TYPE test_failures_total counter
+test_failures_total 0
+# HELP test_in_flight In-flight work.
+# TYPE test_in_flight gauge
+test_in_flight 2
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{step="clone",le="1"} 1
+test_duration_seconds_bucket{step="clone",le="5"} 2
+test_duration_seconds_bucket{step="clone",le="+Inf"} 3
+test_duration_seconds_sum{step="clone"} 13.5
+test_duration_seconds_count{step="clone"} 3
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), expected)
+	}
+
+	if got := deployments.Value("web", "success"); got != 2 {
+		t.Errorf("Value = %v, want 2", got)
+	}
+	if got := durations.Count("other"); got != 0 {
+		t.Errorf("Count of unobserved series = %d, want 0", got)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabelValue = %q", got)
+	}
+}
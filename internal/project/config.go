@@ -2,9 +2,17 @@ package project
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"deplobox/internal/security"
+	"deplobox/pkg/cmdutil"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,10 +20,22 @@ import (
 const (
 	MinSecretLength            = 32
 	DefaultPullTimeout         = 60
+	DefaultPreDeployTimeout    = 300
 	DefaultPostDeployTimeout   = 300
 	DefaultPostActivateTimeout = 300
+	DefaultKeepReleases        = 5
+
+	DefaultHealthCheckStatus   = 200
+	DefaultHealthCheckRetries  = 3
+	DefaultHealthCheckInterval = 2
+	DefaultHealthCheckTimeout  = 10
+
+	DefaultGitHubEnvironment = "production"
 )
 
+// githubRepoPattern matches a GitHub owner/repo
+var githubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
 var ForbiddenSecrets = map[string]bool{
 	"replace-with-secret":     true,
 	"github-webhook-password": true,
@@ -42,6 +62,17 @@ func LoadConfig(configPath string) (*Config, map[string]*Project, error) {
 		config.Projects = make(map[string]ProjectConfig)
 	}
 
+	if errors := ValidateAdminTokens(config.AdminTokens, config.Projects); len(errors) > 0 {
+		return nil, nil, fmt.Errorf("invalid admin_tokens configuration:\n%s", strings.Join(errors, "\n"))
+	}
+
+	// Tokens without scopes are full admin tokens
+	for i := range config.AdminTokens {
+		if len(config.AdminTokens[i].Scopes) == 0 {
+			config.AdminTokens[i].Scopes = []string{security.ScopeAdmin}
+		}
+	}
+
 	// Validate and create Project instances
 	projects := make(map[string]*Project)
 	for name, projectConfig := range config.Projects {
@@ -57,11 +88,26 @@ func LoadConfig(configPath string) (*Config, map[string]*Project, error) {
 			branch = "main"
 		}
 
+		provider := projectConfig.Provider
+		if provider == "" {
+			provider = ProviderGitHub
+		}
+
 		pullTimeout := projectConfig.PullTimeout
 		if pullTimeout == 0 {
 			pullTimeout = DefaultPullTimeout
 		}
 
+		preDeployTimeout := projectConfig.PreDeployTimeout
+		if preDeployTimeout == 0 {
+			preDeployTimeout = DefaultPreDeployTimeout
+		}
+
+		preDeploy := projectConfig.PreDeploy
+		if preDeploy == nil {
+			preDeploy = []interface{}{}
+		}
+
 		postDeployTimeout := projectConfig.PostDeployTimeout
 		if postDeployTimeout == 0 {
 			postDeployTimeout = DefaultPostDeployTimeout
@@ -82,6 +128,71 @@ func LoadConfig(configPath string) (*Config, map[string]*Project, error) {
 			postActivate = []interface{}{}
 		}
 
+		// Copying shared/ stays the default unless shared paths are linked
+		copyFiles := len(projectConfig.LinkedFiles) == 0 && len(projectConfig.LinkedDirs) == 0
+		if projectConfig.CopyFiles != nil {
+			copyFiles = *projectConfig.CopyFiles
+		}
+
+		onRollback := projectConfig.OnRollback
+		if onRollback == nil {
+			onRollback = []interface{}{}
+		}
+
+		onSuccess := projectConfig.OnSuccess
+		if onSuccess == nil {
+			onSuccess = []interface{}{}
+		}
+
+		onFailure := projectConfig.OnFailure
+		if onFailure == nil {
+			onFailure = []interface{}{}
+		}
+
+		keepReleases := projectConfig.KeepReleases
+		if keepReleases == 0 {
+			keepReleases = DefaultKeepReleases
+		}
+
+		// Already checked by ValidateProjectConfig
+		maxReleaseAge, _ := ParseAge(projectConfig.MaxReleaseAge)
+		maxReleasesSize, _ := parseSize(projectConfig.MaxReleasesSize)
+
+		var healthCheck *HealthCheck
+		if projectConfig.HealthCheck != nil {
+			hcConfig := projectConfig.HealthCheck
+			hc := HealthCheck{
+				URL:            hcConfig.URL,
+				Command:        hcConfig.Command,
+				ExpectedStatus: hcConfig.ExpectedStatus,
+				Retries:        DefaultHealthCheckRetries,
+				Interval:       hcConfig.Interval,
+				Timeout:        hcConfig.Timeout,
+			}
+			if hc.ExpectedStatus == 0 {
+				hc.ExpectedStatus = DefaultHealthCheckStatus
+			}
+			if hcConfig.Retries != nil {
+				hc.Retries = *hcConfig.Retries
+			}
+			if hc.Interval == 0 {
+				hc.Interval = DefaultHealthCheckInterval
+			}
+			if hc.Timeout == 0 {
+				hc.Timeout = DefaultHealthCheckTimeout
+			}
+			healthCheck = &hc
+		}
+
+		var githubReporter *GitHubReporter
+		if projectConfig.GitHubReporter != nil {
+			gr := *projectConfig.GitHubReporter
+			if gr.Environment == "" {
+				gr.Environment = DefaultGitHubEnvironment
+			}
+			githubReporter = &gr
+		}
+
 		// Resolve path to absolute
 		resolvedPath, err := filepath.Abs(projectConfig.Path)
 		if err != nil {
@@ -98,18 +209,74 @@ func LoadConfig(configPath string) (*Config, map[string]*Project, error) {
 			Name:                name,
 			Path:                realPath,
 			Secret:              projectConfig.Secret,
+			Provider:            provider,
 			Branch:              branch,
 			PullTimeout:         pullTimeout,
+			PreDeployTimeout:    preDeployTimeout,
+			PreDeploy:           preDeploy,
+			ExcludeGitDir:       projectConfig.ExcludeGitDir,
+			CopyFiles:           copyFiles,
+			LinkedFiles:         projectConfig.LinkedFiles,
+			LinkedDirs:          projectConfig.LinkedDirs,
 			PostDeployTimeout:   postDeployTimeout,
 			PostDeploy:          postDeploy,
 			PostActivateTimeout: postActivateTimeout,
 			PostActivate:        postActivate,
+			HealthCheck:         healthCheck,
+			Shell:               projectConfig.Shell,
+			Env:                 projectConfig.Env,
+			EnvFile:             resolveEnvFile(realPath, projectConfig.EnvFile),
+			CleanEnv:            projectConfig.CleanEnv,
+			Warnings:            ProjectConfigWarnings(name, projectConfig),
+			RollbackOnFailure:   projectConfig.RollbackOnFailure,
+			OnRollback:          onRollback,
+			OnSuccess:           onSuccess,
+			OnFailure:           onFailure,
+			KeepReleases:        keepReleases,
+			MaxReleaseAge:       maxReleaseAge,
+			MaxReleasesSize:     maxReleasesSize,
+			KeepFailed:          projectConfig.KeepFailed,
+			GitHubReporter:      githubReporter,
 		}
 	}
 
 	return &config, projects, nil
 }
 
+// ValidateAdminTokens validates the admin API tokens against the configured
+// projects
+func ValidateAdminTokens(tokens []AdminToken, projects map[string]ProjectConfig) []string {
+	var errors []string
+	seen := make(map[string]bool)
+
+	for i, token := range tokens {
+		if token.Name == "" {
+			errors = append(errors, fmt.Sprintf("  - Admin token %d: name is required", i))
+			continue
+		}
+		if seen[token.Name] {
+			errors = append(errors, fmt.Sprintf("  - Admin token '%s': duplicate name", token.Name))
+		}
+		seen[token.Name] = true
+
+		if !security.ValidTokenHash(token.SHA256) {
+			errors = append(errors, fmt.Sprintf("  - Admin token '%s': sha256 must be the 64 character hex SHA-256 of the token", token.Name))
+		}
+		for _, scope := range token.Scopes {
+			if !security.ValidScope(scope) {
+				errors = append(errors, fmt.Sprintf("  - Admin token '%s': unknown scope '%s' (must be one of: %s)", token.Name, scope, strings.Join(security.Scopes, ", ")))
+			}
+		}
+		for _, name := range token.Projects {
+			if _, ok := projects[name]; !ok {
+				errors = append(errors, fmt.Sprintf("  - Admin token '%s': unknown project '%s'", token.Name, name))
+			}
+		}
+	}
+
+	return errors
+}
+
 // ValidateProjectConfig validates a single project configuration
 func ValidateProjectConfig(name string, config ProjectConfig) []string {
 	var errors []string
@@ -158,9 +325,15 @@ func ValidateProjectConfig(name string, config ProjectConfig) []string {
 					if err != nil {
 						errors = append(errors, fmt.Sprintf("  - Project '%s': 'current' symlink is broken: %v", name, err))
 					} else {
+						// Releases built with exclude_git_dir have no .git; the remote then comes from the repo cache
 						gitDir := filepath.Join(currentPath, ".git")
+						repoHead := filepath.Join(realPath, "repo", "HEAD")
 						if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-							errors = append(errors, fmt.Sprintf("  - Project '%s': current release is not a git repository (missing .git): '%s'", name, currentPath))
+							if !config.ExcludeGitDir {
+								errors = append(errors, fmt.Sprintf("  - Project '%s': current release is not a git repository (missing .git): '%s'", name, currentPath))
+							} else if _, err := os.Stat(repoHead); os.IsNotExist(err) {
+								errors = append(errors, fmt.Sprintf("  - Project '%s': exclude_git_dir requires a 'repo' cache when current release has no .git: '%s'", name, realPath))
+							}
 						}
 					}
 				}
@@ -203,12 +376,22 @@ func ValidateProjectConfig(name string, config ProjectConfig) []string {
 		}
 	}
 
+	// Validate webhook provider (empty uses default)
+	if config.Provider != "" && !slices.Contains(SupportedProviders, config.Provider) {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': provider must be one of %s, got '%s'", name, strings.Join(SupportedProviders, ", "), config.Provider))
+	}
+
 	// Validate timeouts (must be positive if set, zero uses defaults)
 	pullTimeout := config.PullTimeout
 	if pullTimeout < 0 {
 		errors = append(errors, fmt.Sprintf("  - Project '%s': pull_timeout must be a positive integer, got %d", name, pullTimeout))
 	}
 
+	preDeployTimeout := config.PreDeployTimeout
+	if preDeployTimeout < 0 {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': pre_deploy_timeout must be a positive integer, got %d", name, preDeployTimeout))
+	}
+
 	postDeployTimeout := config.PostDeployTimeout
 	if postDeployTimeout < 0 {
 		errors = append(errors, fmt.Sprintf("  - Project '%s': post_deploy_timeout must be a positive integer, got %d", name, postDeployTimeout))
@@ -219,6 +402,17 @@ func ValidateProjectConfig(name string, config ProjectConfig) []string {
 		errors = append(errors, fmt.Sprintf("  - Project '%s': post_activate_timeout must be a positive integer, got %d", name, postActivateTimeout))
 	}
 
+	// Validate release retention (zero uses defaults)
+	if config.KeepReleases < 0 {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': keep_releases must be a positive integer, got %d", name, config.KeepReleases))
+	}
+	if _, err := ParseAge(config.MaxReleaseAge); err != nil {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': max_release_age %v", name, err))
+	}
+	if _, err := parseSize(config.MaxReleasesSize); err != nil {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': max_releases_size %v", name, err))
+	}
+
 	// Validate branch
 	branch := config.Branch
 	if branch == "" {
@@ -228,34 +422,247 @@ func ValidateProjectConfig(name string, config ProjectConfig) []string {
 		errors = append(errors, fmt.Sprintf("  - Project '%s': branch name cannot start with '-', got '%s'", name, branch))
 	}
 
-	// Validate post_deploy commands
-	if config.PostDeploy != nil {
-		for i, cmd := range config.PostDeploy {
-			switch cmd.(type) {
-			case string:
-				// Valid
-			case []interface{}:
-				// Valid - list of commands
-			default:
-				errors = append(errors, fmt.Sprintf("  - Project '%s': post_deploy[%d] must be a string or list, got %T", name, i, cmd))
+	// Validate linked shared paths
+	errors = append(errors, validateLinkedPaths(name, "linked_files", config.LinkedFiles)...)
+	errors = append(errors, validateLinkedPaths(name, "linked_dirs", config.LinkedDirs)...)
+
+	// Validate hook environment
+	for key := range config.Env {
+		if !cmdutil.ValidEnvKey(key) {
+			errors = append(errors, fmt.Sprintf("  - Project '%s': env has invalid variable name '%s'", name, key))
+		} else if strings.HasPrefix(key, "DEPLOBOX_") {
+			errors = append(errors, fmt.Sprintf("  - Project '%s': env variable '%s' uses the reserved DEPLOBOX_ prefix", name, key))
+		}
+	}
+
+	if config.EnvFile != "" && config.Path != "" && filepath.IsAbs(config.Path) {
+		envFile := resolveEnvFile(config.Path, config.EnvFile)
+		if info, err := os.Stat(envFile); err != nil {
+			errors = append(errors, fmt.Sprintf("  - Project '%s': env_file not found: '%s'", name, envFile))
+		} else if info.IsDir() {
+			errors = append(errors, fmt.Sprintf("  - Project '%s': env_file is a directory: '%s'", name, envFile))
+		}
+	}
+
+	// Validate hook command lists
+	errors = append(errors, validateCommandList(name, "pre_deploy", config.PreDeploy)...)
+	errors = append(errors, validateCommandList(name, "post_deploy", config.PostDeploy)...)
+	errors = append(errors, validateCommandList(name, "post_activate", config.PostActivate)...)
+	errors = append(errors, validateCommandList(name, "on_rollback", config.OnRollback)...)
+	errors = append(errors, validateCommandList(name, "on_success", config.OnSuccess)...)
+	errors = append(errors, validateCommandList(name, "on_failure", config.OnFailure)...)
+
+	// Validate healthcheck
+	if config.HealthCheck != nil {
+		errors = append(errors, validateHealthCheck(name, config.HealthCheck)...)
+	}
+	if config.GitHubReporter != nil {
+		errors = append(errors, validateGitHubReporter(name, config.GitHubReporter)...)
+	}
+
+	return errors
+}
+
+// validateLinkedPaths checks linked paths are relative and stay inside the release
+func validateLinkedPaths(name, field string, paths []string) []string {
+	var errors []string
+
+	for i, path := range paths {
+		if !filepath.IsLocal(path) || filepath.Clean(path) == "." || path == ".git" || strings.HasPrefix(path, ".git/") {
+			errors = append(errors, fmt.Sprintf("  - Project '%s': %s[%d] must be a relative path inside the release, got '%s'", name, field, i, path))
+		}
+	}
+
+	return errors
+}
+
+// ParseAge parses an age such as "30d" or "72h". Empty means no limit.
+func ParseAge(age string) (time.Duration, error) {
+	if age == "" {
+		return 0, nil
+	}
+
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(age, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(age)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("must be a positive duration such as '30d' or '72h', got '%s'", age)
+	}
+
+	return d, nil
+}
+
+// sizeUnits maps size suffixes to their multiplier in bytes
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a disk size such as "500MB" or "2GB" (binary units).
+// Empty means no limit.
+func parseSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if n, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(n), unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("must be a positive size such as '500MB' or '2GB', got '%s'", size)
+	}
+
+	return n * multiplier, nil
+}
+
+// resolveEnvFile resolves an env_file setting relative to the project root
+func resolveEnvFile(projectPath, envFile string) string {
+	if envFile == "" || filepath.IsAbs(envFile) {
+		return envFile
+	}
+	return filepath.Join(projectPath, envFile)
+}
+
+// ProjectConfigWarnings returns non-fatal issues in a project configuration,
+// such as shell operators in commands that do not run through a shell
+func ProjectConfigWarnings(name string, config ProjectConfig) []string {
+	var warnings []string
+
+	lists := []struct {
+		field    string
+		commands []interface{}
+	}{
+		{"pre_deploy", config.PreDeploy},
+		{"post_deploy", config.PostDeploy},
+		{"post_activate", config.PostActivate},
+		{"on_rollback", config.OnRollback},
+		{"on_success", config.OnSuccess},
+		{"on_failure", config.OnFailure},
+	}
+
+	for _, list := range lists {
+		for i, cmd := range list.commands {
+			spec, err := cmdutil.ParseCommandSpecWithShell(cmd, config.Shell)
+			if err != nil || spec.Shell {
+				continue
+			}
+
+			// Only string commands are split like a shell would; lists are explicit argv
+			cmdStr, ok := cmd.(string)
+			if m, isMap := cmd.(map[string]interface{}); isMap {
+				cmdStr, ok = m["command"].(string)
+			}
+			if !ok {
+				continue
+			}
+
+			if ops := cmdutil.FindShellOperators(cmdStr); len(ops) > 0 {
+				warnings = append(warnings, fmt.Sprintf("  - Project '%s': %s[%d] contains shell operator(s) %s but does not run through a shell; they are passed as literal arguments (set 'shell: true' to use a shell)", name, list.field, i, strings.Join(ops, " ")))
 			}
 		}
 	}
 
-	// Validate post_activate commands
-	if config.PostActivate != nil {
-		for i, cmd := range config.PostActivate {
-			switch cmd.(type) {
-			case string:
-				// Valid
-			case []interface{}:
-				// Valid - list of commands
-			default:
-				errors = append(errors, fmt.Sprintf("  - Project '%s': post_activate[%d] must be a string or list, got %T", name, i, cmd))
+	return warnings
+}
+
+// validateCommandList checks every entry of a command list is a string, a list or a command map
+func validateCommandList(name, field string, commands []interface{}) []string {
+	var errors []string
+
+	for i, cmd := range commands {
+		switch cmd.(type) {
+		case string:
+			// Valid
+		case []interface{}:
+			// Valid - list of commands
+		case map[string]interface{}:
+			// Structured command with per-command options
+			if _, err := cmdutil.ParseCommandSpec(cmd); err != nil {
+				errors = append(errors, fmt.Sprintf("  - Project '%s': %s[%d] is invalid: %v", name, field, i, err))
 			}
+		default:
+			errors = append(errors, fmt.Sprintf("  - Project '%s': %s[%d] must be a string or list, got %T", name, field, i, cmd))
+		}
+	}
+
+	return errors
+}
+
+// validateHealthCheck validates a project's healthcheck block
+func validateHealthCheck(name string, hc *HealthCheckConfig) []string {
+	var errors []string
+
+	hasURL := hc.URL != ""
+	hasCommand := hc.Command != nil
+	if hasURL == hasCommand {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck must set exactly one of 'url' or 'command'", name))
+	}
+
+	if hasURL && !strings.HasPrefix(hc.URL, "http://") && !strings.HasPrefix(hc.URL, "https://") {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck url must start with http:// or https://, got '%s'", name, hc.URL))
+	}
+
+	if hasCommand {
+		switch hc.Command.(type) {
+		case string, []interface{}:
+			// Valid
+		default:
+			errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck command must be a string or list, got %T", name, hc.Command))
 		}
 	}
 
+	if hc.ExpectedStatus != 0 && (hc.ExpectedStatus < 100 || hc.ExpectedStatus > 599) {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck expected_status must be a valid HTTP status, got %d", name, hc.ExpectedStatus))
+	}
+	if hc.Retries != nil && *hc.Retries < 0 {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck retries must be zero or a positive integer, got %d", name, *hc.Retries))
+	}
+	if hc.Interval < 0 {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck interval must be a positive integer, got %d", name, hc.Interval))
+	}
+	if hc.Timeout < 0 {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': healthcheck timeout must be a positive integer, got %d", name, hc.Timeout))
+	}
+
+	return errors
+}
+
+// validateGitHubReporter validates a project's github_reporter block
+func validateGitHubReporter(name string, gr *GitHubReporter) []string {
+	var errors []string
+
+	if !githubRepoPattern.MatchString(gr.Repository) || strings.HasSuffix(gr.Repository, "/.") || strings.HasSuffix(gr.Repository, "/..") {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': github_reporter repository must be 'owner/repo', got '%s'", name, gr.Repository))
+	}
+	if gr.Token == "" {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': github_reporter is missing required 'token' field", name))
+	}
+	if gr.BaseURL != "" && !strings.HasPrefix(gr.BaseURL, "http://") && !strings.HasPrefix(gr.BaseURL, "https://") {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': github_reporter base_url must start with http:// or https://, got '%s'", name, gr.BaseURL))
+	}
+	if gr.EnvironmentURL != "" && !strings.HasPrefix(gr.EnvironmentURL, "http://") && !strings.HasPrefix(gr.EnvironmentURL, "https://") {
+		errors = append(errors, fmt.Sprintf("  - Project '%s': github_reporter environment_url must start with http:// or https://, got '%s'", name, gr.EnvironmentURL))
+	}
+
 	return errors
 }
 
@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"deplobox/internal/security"
 )
 
 func TestValidateProjectConfig_ValidConfig(t *testing.T) {
@@ -302,3 +305,473 @@ func TestProjectMatchesRef(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateProjectConfig_InvalidProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	config := ProjectConfig{
+		Path:     tmpDir,
+		Secret:   "valid-secret-with-at-least-32-chars-here",
+		Provider: "bitbucket-server",
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	found := false
+	for _, err := range errors {
+		if strings.Contains(err, "provider must be") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("Expected provider validation error, got: %v", errors)
+	}
+}
+
+func TestValidateProjectConfig_ExcludeGitDir(t *testing.T) {
+	// Current release exported without .git
+	tmpDir := t.TempDir()
+	release1 := filepath.Join(tmpDir, "releases", "2024-01-01-00-00-00")
+	if err := os.MkdirAll(release1, 0755); err != nil {
+		t.Fatalf("Failed to create release directory: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared directory: %v", err)
+	}
+	if err := os.Symlink(release1, filepath.Join(tmpDir, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	config := ProjectConfig{
+		Path:          tmpDir,
+		Secret:        "valid-secret-with-at-least-32-chars-here",
+		ExcludeGitDir: true,
+	}
+
+	// Without a repo cache there is nowhere to read the remote from
+	errors := ValidateProjectConfig("test-project", config)
+	found := false
+	for _, err := range errors {
+		if strings.Contains(err, "exclude_git_dir requires a 'repo' cache") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("Expected missing repo cache error, got: %v", errors)
+	}
+
+	// With a bare repo cache the config is valid
+	repoDir := filepath.Join(tmpDir, "repo")
+	if err := os.Mkdir(repoDir, 0755); err != nil {
+		t.Fatalf("Failed to create repo directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "HEAD"), []byte("ref: refs/heads/main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create repo HEAD: %v", err)
+	}
+
+	if errors := ValidateProjectConfig("test-project", config); len(errors) > 0 {
+		t.Errorf("Expected exclude_git_dir with repo cache to pass validation, got errors: %v", errors)
+	}
+
+	// Without exclude_git_dir a missing .git is still an error
+	config.ExcludeGitDir = false
+	if errors := ValidateProjectConfig("test-project", config); len(errors) == 0 {
+		t.Error("Expected missing .git error when exclude_git_dir is not set")
+	}
+}
+
+func TestValidateProjectConfig_HealthCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		healthCheck *HealthCheckConfig
+		expected    string
+	}{
+		{"neither url nor command", &HealthCheckConfig{}, "exactly one of 'url' or 'command'"},
+		{"both url and command", &HealthCheckConfig{URL: "http://localhost/health", Command: "true"}, "exactly one of 'url' or 'command'"},
+		{"non-http url", &HealthCheckConfig{URL: "ftp://localhost/health"}, "must start with http:// or https://"},
+		{"invalid status", &HealthCheckConfig{URL: "http://localhost/health", ExpectedStatus: 42}, "expected_status must be a valid HTTP status"},
+		{"negative retries", &HealthCheckConfig{URL: "http://localhost/health", Retries: intPtr(-1)}, "retries must be zero or a positive integer"},
+		{"invalid command type", &HealthCheckConfig{Command: 42}, "command must be a string or list"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := ProjectConfig{
+				Path:        t.TempDir(),
+				Secret:      "valid-secret-with-at-least-32-chars-here",
+				HealthCheck: tc.healthCheck,
+			}
+
+			errors := ValidateProjectConfig("test-project", config)
+			found := false
+			for _, err := range errors {
+				if strings.Contains(err, tc.expected) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("Expected error containing %q, got: %v", tc.expected, errors)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_HealthCheckRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+	var yamlConfig strings.Builder
+	yamlConfig.WriteString("projects:\n")
+	for name, retries := range map[string]string{"default": "", "no-retries": "\n      retries: 0", "five": "\n      retries: 5"} {
+		// An installed project with one release
+		path := filepath.Join(tmpDir, name)
+		release := filepath.Join(path, "releases", "2024-01-01-00-00-00")
+		for _, dir := range []string{filepath.Join(path, ".git"), filepath.Join(path, "shared"), filepath.Join(release, ".git")} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(release, filepath.Join(path, "current")); err != nil {
+			t.Fatal(err)
+		}
+		yamlConfig.WriteString("  " + name + ":\n")
+		yamlConfig.WriteString("    path: " + path + "\n")
+		yamlConfig.WriteString("    secret: valid-secret-with-at-least-32-chars-" + name + "\n")
+		yamlConfig.WriteString("    healthcheck:\n      url: http://localhost/health" + retries + "\n")
+	}
+	configPath := filepath.Join(tmpDir, "deplobox.yaml")
+	if err := os.WriteFile(configPath, []byte(yamlConfig.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, projects, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	// An explicit 0 disables retries instead of falling back to the default
+	for name, expected := range map[string]int{"default": DefaultHealthCheckRetries, "no-retries": 0, "five": 5} {
+		if got := projects[name].HealthCheck.Retries; got != expected {
+			t.Errorf("%s: expected %d retries, got %d", name, expected, got)
+		}
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidateProjectConfig_GitHubReporter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		reporter *GitHubReporter
+		expected string
+	}{
+		{"missing repository", &GitHubReporter{Token: "ghp_test"}, "repository must be 'owner/repo'"},
+		{"repository without owner", &GitHubReporter{Repository: "repo", Token: "ghp_test"}, "repository must be 'owner/repo'"},
+		{"repository with path", &GitHubReporter{Repository: "owner/repo/extra", Token: "ghp_test"}, "repository must be 'owner/repo'"},
+		{"dot repository", &GitHubReporter{Repository: "owner/..", Token: "ghp_test"}, "repository must be 'owner/repo'"},
+		{"missing token", &GitHubReporter{Repository: "owner/repo"}, "missing required 'token' field"},
+		{"non-http base url", &GitHubReporter{Repository: "owner/repo", Token: "ghp_test", BaseURL: "github.example.com"}, "base_url must start with http:// or https://"},
+		{"non-http environment url", &GitHubReporter{Repository: "owner/repo", Token: "ghp_test", EnvironmentURL: "example.com"}, "environment_url must start with http:// or https://"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := ProjectConfig{
+				Path:           t.TempDir(),
+				Secret:         "valid-secret-with-at-least-32-chars-here",
+				GitHubReporter: tc.reporter,
+			}
+
+			errors := ValidateProjectConfig("test-project", config)
+			found := false
+			for _, err := range errors {
+				if strings.Contains(err, tc.expected) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("Expected error containing %q, got: %v", tc.expected, errors)
+			}
+		})
+	}
+
+	// A complete block has no reporter errors
+	config := ProjectConfig{
+		Path:   t.TempDir(),
+		Secret: "valid-secret-with-at-least-32-chars-here",
+		GitHubReporter: &GitHubReporter{
+			Repository:     "my-org/my.site",
+			Token:          "ghp_test",
+			BaseURL:        "https://github.example.com/api/v3/",
+			EnvironmentURL: "https://example.com",
+		},
+	}
+	for _, err := range ValidateProjectConfig("test-project", config) {
+		if strings.Contains(err, "github_reporter") {
+			t.Errorf("Unexpected github_reporter error: %s", err)
+		}
+	}
+}
+
+func TestValidateProjectConfig_InvalidOnRollback(t *testing.T) {
+	config := ProjectConfig{
+		Path:              t.TempDir(),
+		Secret:            "valid-secret-with-at-least-32-chars-here",
+		RollbackOnFailure: true,
+		OnRollback:        []interface{}{"pm2 reload app", 42},
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	found := false
+	for _, err := range errors {
+		if strings.Contains(err, "on_rollback[1] must be a string or list") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("Expected on_rollback validation error, got: %v", errors)
+	}
+}
+
+func TestValidateProjectConfig_InvalidLifecycleHooks(t *testing.T) {
+	config := ProjectConfig{
+		Path:      t.TempDir(),
+		Secret:    "valid-secret-with-at-least-32-chars-here",
+		PreDeploy: []interface{}{3.14},
+		OnSuccess: []interface{}{"echo ok"},
+		OnFailure: []interface{}{true},
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	for _, expected := range []string{"pre_deploy[0] must be a string or list", "on_failure[0] must be a string or list"} {
+		found := false
+		for _, err := range errors {
+			if strings.Contains(err, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error containing %q, got: %v", expected, errors)
+		}
+	}
+}
+
+func TestValidateProjectConfig_StructuredCommands(t *testing.T) {
+	config := ProjectConfig{
+		Path:   t.TempDir(),
+		Secret: "valid-secret-with-at-least-32-chars-here",
+		PostDeploy: []interface{}{
+			map[string]interface{}{"name": "restart queue", "command": "php artisan queue:restart", "allow_failure": true},
+			map[string]interface{}{"command": []interface{}{"npm", "ci"}, "workdir": "../outside"},
+			map[string]interface{}{"timeout": 30},
+		},
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	for _, unexpected := range []string{"post_deploy[0]"} {
+		for _, err := range errors {
+			if strings.Contains(err, unexpected) {
+				t.Errorf("Expected valid structured command, got: %s", err)
+			}
+		}
+	}
+
+	for _, expected := range []string{"post_deploy[1] is invalid: workdir must be a relative path", "post_deploy[2] is invalid: missing required 'command'"} {
+		found := false
+		for _, err := range errors {
+			if strings.Contains(err, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error containing %q, got: %v", expected, errors)
+		}
+	}
+}
+
+func TestProjectConfigWarnings_ShellOperators(t *testing.T) {
+	config := ProjectConfig{
+		PostDeploy: []interface{}{
+			"php artisan queue:restart || true",
+			[]interface{}{"sh", "-c", "a || b"},
+			map[string]interface{}{"command": "npm ci && npm run build", "shell": true},
+		},
+		OnSuccess: []interface{}{map[string]interface{}{"command": "curl -s https://example.com | sh"}},
+	}
+
+	warnings := ProjectConfigWarnings("test-project", config)
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "post_deploy[0] contains shell operator(s) ||") {
+		t.Errorf("Unexpected warning: %s", warnings[0])
+	}
+	if !strings.Contains(warnings[1], "on_success[0] contains shell operator(s) |") {
+		t.Errorf("Unexpected warning: %s", warnings[1])
+	}
+
+	// Project-level shell silences the warnings for string commands
+	config.Shell = true
+	if warnings := ProjectConfigWarnings("test-project", config); len(warnings) != 0 {
+		t.Errorf("Expected no warnings with shell: true, got %v", warnings)
+	}
+}
+
+func TestValidateProjectConfig_HookEnvironment(t *testing.T) {
+	config := ProjectConfig{
+		Path:    t.TempDir(),
+		Secret:  "valid-secret-with-at-least-32-chars-here",
+		Env:     map[string]string{"APP_ENV": "production", "BAD-NAME": "x", "DEPLOBOX_COMMIT": "spoofed"},
+		EnvFile: "shared/.env",
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	for _, expected := range []string{
+		"env has invalid variable name 'BAD-NAME'",
+		"env variable 'DEPLOBOX_COMMIT' uses the reserved DEPLOBOX_ prefix",
+		"env_file not found",
+	} {
+		found := false
+		for _, err := range errors {
+			if strings.Contains(err, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error containing %q, got: %v", expected, errors)
+		}
+	}
+	for _, err := range errors {
+		if strings.Contains(err, "APP_ENV") {
+			t.Errorf("Expected APP_ENV to be valid, got: %s", err)
+		}
+	}
+}
+
+func TestValidateProjectConfig_LinkedPaths(t *testing.T) {
+	config := ProjectConfig{
+		Path:        t.TempDir(),
+		Secret:      "valid-secret-with-at-least-32-chars-here",
+		LinkedFiles: []string{".env", "/etc/passwd"},
+		LinkedDirs:  []string{"storage", "../shared", ".git"},
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	for _, expected := range []string{"linked_files[1]", "linked_dirs[1]", "linked_dirs[2]"} {
+		found := false
+		for _, err := range errors {
+			if strings.Contains(err, expected+" must be a relative path inside the release") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error for %s, got: %v", expected, errors)
+		}
+	}
+	for _, err := range errors {
+		if strings.Contains(err, "linked_files[0]") || strings.Contains(err, "linked_dirs[0]") {
+			t.Errorf("Expected valid linked path, got: %s", err)
+		}
+	}
+}
+
+func TestValidateProjectConfig_ReleaseRetention(t *testing.T) {
+	config := ProjectConfig{
+		Path:            t.TempDir(),
+		Secret:          "valid-secret-with-at-least-32-chars-here",
+		KeepReleases:    -1,
+		MaxReleaseAge:   "soon",
+		MaxReleasesSize: "2XB",
+	}
+
+	errors := ValidateProjectConfig("test-project", config)
+
+	for _, expected := range []string{"keep_releases", "max_release_age", "max_releases_size"} {
+		found := false
+		for _, err := range errors {
+			if strings.Contains(err, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected error for %s, got: %v", expected, errors)
+		}
+	}
+}
+
+func TestParseAgeAndSize(t *testing.T) {
+	ageTests := map[string]time.Duration{
+		"":    0,
+		"30d": 30 * 24 * time.Hour,
+		"72h": 72 * time.Hour,
+	}
+	for input, expected := range ageTests {
+		got, err := ParseAge(input)
+		if err != nil || got != expected {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", input, got, err, expected)
+		}
+	}
+
+	sizeTests := map[string]int64{
+		"":      0,
+		"1024":  1024,
+		"500MB": 500 << 20,
+		"2gb":   2 << 30,
+	}
+	for input, expected := range sizeTests {
+		got, err := parseSize(input)
+		if err != nil || got != expected {
+			t.Errorf("parseSize(%q) = %v, %v; want %v", input, got, err, expected)
+		}
+	}
+
+	for _, input := range []string{"0d", "-1h", "10"} {
+		if _, err := ParseAge(input); err == nil {
+			t.Errorf("Expected ParseAge(%q) to fail", input)
+		}
+	}
+	for _, input := range []string{"0MB", "MB", "1.5GB"} {
+		if _, err := parseSize(input); err == nil {
+			t.Errorf("Expected parseSize(%q) to fail", input)
+		}
+	}
+}
+
+func TestValidateAdminTokens(t *testing.T) {
+	projects := map[string]ProjectConfig{"myapp": {}}
+
+	valid := []AdminToken{
+		{Name: "ci", SHA256: security.HashToken("ci-token"), Scopes: []string{"deploy"}, Projects: []string{"myapp"}},
+		{Name: "ops", SHA256: security.HashToken("ops-token")},
+	}
+	if errors := ValidateAdminTokens(valid, projects); len(errors) != 0 {
+		t.Errorf("Expected no errors, got: %v", errors)
+	}
+
+	invalid := []AdminToken{
+		{Name: "", SHA256: security.HashToken("a")},
+		{Name: "ci", SHA256: "not-a-hash"},
+		{Name: "ci", SHA256: security.HashToken("b")},
+		{Name: "bad-scope", SHA256: security.HashToken("c"), Scopes: []string{"write"}},
+		{Name: "bad-project", SHA256: security.HashToken("d"), Projects: []string{"other"}},
+	}
+	errors := ValidateAdminTokens(invalid, projects)
+	if len(errors) != 5 {
+		t.Errorf("Expected 5 errors, got: %v", errors)
+	}
+}
@@ -1,31 +1,134 @@
 package project
 
+import "time"
+
+const (
+	// ProviderGitHub identifies GitHub push webhooks (X-Hub-Signature-256)
+	ProviderGitHub = "github"
+
+	// ProviderGitLab identifies GitLab push webhooks (X-Gitlab-Token)
+	ProviderGitLab = "gitlab"
+
+	// ProviderGitea identifies Gitea and Forgejo push webhooks (X-Gitea-Signature)
+	ProviderGitea = "gitea"
+
+	// ProviderBitbucket identifies Bitbucket Cloud push webhooks (X-Hub-Signature)
+	ProviderBitbucket = "bitbucket"
+)
+
+// SupportedProviders lists the webhook providers accepted in configuration
+var SupportedProviders = []string{ProviderGitHub, ProviderGitLab, ProviderGitea, ProviderBitbucket}
+
 // Project represents a validated deployment project configuration
 type Project struct {
 	Name                string
 	Path                string
 	Secret              string
+	Provider            string // Webhook provider: github, gitlab, gitea or bitbucket
 	Branch              string
 	PullTimeout         int
+	PreDeployTimeout    int
+	PreDeploy           []interface{} // Commands run in current/ before the release is created
+	ExcludeGitDir       bool          // Build releases with git archive (no .git in releases)
+	CopyFiles           bool          // Copy shared/ into each release with rsync
+	LinkedFiles         []string      // Release files symlinked to shared/
+	LinkedDirs          []string      // Release directories symlinked to shared/
 	PostDeployTimeout   int
-	PostDeploy          []interface{} // Can be string or []string
+	PostDeploy          []interface{} // Can be string, []string or a command map with options
 	PostActivateTimeout int
-	PostActivate        []interface{} // Can be string or []string
+	PostActivate        []interface{}     // Can be string, []string or a command map with options
+	HealthCheck         *HealthCheck      // Optional post-activation check, nil if not configured
+	Shell               bool              // Run string hook commands through /bin/sh -c
+	Env                 map[string]string // Extra environment variables for hook commands
+	EnvFile             string            // Absolute path of a dotenv file loaded for hook commands
+	CleanEnv            bool              // Do not inherit the server's environment in hook commands
+	Warnings            []string          // Non-fatal configuration issues found at load time
+	RollbackOnFailure   bool              // Restore the previous release when post_activate fails
+	OnRollback          []interface{}     // Commands run in current/ after a rollback
+	OnSuccess           []interface{}     // Commands run in current/ after a successful deployment
+	OnFailure           []interface{}     // Commands run in current/ after a failed deployment
+	KeepReleases        int               // Maximum number of releases kept by cleanup
+	MaxReleaseAge       time.Duration     // Cleanup removes releases older than this, 0 for no limit
+	MaxReleasesSize     int64             // Disk budget for releases/ in bytes, 0 for no limit
+	KeepFailed          bool              // Keep the last failed build in releases/ for debugging
+	GitHubReporter      *GitHubReporter   // Report deployments to GitHub, nil if not configured
+}
+
+// HealthCheck configures how a freshly activated release is checked.
+// Exactly one of URL or Command is set.
+type HealthCheck struct {
+	URL            string      // HTTP(S) URL that must return ExpectedStatus
+	Command        interface{} // Command run in current/ that must exit 0 (string or list)
+	ExpectedStatus int         // Expected HTTP status code
+	Retries        int         // Retries after the first failed attempt
+	Interval       int         // Seconds between attempts
+	Timeout        int         // Seconds per attempt
+}
+
+// HealthCheckConfig represents the YAML configuration of a health check
+type HealthCheckConfig struct {
+	URL            string      `yaml:"url"`
+	Command        interface{} `yaml:"command"`
+	ExpectedStatus int         `yaml:"expected_status"`
+	Retries        *int        `yaml:"retries"` // nil if not set, as 0 disables retries
+	Interval       int         `yaml:"interval"`
+	Timeout        int         `yaml:"timeout"`
+}
+
+// GitHubReporter configures reporting deployments to GitHub as Deployments
+// with in_progress, success and failure statuses
+type GitHubReporter struct {
+	Repository     string `yaml:"repository"`      // owner/repo
+	Token          string `yaml:"token"`           // Token allowed to write deployments
+	BaseURL        string `yaml:"base_url"`        // API root, e.g. https://github.example.com/api/v3/ (default: https://api.github.com/)
+	Environment    string `yaml:"environment"`     // GitHub environment name
+	EnvironmentURL string `yaml:"environment_url"` // URL of the deployed site, linked from GitHub
 }
 
 // ProjectConfig represents the YAML configuration for a project
 type ProjectConfig struct {
-	Path                string        `yaml:"path"`
-	Secret              string        `yaml:"secret"`
-	Branch              string        `yaml:"branch"`
-	PullTimeout         int           `yaml:"pull_timeout"`
-	PostDeployTimeout   int           `yaml:"post_deploy_timeout"`
-	PostDeploy          []interface{} `yaml:"post_deploy"`
-	PostActivateTimeout int           `yaml:"post_activate_timeout"`
-	PostActivate        []interface{} `yaml:"post_activate"`
+	Path                string             `yaml:"path"`
+	Secret              string             `yaml:"secret"`
+	Provider            string             `yaml:"provider"`
+	Branch              string             `yaml:"branch"`
+	PullTimeout         int                `yaml:"pull_timeout"`
+	PreDeployTimeout    int                `yaml:"pre_deploy_timeout"`
+	PreDeploy           []interface{}      `yaml:"pre_deploy"`
+	ExcludeGitDir       bool               `yaml:"exclude_git_dir"`
+	CopyFiles           *bool              `yaml:"copy_files"`
+	LinkedFiles         []string           `yaml:"linked_files"`
+	LinkedDirs          []string           `yaml:"linked_dirs"`
+	PostDeployTimeout   int                `yaml:"post_deploy_timeout"`
+	PostDeploy          []interface{}      `yaml:"post_deploy"`
+	PostActivateTimeout int                `yaml:"post_activate_timeout"`
+	PostActivate        []interface{}      `yaml:"post_activate"`
+	HealthCheck         *HealthCheckConfig `yaml:"healthcheck"`
+	Shell               bool               `yaml:"shell"`
+	Env                 map[string]string  `yaml:"env"`
+	EnvFile             string             `yaml:"env_file"`
+	CleanEnv            bool               `yaml:"clean_env"`
+	RollbackOnFailure   bool               `yaml:"rollback_on_failure"`
+	OnRollback          []interface{}      `yaml:"on_rollback"`
+	OnSuccess           []interface{}      `yaml:"on_success"`
+	OnFailure           []interface{}      `yaml:"on_failure"`
+	KeepReleases        int                `yaml:"keep_releases"`
+	MaxReleaseAge       string             `yaml:"max_release_age"`
+	MaxReleasesSize     string             `yaml:"max_releases_size"`
+	KeepFailed          bool               `yaml:"keep_failed"`
+	GitHubReporter      *GitHubReporter    `yaml:"github_reporter"`
+}
+
+// AdminToken is a bearer token accepted by the admin API. Only the SHA-256
+// of the token is stored in the config.
+type AdminToken struct {
+	Name     string   `yaml:"name"`     // Identity recorded in the history for actions taken with the token
+	SHA256   string   `yaml:"sha256"`   // Hex SHA-256 of the token
+	Scopes   []string `yaml:"scopes"`   // read, deploy, rollback and/or admin (default: admin)
+	Projects []string `yaml:"projects"` // Projects the token may act on (default: all)
 }
 
 // Config represents the root configuration structure
 type Config struct {
-	Projects map[string]ProjectConfig `yaml:"projects"`
+	AdminTokens []AdminToken             `yaml:"admin_tokens"`
+	Projects    map[string]ProjectConfig `yaml:"projects"`
 }
@@ -0,0 +1,116 @@
+// Package report reports deployments back to the hosting service, so
+// developers can see on the commit whether their push went live.
+package report
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"deplobox/internal/project"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// GitHub deployment status states
+const (
+	StateInProgress = "in_progress"
+	StateSuccess    = "success"
+	StateFailure    = "failure"
+	StateInactive   = "inactive"
+)
+
+// maxDescriptionLength is the longest description GitHub accepts on a
+// deployment status
+const maxDescriptionLength = 140
+
+// GitHub reports deployments of a repository through the GitHub Deployments
+// API: a Deployment per deployed commit, with statuses as it progresses
+type GitHub struct {
+	client         *github.Client
+	owner          string
+	repo           string
+	environment    string
+	environmentURL string
+}
+
+// NewGitHub creates a reporter from a project's github_reporter config
+func NewGitHub(cfg *project.GitHubReporter) (*GitHub, error) {
+	owner, repo, ok := strings.Cut(cfg.Repository, "/")
+	if !ok || owner == "" || repo == "" {
+		return nil, fmt.Errorf("invalid repository '%s': must be owner/repo", cfg.Repository)
+	}
+
+	client := github.NewClient(nil).WithAuthToken(cfg.Token)
+	if cfg.BaseURL != "" {
+		// The client resolves API paths relative to the base URL
+		baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid base_url '%s': %w", cfg.BaseURL, err)
+		}
+		client.BaseURL = baseURL
+	}
+
+	return &GitHub{
+		client:         client,
+		owner:          owner,
+		repo:           repo,
+		environment:    cfg.Environment,
+		environmentURL: cfg.EnvironmentURL,
+	}, nil
+}
+
+// Start creates a Deployment of ref, a commit SHA or a branch, and marks it
+// in_progress. Returns the Deployment's ID for Finish.
+func (g *GitHub) Start(ctx context.Context, ref, description string) (int64, error) {
+	description = truncateDescription(description)
+	deployment, _, err := g.client.Repositories.CreateDeployment(ctx, g.owner, g.repo, &github.DeploymentRequest{
+		Ref:         github.String(ref),
+		Task:        github.String("deploy"),
+		Environment: github.String(g.environment),
+		Description: github.String(description),
+		// deplobox deploys exactly the pushed commit, without merging or waiting for checks
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create GitHub deployment: %w", err)
+	}
+
+	if err := g.setStatus(ctx, deployment.GetID(), StateInProgress, description); err != nil {
+		return deployment.GetID(), err
+	}
+	return deployment.GetID(), nil
+}
+
+// Finish sets the final state of a Deployment made by Start: success,
+// failure or inactive
+func (g *GitHub) Finish(ctx context.Context, id int64, state, description string) error {
+	return g.setStatus(ctx, id, state, truncateDescription(description))
+}
+
+func (g *GitHub) setStatus(ctx context.Context, id int64, state, description string) error {
+	status := &github.DeploymentStatusRequest{
+		State:       github.String(state),
+		Description: github.String(description),
+		Environment: github.String(g.environment),
+	}
+	if g.environmentURL != "" {
+		status.EnvironmentURL = github.String(g.environmentURL)
+	}
+
+	if _, _, err := g.client.Repositories.CreateDeploymentStatus(ctx, g.owner, g.repo, id, status); err != nil {
+		return fmt.Errorf("failed to set GitHub deployment %d to %s: %w", id, state, err)
+	}
+	return nil
+}
+
+// truncateDescription shortens a description to what GitHub accepts
+func truncateDescription(description string) string {
+	runes := []rune(description)
+	if len(runes) <= maxDescriptionLength {
+		return description
+	}
+	return string(runes[:maxDescriptionLength-1]) + "…"
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"deplobox/internal/project"
+)
+
+// fakeRequest is a request received by the fake GitHub API
+type fakeRequest struct {
+	Method string
+	Path   string
+	Auth   string
+	Body   map[string]interface{}
+}
+
+// newFakeGitHub starts a fake GitHub API that creates deployment 42 and
+// records the requests it receives
+func newFakeGitHub(t *testing.T) (*httptest.Server, func() []fakeRequest) {
+	var mu sync.Mutex
+	var requests []fakeRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := fakeRequest{Method: r.Method, Path: r.URL.Path, Auth: r.Header.Get("Authorization")}
+		_ = json.NewDecoder(r.Body).Decode(&req.Body)
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if strings.HasSuffix(r.URL.Path, "/statuses") {
+			w.Write([]byte(`{"id":1}`))
+		} else {
+			w.Write([]byte(`{"id":42}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []fakeRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]fakeRequest(nil), requests...)
+	}
+}
+
+func TestGitHub_StartAndFinish(t *testing.T) {
+	server, requests := newFakeGitHub(t)
+
+	reporter, err := NewGitHub(&project.GitHubReporter{
+		Repository:     "my-org/my-site",
+		Token:          "ghp_test",
+		BaseURL:        server.URL + "/api/v3", // Without the trailing slash
+		Environment:    "production",
+		EnvironmentURL: "https://example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewGitHub failed: %v", err)
+	}
+
+	id, err := reporter.Start(context.Background(), "1a2b3c4d5e6f", "Deploying 1a2b3c4")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if id != 42 {
+		t.Errorf("Expected deployment ID 42, got %d", id)
+	}
+	if err := reporter.Finish(context.Background(), id, StateSuccess, "Deployment successful"); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	got := requests()
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 requests, got %d: %+v", len(got), got)
+	}
+
+	create := got[0]
+	if create.Method != "POST" || create.Path != "/api/v3/repos/my-org/my-site/deployments" {
+		t.Errorf("Expected POST of the deployment, got %s %s", create.Method, create.Path)
+	}
+	if create.Auth != "Bearer ghp_test" {
+		t.Errorf("Expected the token in the Authorization header, got %q", create.Auth)
+	}
+	if create.Body["ref"] != "1a2b3c4d5e6f" || create.Body["environment"] != "production" || create.Body["auto_merge"] != false {
+		t.Errorf("Unexpected deployment request: %v", create.Body)
+	}
+	if contexts, ok := create.Body["required_contexts"].([]interface{}); !ok || len(contexts) != 0 {
+		t.Errorf("Expected empty required_contexts, got %v", create.Body["required_contexts"])
+	}
+
+	for i, state := range []string{StateInProgress, StateSuccess} {
+		status := got[i+1]
+		if status.Path != "/api/v3/repos/my-org/my-site/deployments/42/statuses" {
+			t.Errorf("Expected a status of deployment 42, got %s", status.Path)
+		}
+		if status.Body["state"] != state || status.Body["environment_url"] != "https://example.com" {
+			t.Errorf("Expected state %s, got %v", state, status.Body)
+		}
+	}
+}
+
+func TestGitHub_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	reporter, err := NewGitHub(&project.GitHubReporter{Repository: "my-org/my-site", Token: "wrong", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewGitHub failed: %v", err)
+	}
+
+	if _, err := reporter.Start(context.Background(), "main", "Deploying main"); err == nil {
+		t.Error("Expected an error for a rejected token")
+	}
+}
+
+func TestTruncateDescription(t *testing.T) {
+	if got := truncateDescription("short"); got != "short" {
+		t.Errorf("Expected short descriptions unchanged, got %q", got)
+	}
+
+	got := truncateDescription(strings.Repeat("é", 200))
+	if n := len([]rune(got)); n != maxDescriptionLength {
+		t.Errorf("Expected %d characters, got %d", maxDescriptionLength, n)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("Expected an ellipsis, got %q", got)
+	}
+}
@@ -13,6 +13,7 @@ var (
 	gitURLPattern  = regexp.MustCompile(`^https://github\.com/[a-zA-Z0-9_-]+/[a-zA-Z0-9_.-]+(?:\.git)?$`)
 	branchPattern  = regexp.MustCompile(`^[a-zA-Z0-9/_.-]+$`)
 	projectPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	commitPattern  = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
 )
 
 // ValidateGitURL ensures URL is safe for git clone operations.
@@ -52,6 +53,18 @@ func ValidateBranchName(branch string) error {
 	return nil
 }
 
+// ValidateCommitSHA ensures a commit hash from a webhook payload is a plain
+// hex object name (abbreviated or full SHA-1/SHA-256) before it reaches git.
+func ValidateCommitSHA(sha string) error {
+	if sha == "" {
+		return fmt.Errorf("commit hash cannot be empty")
+	}
+	if !commitPattern.MatchString(sha) {
+		return fmt.Errorf("commit hash must be 7-64 hexadecimal characters")
+	}
+	return nil
+}
+
 // ValidateProjectName ensures project name is safe for use in paths and URLs.
 func ValidateProjectName(name string) error {
 	if name == "" {
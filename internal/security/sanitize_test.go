@@ -101,6 +101,38 @@ func TestValidateBranchName(t *testing.T) {
 	}
 }
 
+func TestValidateCommitSHA(t *testing.T) {
+	tests := []struct {
+		name    string
+		sha     string
+		wantErr bool
+	}{
+		// Valid cases
+		{"full sha1", "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", false},
+		{"abbreviated", "da15608", false},
+		{"uppercase", "DA1560886D4F", false},
+		{"full sha256", "6d7a1c3a0e1f5b9a2c4d8e0f1a2b3c4d5e6f7a8b6d7a1c3a0e1f5b9a2c4d8e0f", false},
+
+		// Invalid cases
+		{"empty", "", true},
+		{"too short", "da156", true},
+		{"too long", "6d7a1c3a0e1f5b9a2c4d8e0f1a2b3c4d5e6f7a8b6d7a1c3a0e1f5b9a2c4d8e0f0", true},
+		{"non-hex", "ghi789abc", true},
+		{"option injection", "--upload-pack=evil", true},
+		{"ref name", "refs/heads/main", true},
+		{"revision expression", "HEAD~1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCommitSHA(tt.sha)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCommitSHA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateProjectName(t *testing.T) {
 	tests := []struct {
 		name    string
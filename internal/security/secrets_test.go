@@ -340,3 +340,61 @@ func BenchmarkCalculateEntropy(b *testing.B) {
 		_ = calculateEntropy(secret)
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("test-token")
+	if !ValidTokenHash(hash) {
+		t.Fatalf("HashToken returned invalid hash %q", hash)
+	}
+	if !TokenMatches("test-token", hash) {
+		t.Error("Expected token to match its hash")
+	}
+	if TokenMatches("other-token", hash) {
+		t.Error("Expected other token not to match")
+	}
+	if !ValidTokenHash(strings.ToUpper(hash)) || !TokenMatches("test-token", strings.ToUpper(hash)) {
+		t.Error("Expected an uppercase hash to be valid and match")
+	}
+
+	for _, invalid := range []string{"", "abc", hash[:63], hash + "00", "zz" + hash[2:]} {
+		if ValidTokenHash(invalid) {
+			t.Errorf("ValidTokenHash(%q) = true, want false", invalid)
+		}
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	token, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, TokenPrefix) || len(token) != len(TokenPrefix)+64 {
+		t.Errorf("Unexpected token format %q", token)
+	}
+
+	other, _ := GenerateToken()
+	if token == other {
+		t.Error("Expected different tokens")
+	}
+}
+
+func TestScopeGrants(t *testing.T) {
+	tests := []struct {
+		granted []string
+		scope   string
+		want    bool
+	}{
+		{[]string{ScopeRead}, ScopeRead, true},
+		{[]string{ScopeRead}, ScopeDeploy, false},
+		{[]string{ScopeDeploy}, ScopeRead, true},
+		{[]string{ScopeDeploy}, ScopeRollback, false},
+		{[]string{ScopeDeploy, ScopeRollback}, ScopeRollback, true},
+		{[]string{ScopeAdmin}, ScopeRollback, true},
+		{nil, ScopeRead, false},
+	}
+	for _, tt := range tests {
+		if got := ScopeGrants(tt.granted, tt.scope); got != tt.want {
+			t.Errorf("ScopeGrants(%v, %s) = %v, want %v", tt.granted, tt.scope, got, tt.want)
+		}
+	}
+}
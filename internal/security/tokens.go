@@ -0,0 +1,68 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// API token scopes. Every scope includes read access; admin includes all.
+const (
+	ScopeRead     = "read"     // Status, deployment logs and live streams
+	ScopeDeploy   = "deploy"   // Manual deployments
+	ScopeRollback = "rollback" // Restores of earlier releases
+	ScopeAdmin    = "admin"    // Everything
+)
+
+// Scopes lists the valid API token scopes
+var Scopes = []string{ScopeRead, ScopeDeploy, ScopeRollback, ScopeAdmin}
+
+// TokenPrefix starts every generated API token, so leaked tokens are easy
+// to recognise
+const TokenPrefix = "dbx_"
+
+// GenerateToken creates a random API token
+func GenerateToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return TokenPrefix + hex.EncodeToString(bytes), nil
+}
+
+// HashToken returns the hex SHA-256 of an API token, the form in which
+// tokens are stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidTokenHash checks that a stored token hash is a hex SHA-256
+func ValidTokenHash(hash string) bool {
+	decoded, err := hex.DecodeString(hash)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// TokenMatches reports whether token hashes to hash, in constant time.
+// Like ValidTokenHash, it accepts hashes in upper or lower case hex.
+func TokenMatches(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(strings.ToLower(hash))) == 1
+}
+
+// ValidScope checks that scope is one of Scopes
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// ScopeGrants reports whether a token with the granted scopes may perform an
+// action that needs scope
+func ScopeGrants(granted []string, scope string) bool {
+	if slices.Contains(granted, ScopeAdmin) || slices.Contains(granted, scope) {
+		return true
+	}
+	return scope == ScopeRead && len(granted) > 0
+}
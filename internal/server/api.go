@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/project"
+	"deplobox/internal/security"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ManualDeployRequest is the optional JSON body of a manual deploy request
+type ManualDeployRequest struct {
+	Commit string `json:"commit"` // Commit SHA to deploy (default: branch tip)
+	Branch string `json:"branch"` // Branch to deploy (default: the configured one)
+}
+
+// HandleManualDeploy starts a deployment without a push, e.g. to pick up
+// changed shared files. The body may name a commit or branch to deploy.
+func (s *Server) HandleManualDeploy(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "projectName")
+	s.Logger.Info("manual deploy requested", "project", projectName, "token", tokenName(r), "remote_addr", r.RemoteAddr)
+
+	// Validate project name for security
+	if err := security.ValidateProjectName(projectName); err != nil {
+		s.Logger.Warn("Invalid project name in deploy request", "project", projectName, "error", err)
+		s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid project name: %v", err)})
+		return
+	}
+
+	// Check if project exists
+	proj, err := s.Registry.Get(projectName)
+	if err != nil {
+		s.respondJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown project"})
+		return
+	}
+
+	// The body is optional; without one the configured branch tip is deployed
+	var req ManualDeployRequest
+	if !s.readOptionalJSON(w, r, projectName, &req) {
+		return
+	}
+
+	branch := proj.Branch
+	if req.Branch != "" {
+		if err := security.ValidateBranchName(req.Branch); err != nil {
+			s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid branch name: %v", err)})
+			return
+		}
+		branch = req.Branch
+	}
+	if req.Commit != "" {
+		if err := security.ValidateCommitSHA(req.Commit); err != nil {
+			s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid commit hash: %v", err)})
+			return
+		}
+	}
+
+	push := &deployment.PushEvent{
+		Ref:     "refs/heads/" + branch,
+		Commit:  req.Commit,
+		Pusher:  tokenName(r),
+		Trigger: deployment.TriggerManual,
+	}
+	s.Logger.Info("manual deploy accepted", "project", projectName, "branch", branch, "commit", req.Commit, "token", push.Pusher)
+
+	s.submitDeployment(w, r, projectName, proj, push)
+}
+
+// HandleAPIRestore restores an earlier release, for requests authenticated
+// with a rollback token. The body is optional; without one the previous
+// release is restored.
+func (s *Server) HandleAPIRestore(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "projectName")
+	s.Logger.Info("restore requested", "project", projectName, "token", tokenName(r), "remote_addr", r.RemoteAddr)
+
+	// Validate project name for security
+	if err := security.ValidateProjectName(projectName); err != nil {
+		s.Logger.Warn("Invalid project name in restore request", "project", projectName, "error", err)
+		s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid project name: %v", err)})
+		return
+	}
+
+	// Check if project exists
+	proj, err := s.Registry.Get(projectName)
+	if err != nil {
+		s.respondJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown project"})
+		return
+	}
+
+	var req RestoreRequest
+	if !s.readOptionalJSON(w, r, projectName, &req) {
+		return
+	}
+
+	s.startRestore(w, r, proj, req)
+}
+
+// readOptionalJSON decodes a JSON request body into v, leaving v untouched
+// if the body is empty. Writes the error response and returns false if the
+// body cannot be read.
+func (s *Server) readOptionalJSON(w http.ResponseWriter, r *http.Request, projectName string, v interface{}) bool {
+	if r.ContentLength > MaxPayloadBytes {
+		s.respondJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "Payload too large"})
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxPayloadBytes))
+	if err != nil {
+		s.Logger.Error("failed to read request body", "error", err, "project", projectName)
+		s.respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read payload"})
+		return false
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return true
+	}
+
+	if r.Header.Get("Content-Type") != "application/json" {
+		s.respondJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Invalid content type"})
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON payload"})
+		return false
+	}
+
+	return true
+}
+
+// projectForPush returns the project to deploy push with: a copy targeting
+// another branch for manual deployments that override it, else proj itself
+func projectForPush(proj *project.Project, push *deployment.PushEvent) *project.Project {
+	branch := strings.TrimPrefix(push.Ref, "refs/heads/")
+	if push.TriggerName() != deployment.TriggerManual || branch == proj.Branch {
+		return proj
+	}
+
+	override := *proj
+	override.Branch = branch
+	return &override
+}
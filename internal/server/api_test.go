@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+)
+
+func newManualDeployRequest(body, token string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/projects/test-project/deploy", bytes.NewReader([]byte(body)))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestHandleManualDeploy_Unauthorized(t *testing.T) {
+	server, _ := setupTestServer(t)
+	addTestToken(server, "deploy")
+
+	for _, token := range []string{"", "wrong-token"} {
+		rr := httptest.NewRecorder()
+		server.Router().ServeHTTP(rr, newManualDeployRequest("", token))
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Token %q: expected status 401, got %d", token, rr.Code)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Token %q: expected WWW-Authenticate header", token)
+		}
+	}
+
+	// Without configured tokens the API accepts nothing
+	server.AdminTokens = nil
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newManualDeployRequest("", testAPIToken))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without configured tokens, got %d", rr.Code)
+	}
+}
+
+func TestHandleManualDeploy_InvalidOverride(t *testing.T) {
+	server, _ := setupTestServer(t)
+	addTestToken(server, "deploy")
+
+	for _, body := range []string{`{"commit":"not-a-sha"}`, `{"branch":"--upload-pack=evil"}`, `{"commit":`} {
+		rr := httptest.NewRecorder()
+		server.Router().ServeHTTP(rr, newManualDeployRequest(body, testAPIToken))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Body %s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleManualDeploy_Queued(t *testing.T) {
+	tmpDir := t.TempDir()
+	testProject := &project.Project{
+		Name:   "test-project",
+		Path:   tmpDir,
+		Secret: "test-secret-at-least-32-chars-long-here",
+		Branch: "main",
+	}
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+
+	hist, err := history.NewHistory(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	server := NewServer(registry, hist, slog.New(slog.NewTextHandler(os.Stderr, nil)), false)
+	addTestToken(server, "deploy")
+
+	// Simulate an in-progress deployment, so the manual one is queued
+	server.LockManager.TryLock("test-project")
+	defer server.LockManager.Unlock("test-project")
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newManualDeployRequest(`{"branch":"hotfix","commit":"abc1234"}`, testAPIToken))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["message"] != "Deployment queued" {
+		t.Errorf("Expected 'Deployment queued' message, got %v", response)
+	}
+
+	pending := server.Queue.Pending("test-project")
+	if pending == nil {
+		t.Fatal("Expected a pending deployment")
+	}
+	if pending.Push.Ref != "refs/heads/hotfix" || pending.Push.Commit != "abc1234" {
+		t.Errorf("Expected hotfix at abc1234, got %+v", pending.Push)
+	}
+	if pending.Push.Trigger != deployment.TriggerManual || pending.Push.Pusher != "ci" {
+		t.Errorf("Expected manual push by ci, got %+v", pending.Push)
+	}
+
+	records, err := hist.GetDeploymentHistory(context.Background(), "test-project", 10)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 history record, got %d", len(records))
+	}
+	record := records[0]
+	if record.Status != "queued" || record.Branch != "hotfix" {
+		t.Errorf("Expected queued hotfix record, got %s/%s", record.Status, record.Branch)
+	}
+	if record.Trigger != deployment.TriggerManual || record.TriggeredBy == nil || *record.TriggeredBy != "ci" {
+		t.Errorf("Expected manual trigger by ci, got %s/%v", record.Trigger, record.TriggeredBy)
+	}
+}
+
+func TestProjectForPush(t *testing.T) {
+	proj := &project.Project{Name: "test-project", Branch: "main"}
+
+	webhook := &deployment.PushEvent{Ref: "refs/heads/hotfix"}
+	if got := projectForPush(proj, webhook); got != proj {
+		t.Error("Expected webhook pushes to use the configured project")
+	}
+
+	manual := &deployment.PushEvent{Ref: "refs/heads/hotfix", Trigger: deployment.TriggerManual}
+	got := projectForPush(proj, manual)
+	if got.Branch != "hotfix" || proj.Branch != "main" {
+		t.Errorf("Expected a copy on hotfix leaving the project on main, got %s and %s", got.Branch, proj.Branch)
+	}
+}
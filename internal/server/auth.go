@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"deplobox/internal/security"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TokenTouchInterval is how often the last use of a stored token is written,
+// so busy clients do not cause a database write per request
+const TokenTouchInterval = time.Minute
+
+// principalKey is the request context key holding the authenticated Principal
+type principalKey struct{}
+
+// Principal is the API token a request was authenticated with
+type Principal struct {
+	Name     string   // Token name, recorded in the history for actions taken with it
+	Scopes   []string // read, deploy, rollback and/or admin
+	Projects []string // Projects the token may act on, all if empty
+}
+
+// Allows reports whether the token may perform an action needing scope
+func (p *Principal) Allows(scope string) bool {
+	return security.ScopeGrants(p.Scopes, scope)
+}
+
+// AllowsProject reports whether the token may act on the project
+func (p *Principal) AllowsProject(project string) bool {
+	return len(p.Projects) == 0 || slices.Contains(p.Projects, project)
+}
+
+// RequireScope returns middleware that admits requests carrying an API token
+// ("Authorization: Bearer <token>") with the scope. On routes with a
+// {projectName}, the token must also be allowed on that project.
+func (s *Server) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := s.authenticate(r)
+			if principal == nil {
+				s.Logger.Warn("invalid or missing API token", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="deplobox"`)
+				s.respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid or missing API token"})
+				return
+			}
+
+			if !principal.Allows(scope) {
+				s.Logger.Warn("API token lacks scope", "token", principal.Name, "scope", scope, "path", r.URL.Path)
+				s.respondJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("Token lacks the '%s' scope", scope)})
+				return
+			}
+
+			if projectName := chi.URLParam(r, "projectName"); projectName != "" && !principal.AllowsProject(projectName) {
+				s.Logger.Warn("API token not allowed for project", "token", principal.Name, "project", projectName)
+				s.respondJSON(w, http.StatusForbidden, map[string]string{"error": "Token not allowed for this project"})
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticate returns the principal of the request's bearer token
+func (s *Server) authenticate(r *http.Request) *Principal {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return nil
+	}
+	return s.authenticateToken(r.Context(), token)
+}
+
+// authenticateToken returns the principal of a token: one of the config's
+// admin_tokens, or a live token from the token store
+func (s *Server) authenticateToken(ctx context.Context, token string) *Principal {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil
+	}
+
+	// Check every config token, so the response time does not reveal which matched
+	var principal *Principal
+	for _, t := range s.AdminTokens {
+		if security.TokenMatches(token, t.SHA256) && principal == nil {
+			principal = &Principal{Name: t.Name, Scopes: t.Scopes, Projects: t.Projects}
+		}
+	}
+	if principal != nil || s.History == nil {
+		return principal
+	}
+
+	stored, err := s.History.GetTokenByHash(ctx, security.HashToken(token))
+	if err != nil {
+		s.Logger.Error("Failed to look up API token", "error", err)
+		return nil
+	}
+	now := time.Now()
+	if stored == nil || !stored.Active(now) {
+		return nil
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= TokenTouchInterval {
+		if err := s.History.TouchToken(ctx, stored.ID, now); err != nil {
+			s.Logger.Error("Failed to record API token use", "error", err, "token", stored.Name)
+		}
+	}
+
+	return &Principal{Name: stored.Name, Scopes: stored.Scopes, Projects: stored.Projects}
+}
+
+// principal returns the API token that authenticated a request, or nil on
+// routes without token authentication
+func principal(r *http.Request) *Principal {
+	p, _ := r.Context().Value(principalKey{}).(*Principal)
+	return p
+}
+
+// tokenName returns the name of the API token that authenticated a request
+func tokenName(r *http.Request) string {
+	if p := principal(r); p != nil {
+		return p.Name
+	}
+	return ""
+}
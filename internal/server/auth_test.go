@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+	"deplobox/internal/security"
+)
+
+const testAPIToken = "test-api-token"
+
+// addTestToken makes the server accept testAPIToken, named "ci", with the
+// given scopes on all projects
+func addTestToken(server *Server, scopes ...string) {
+	server.AdminTokens = append(server.AdminTokens, project.AdminToken{
+		Name:   "ci",
+		SHA256: security.HashToken(testAPIToken),
+		Scopes: scopes,
+	})
+}
+
+// authorized adds testAPIToken to a request
+func authorized(req *http.Request) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+testAPIToken)
+	return req
+}
+
+func TestRequireScope(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.AdminTokens = []project.AdminToken{
+		{Name: "reader", SHA256: security.HashToken("read-token"), Scopes: []string{"read"}},
+		{Name: "other", SHA256: security.HashToken("other-token"), Scopes: []string{"admin"}, Projects: []string{"other-project"}},
+		{Name: "admin", SHA256: security.HashToken("admin-token"), Scopes: []string{"admin"}},
+	}
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/status/test-project", "", http.StatusUnauthorized},
+		{"GET", "/status/test-project", "wrong-token", http.StatusUnauthorized},
+		{"GET", "/status/test-project", "read-token", http.StatusServiceUnavailable}, // Admitted; no history in test mode
+		{"GET", "/status/test-project", "other-token", http.StatusForbidden},
+		{"POST", "/api/projects/test-project/deploy", "read-token", http.StatusForbidden},
+		{"POST", "/api/projects/test-project/restore", "read-token", http.StatusForbidden},
+		{"POST", "/api/projects/test-project/deploy", "other-token", http.StatusForbidden},
+		{"GET", "/status/test-project", "admin-token", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rr := httptest.NewRecorder()
+		server.Router().ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s with %q: expected status %d, got %d", tt.method, tt.path, tt.token, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestRequireScope_StoredToken(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+
+	hist, err := history.NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Hour)
+	for _, token := range []*history.APIToken{
+		{Name: "ops", TokenHash: security.HashToken("ops-token"), Scopes: []string{"rollback"}, Projects: []string{"test-project"}},
+		{Name: "old", TokenHash: security.HashToken("old-token"), Scopes: []string{"admin"}, ExpiresAt: &expired},
+	} {
+		if _, err := hist.CreateToken(ctx, token); err != nil {
+			t.Fatalf("Failed to create token: %v", err)
+		}
+	}
+
+	server := NewServer(registry, hist, slog.New(slog.NewTextHandler(os.Stderr, nil)), false)
+
+	// Expired tokens are rejected
+	req := httptest.NewRequest("GET", "/status/test-project", nil)
+	req.Header.Set("Authorization", "Bearer old-token")
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for expired token, got %d", rr.Code)
+	}
+
+	// A rollback token restores and is recorded as the trigger
+	req = httptest.NewRequest("POST", "/api/projects/test-project/restore", nil)
+	req.Header.Set("Authorization", "Bearer ops-token")
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	server.WaitForDeployments()
+
+	latest, err := hist.GetLatestDeployment(ctx, "test-project")
+	if err != nil || latest == nil {
+		t.Fatalf("Failed to get latest deployment: %v", err)
+	}
+	if latest.Status != "restored" || latest.TriggeredBy == nil || *latest.TriggeredBy != "ops" {
+		t.Errorf("Expected restore by ops, got %s/%v", latest.Status, latest.TriggeredBy)
+	}
+
+	token, err := hist.GetTokenByHash(ctx, security.HashToken("ops-token"))
+	if err != nil || token == nil {
+		t.Fatalf("Failed to get token: %v", err)
+	}
+	if token.LastUsedAt == nil {
+		t.Error("Expected last use to be recorded")
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/history"
+	"deplobox/internal/security"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// DashboardCookie holds the API token of a dashboard login. It is only
+	// sent to /dashboard, so it cannot authenticate API requests.
+	DashboardCookie = "deplobox_token"
+
+	dashboardPath         = "/dashboard"
+	dashboardCookieMaxAge = 12 * time.Hour
+	dashboardDeployments  = 10 // Recent deployments shown per project
+)
+
+//go:embed dashboard/*.html
+var dashboardFiles embed.FS
+
+var dashboardTemplates = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"shortSHA":   shortSHA,
+	"timeFormat": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+	"duration":   formatDurationSeconds,
+}).ParseFS(dashboardFiles, "dashboard/*.html"))
+
+// dashboardProject is a project as shown on the dashboard
+type dashboardProject struct {
+	Name        string
+	Branch      string
+	Provider    string
+	Release     *deployment.Release  // nil if nothing is deployed yet
+	Lock        *deployment.LockInfo // nil unless a deployment or restore holds the lock
+	Deployments []history.DeploymentRecord
+	Error       string // Why the history could not be shown
+}
+
+// dashboardPage is the data of every dashboard page
+type dashboardPage struct {
+	Title      string
+	Principal  *Principal
+	Error      string
+	Projects   []dashboardProject
+	Deployment *history.DeploymentRecord
+	Steps      []history.StepRecord
+}
+
+// DashboardAuth admits dashboard requests carrying an API token, either as a
+// bearer token or in the login cookie. Other requests get the login page.
+func (s *Server) DashboardAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := s.authenticate(r)
+		if principal == nil {
+			if cookie, err := r.Cookie(DashboardCookie); err == nil {
+				principal = s.authenticateToken(r.Context(), cookie.Value)
+			}
+		}
+		if principal == nil || !principal.Allows(security.ScopeRead) {
+			s.renderHTML(w, http.StatusUnauthorized, "login.html", &dashboardPage{Title: "Sign in"})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// HandleDashboard shows every project the token may see, with its current
+// release and recent deployments
+func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+
+	names := s.Registry.List()
+	slices.Sort(names)
+
+	page := &dashboardPage{Title: "Projects", Principal: p}
+	for _, name := range names {
+		if !p.AllowsProject(name) {
+			continue
+		}
+		proj, err := s.Registry.Get(name)
+		if err != nil {
+			continue
+		}
+
+		item := dashboardProject{Name: name, Branch: proj.Branch, Provider: proj.Provider}
+		if current, err := deployment.NewExecutor(proj.Path).CurrentRelease(); err == nil {
+			item.Release = current
+		}
+		if holder, err := deployment.ReadProjectLock(proj.Path); err == nil {
+			item.Lock = holder
+		}
+
+		if s.TestMode {
+			item.Error = "History not available in test mode"
+		} else if item.Deployments, err = s.History.GetDeploymentHistory(r.Context(), name, dashboardDeployments); err != nil {
+			s.Logger.Error("Failed to get deployment history", "error", err, "project", name)
+			item.Error = "Failed to fetch deployment history"
+		}
+
+		page.Projects = append(page.Projects, item)
+	}
+
+	s.renderHTML(w, http.StatusOK, "index.html", page)
+}
+
+// HandleDashboardDeployment shows a deployment and the output of its steps
+func (s *Server) HandleDashboardDeployment(w http.ResponseWriter, r *http.Request) {
+	page := &dashboardPage{Title: "Deployment", Principal: principal(r)}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		page.Error = "Invalid deployment ID"
+		s.renderHTML(w, http.StatusBadRequest, "deployment.html", page)
+		return
+	}
+	page.Title = fmt.Sprintf("Deployment #%d", id)
+
+	if s.TestMode {
+		page.Error = "History not available in test mode"
+		s.renderHTML(w, http.StatusServiceUnavailable, "deployment.html", page)
+		return
+	}
+
+	record, err := s.History.GetDeployment(r.Context(), id)
+	if err != nil {
+		s.Logger.Error("Failed to get deployment", "error", err, "deployment_id", id)
+		page.Error = "Failed to fetch deployment"
+		s.renderHTML(w, http.StatusInternalServerError, "deployment.html", page)
+		return
+	}
+	// Deployments of projects the token may not see look like unknown ones
+	if record == nil || !page.Principal.AllowsProject(record.Project) {
+		page.Error = "Unknown deployment"
+		s.renderHTML(w, http.StatusNotFound, "deployment.html", page)
+		return
+	}
+	page.Deployment = record
+
+	page.Steps, err = s.History.GetDeploymentSteps(r.Context(), id)
+	if err != nil {
+		s.Logger.Error("Failed to get deployment steps", "error", err, "deployment_id", id)
+		page.Error = "Failed to fetch deployment steps"
+		s.renderHTML(w, http.StatusInternalServerError, "deployment.html", page)
+		return
+	}
+
+	s.renderHTML(w, http.StatusOK, "deployment.html", page)
+}
+
+// HandleDashboardLogin checks the token from the login form and keeps it in
+// the dashboard cookie
+func (s *Server) HandleDashboardLogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxPayloadBytes)
+	principal := s.authenticateToken(r.Context(), r.PostFormValue("token"))
+	if principal == nil {
+		s.Logger.Warn("invalid dashboard login", "remote_addr", r.RemoteAddr)
+		s.renderHTML(w, http.StatusUnauthorized, "login.html", &dashboardPage{Title: "Sign in", Error: "Invalid or expired token"})
+		return
+	}
+
+	s.Logger.Info("dashboard login", "token", principal.Name, "remote_addr", r.RemoteAddr)
+	http.SetCookie(w, s.dashboardCookie(r, r.PostFormValue("token"), int(dashboardCookieMaxAge.Seconds())))
+	http.Redirect(w, r, dashboardPath, http.StatusSeeOther)
+}
+
+// HandleDashboardLogout clears the dashboard cookie
+func (s *Server) HandleDashboardLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, s.dashboardCookie(r, "", -1))
+	http.Redirect(w, r, dashboardPath, http.StatusSeeOther)
+}
+
+// dashboardCookie builds the login cookie. It is marked Secure when the
+// request came over HTTPS, directly or through the reverse proxy.
+func (s *Server) dashboardCookie(r *http.Request, token string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     DashboardCookie,
+		Value:    token,
+		Path:     dashboardPath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// renderHTML renders a dashboard template. Pages load nothing from elsewhere,
+// which the Content-Security-Policy enforces.
+func (s *Server) renderHTML(w http.ResponseWriter, statusCode int, name string, page *dashboardPage) {
+	var buf bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&buf, name, page); err != nil {
+		s.Logger.Error("failed to render dashboard page", "error", err, "template", name)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	w.Write(buf.Bytes())
+}
+
+// shortSHA shortens a commit hash for display
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// formatDurationSeconds formats a duration in seconds, e.g. 350ms or 1m12.4s
+func formatDurationSeconds(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+	"deplobox/internal/security"
+)
+
+func newDashboardLogin(token string) *http.Request {
+	req := httptest.NewRequest("POST", "/dashboard/login", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestDashboard_Login(t *testing.T) {
+	server, _ := setupTestServer(t)
+	addTestToken(server, "read")
+
+	// Without a token, the login page
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/dashboard", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `name="token"`) {
+		t.Errorf("Expected the login form, got:\n%s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newDashboardLogin("wrong-token"))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong token, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newDashboardLogin(testAPIToken))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DashboardCookie || cookies[0].Path != "/dashboard" || !cookies[0].HttpOnly {
+		t.Fatalf("Expected an HttpOnly %s cookie for /dashboard, got %+v", DashboardCookie, cookies)
+	}
+
+	// The cookie opens the dashboard
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Expected HTML, got %q", ct)
+	}
+	if !strings.Contains(rr.Body.String(), "test-project") {
+		t.Errorf("Expected test-project on the dashboard, got:\n%s", rr.Body.String())
+	}
+
+	// But not the API
+	req = httptest.NewRequest("GET", "/status/test-project", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for the API with the dashboard cookie, got %d", rr.Code)
+	}
+}
+
+func TestDashboard_Projects(t *testing.T) {
+	server, id := setupRestoredServer(t)
+
+	// Error messages from the history are escaped
+	if _, err := server.History.RecordDeployment(context.Background(), &history.DeploymentRecord{
+		Project:      "test-project",
+		Branch:       "main",
+		Ref:          "refs/heads/main",
+		Status:       "failed",
+		CommitHash:   stringPtrOrNil("abc123def456"),
+		ErrorMessage: stringPtrOrNil("<script>alert(1)</script>"),
+	}); err != nil {
+		t.Fatalf("Failed to record deployment: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/dashboard", nil)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"2024-12-09-10-00-00", // Current release after the restore
+		"/dashboard/deployments/" + strconv.FormatInt(id, 10),
+		"abc123d",
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q on the dashboard, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script>") {
+		t.Error("Expected the error message to be escaped")
+	}
+}
+
+func TestDashboard_Deployment(t *testing.T) {
+	server, id := setupRestoredServer(t)
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/dashboard/deployments/"+strconv.FormatInt(id, 10), nil)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, want := range []string{"post_activate[0]", "$ touch activated", "restored"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("Expected %q on the deployment page, got:\n%s", want, rr.Body.String())
+		}
+	}
+
+	for path, status := range map[string]int{
+		"/dashboard/deployments/999": http.StatusNotFound,
+		"/dashboard/deployments/abc": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		server.Router().ServeHTTP(rr, authorized(httptest.NewRequest("GET", path, nil)))
+		if rr.Code != status {
+			t.Errorf("GET %s: expected status %d, got %d", path, status, rr.Code)
+		}
+	}
+}
+
+func TestDashboard_ProjectRestriction(t *testing.T) {
+	server, id := setupRestoredServer(t)
+	server.AdminTokens = []project.AdminToken{
+		{Name: "other", SHA256: security.HashToken("other-token"), Scopes: []string{"read"}, Projects: []string{"other-project"}},
+	}
+
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	req.Header.Set("Authorization", "Bearer other-token")
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "test-project") {
+		t.Error("Expected test-project to be hidden from a token for other-project")
+	}
+
+	req = httptest.NewRequest("GET", "/dashboard/deployments/"+strconv.FormatInt(id, 10), nil)
+	req.Header.Set("Authorization", "Bearer other-token")
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another project's deployment, got %d", rr.Code)
+	}
+}
+
+func TestDashboard_NotGloballyRateLimited(t *testing.T) {
+	server, _ := setupRestoredServer(t)
+	router := server.Router()
+
+	// Page loads and refreshes from one browser go past the global limit
+	for i := 0; i < GlobalRateLimit+5; i++ {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/dashboard", nil)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i+1, rr.Code)
+		}
+	}
+
+	// Login attempts have their own limit
+	for i := 0; i < LoginRateLimit; i++ {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newDashboardLogin("wrong-token"))
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Login %d: expected status 401, got %d", i+1, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newDashboardLogin("wrong-token"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 once the login limit is used up, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"deplobox/internal/history"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HandleDeployment returns a deployment and its recorded steps
+func (s *Server) HandleDeployment(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.lookupDeployment(w, r)
+	if !ok {
+		return
+	}
+
+	steps, err := s.History.GetDeploymentSteps(r.Context(), record.ID)
+	if err != nil {
+		s.Logger.Error("Failed to get deployment steps", "error", err, "deployment_id", record.ID)
+		s.respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch deployment steps"})
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deployment": record,
+		"steps":      steps,
+	})
+}
+
+// lookupDeployment loads the deployment named by the {id} URL parameter,
+// writing the error response if it cannot
+func (s *Server) lookupDeployment(w http.ResponseWriter, r *http.Request) (*history.DeploymentRecord, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid deployment ID"})
+		return nil, false
+	}
+
+	// Check if history is available
+	if s.TestMode {
+		s.respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "History not available in test mode"})
+		return nil, false
+	}
+
+	record, err := s.History.GetDeployment(r.Context(), id)
+	if err != nil {
+		s.Logger.Error("Failed to get deployment", "error", err, "deployment_id", id)
+		s.respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to fetch deployment"})
+		return nil, false
+	}
+	if record == nil {
+		s.respondJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown deployment"})
+		return nil, false
+	}
+
+	// Tokens restricted to other projects must not see this one's deployments
+	if p := principal(r); p != nil && !p.AllowsProject(record.Project) {
+		s.respondJSON(w, http.StatusForbidden, map[string]string{"error": "Token not allowed for this project"})
+		return nil, false
+	}
+
+	return record, true
+}
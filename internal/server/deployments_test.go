@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+)
+
+// setupRestoredServer runs a restore with post_activate and returns the server
+// and the ID of its history record
+func setupRestoredServer(t *testing.T) (*Server, int64) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+
+	hist, err := history.NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	t.Cleanup(func() { hist.Close() })
+
+	server := NewServer(registry, hist, slog.New(slog.NewTextHandler(os.Stderr, nil)), false)
+	addTestToken(server, "rollback")
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest([]byte(`{"run_post_activate":true}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	server.WaitForDeployments()
+
+	latest, err := hist.GetLatestDeployment(context.Background(), "test-project")
+	if err != nil || latest == nil {
+		t.Fatalf("Failed to get latest deployment: %v", err)
+	}
+	return server, latest.ID
+}
+
+func TestHandleDeployment(t *testing.T) {
+	server, id := setupRestoredServer(t)
+
+	req := authorized(httptest.NewRequest("GET", "/deployments/"+strconv.FormatInt(id, 10), nil))
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Deployment history.DeploymentRecord
+		Steps      []history.StepRecord
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Deployment.Status != "restored" {
+		t.Errorf("Expected status 'restored', got '%s'", response.Deployment.Status)
+	}
+	if len(response.Steps) != 1 || response.Steps[0].Step != "post_activate[0]" || response.Steps[0].Command != "touch activated" {
+		t.Errorf("Expected the post_activate step, got %+v", response.Steps)
+	}
+
+	for path, status := range map[string]int{
+		"/deployments/999": http.StatusNotFound,
+		"/deployments/abc": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		server.Router().ServeHTTP(rr, authorized(httptest.NewRequest("GET", path, nil)))
+		if rr.Code != status {
+			t.Errorf("GET %s: expected status %d, got %d", path, status, rr.Code)
+		}
+	}
+}
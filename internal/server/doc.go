@@ -2,19 +2,26 @@
 //
 // This package provides:
 //   - GitHub webhook endpoint handling with HMAC signature verification
+//   - GitLab, Gitea/Forgejo and Bitbucket Cloud push webhooks via WebhookProvider
 //   - Per-IP rate limiting to prevent abuse and DDoS attacks
 //   - Health and status endpoints for monitoring
+//   - Prometheus metrics at /metrics, optionally on a separate listener
+//   - Read-only HTML dashboard at /dashboard, rendered from embedded templates
+//   - Token-authenticated manual deploy and restore API with scoped tokens
 //   - Structured logging of all HTTP requests
 //
 // The server integrates with other packages:
 //   - internal/project: Project configuration and validation
 //   - internal/deployment: Git pull and post-deploy command execution
 //   - internal/history: SQLite-based deployment history tracking
+//   - internal/report: GitHub Deployments reporting for projects with a github_reporter
 //
 // Security features:
 //   - HMAC-SHA256 webhook signature verification
 //   - Content-Type validation (application/json only)
 //   - Payload size limits (1MB max)
 //   - Rate limiting (global and per-webhook)
+//   - Bearer API tokens with scopes and project restrictions for all other endpoints
 //   - Per-project deployment locking (prevents concurrent deployments)
+//   - Per-project pending slot that coalesces pushes arriving mid-deployment
 package server
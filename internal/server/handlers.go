@@ -21,7 +21,7 @@ const (
 	RecentDeploymentsLimit = 10        // Number of recent deployments to return in status endpoint
 )
 
-// HandleWebhook handles GitHub webhook requests
+// HandleWebhook handles push webhook requests from the project's configured provider
 func (s *Server) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	projectName := chi.URLParam(r, "projectName")
 	s.Logger.Info("webhook received", "project", projectName, "remote_addr", r.RemoteAddr)
@@ -67,16 +67,31 @@ func (s *Server) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 	s.Logger.Debug("payload read", "project", projectName, "bytes", len(body))
 
+	// Look up the webhook provider for this project
+	provider, ok := ProviderFor(proj.Provider)
+	if !ok {
+		s.Logger.Error("unsupported webhook provider", "project", projectName, "provider", proj.Provider)
+		s.respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "Unsupported webhook provider"})
+		return
+	}
+
 	// Verify signature
-	signature := r.Header.Get("X-Hub-Signature-256")
-	s.Logger.Debug("verifying signature", "project", projectName, "signature_present", signature != "")
-	if !VerifySignature(body, signature, proj.Secret) {
-		s.Logger.Warn("invalid signature", "project", projectName)
+	s.Logger.Debug("verifying signature", "project", projectName, "provider", provider.Name())
+	if !provider.VerifySignature(r, body, proj.Secret) {
+		s.Logger.Warn("invalid signature", "project", projectName, "provider", provider.Name())
+		s.Metrics.SignatureFailures.Inc(projectName, provider.Name())
 		s.respondJSON(w, http.StatusForbidden, map[string]string{"error": "Invalid signature"})
 		return
 	}
 	s.Logger.Debug("signature verified", "project", projectName)
 
+	// Only push events trigger deployments
+	if !provider.IsPushEvent(r) {
+		s.Logger.Info("ignoring non-push event", "project", projectName, "provider", provider.Name())
+		s.respondJSON(w, http.StatusOK, map[string]string{"message": "Ignoring non-push event"})
+		return
+	}
+
 	// Parse JSON payload
 	var payload map[string]interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
@@ -91,13 +106,21 @@ func (s *Server) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract ref for logging
-	ref, _ := payload["ref"].(string)
-	s.Logger.Info("payload parsed", "project", projectName, "ref", ref, "target_branch", proj.Branch)
+	// Extract provider-independent push details
+	push := provider.ParsePush(payload)
+	ref := push.Ref
+	s.Logger.Info("payload parsed", "project", projectName, "ref", ref, "commit", push.Commit, "pusher", push.Pusher, "target_branch", proj.Branch)
+
+	// Deleting a branch pushes the zero SHA, which cannot be checked out
+	if push.DeletesRef() {
+		s.Logger.Info("branch deleted, ignoring", "project", projectName, "ref", ref)
+		s.respondJSON(w, http.StatusOK, map[string]string{"message": "Branch deletion ignored"})
+		return
+	}
 
 	// Check if this is a target branch before acquiring lock
 	// This allows us to respond immediately for non-target branches
-	deploy := deployment.NewDeployment(proj, payload, s.ExposeOutput, s.Logger)
+	deploy := deployment.NewDeployment(proj, push, s.ExposeOutput, s.Logger)
 	shouldDeploy := deploy.ShouldDeploy()
 	s.Logger.Debug("checking if should deploy", "project", projectName, "ref", ref, "target_branch", proj.Branch, "should_deploy", shouldDeploy)
 	if !shouldDeploy {
@@ -106,30 +129,41 @@ func (s *Server) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Try to acquire deployment lock
-	s.Logger.Debug("attempting to acquire lock", "project", projectName)
-	if !s.LockManager.TryLock(projectName) {
-		s.Logger.Warn("deployment already in progress, rejecting", "project", projectName)
-
-		// Record rejected deployment
-		if !s.TestMode {
-			ref, _ := payload["ref"].(string)
-			if _, err := s.History.RecordDeployment(r.Context(), &history.DeploymentRecord{
-				Project:      projectName,
-				Branch:       proj.Branch,
-				Ref:          ref,
-				Status:       "rejected",
-				ErrorMessage: stringPtr("Deployment already in progress"),
-			}); err != nil {
-				s.Logger.Error("Failed to record rejection in history", "error", err, "project", projectName)
-			}
+	s.submitDeployment(w, r, projectName, proj, push)
+}
+
+// submitDeployment starts a deployment of push, or queues it behind the one
+// already running, and responds with 202 either way
+func (s *Server) submitDeployment(w http.ResponseWriter, r *http.Request, projectName string, proj *project.Project, push *deployment.PushEvent) {
+	s.Logger.Debug("submitting deployment", "project", projectName)
+
+	// The push is recorded as queued before it is submitted, so its record
+	// exists by the time it is superseded or handed over. Starting the
+	// deployment turns this record into the in_progress one.
+	item := &deployment.QueuedDeployment{
+		Push:     push,
+		QueuedAt: time.Now(),
+		RecordID: s.recordQueued(r.Context(), proj, push),
+	}
+	started, superseded := s.Queue.Submit(projectName, item)
+	if !started {
+		s.Logger.Info("deployment already in progress, queued", "project", projectName, "commit", push.Commit)
+
+		if superseded != nil {
+			s.Logger.Info("queued deployment superseded", "project", projectName, "commit", superseded.Push.Commit, "superseded_by", push.Commit)
+			s.recordSuperseded(r.Context(), projectName, superseded, fmt.Sprintf("Superseded by newer push %s", push.Commit))
+			s.Metrics.Deployments.Inc(projectName, "superseded")
 		}
 
-		s.respondJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Deployment already in progress"})
+		s.respondJSON(w, http.StatusAccepted, map[string]string{
+			"message": "Deployment queued",
+			"project": projectName,
+		})
 		return
 	}
 
 	s.Logger.Info("lock acquired, starting async deployment", "project", projectName)
+	s.Metrics.LockWait.Observe(0, projectName, lockQueue)
 
 	// Respond immediately to GitHub to avoid timeout
 	// GitHub webhooks have a 10-second timeout, so we acknowledge receipt
@@ -140,23 +174,145 @@ func (s *Server) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Execute deployment asynchronously
-	s.deployWg.Add(1)
 	s.Logger.Info("spawning deployment goroutine", "project", projectName)
-	go func() {
-		defer s.deployWg.Done()
-		defer s.LockManager.Unlock(projectName)
+	s.runAsync(func() {
 		s.Logger.Info("deployment goroutine started", "project", projectName)
-		s.executeDeployment(context.Background(), projectName, proj, payload)
-	}()
+		s.executeDeployment(context.Background(), projectName, proj, push, item.RecordID)
+		s.runQueuedDeployments(projectName, proj)
+	})
+}
+
+// runQueuedDeployments deploys the newest push that arrived while the project
+// lock was held; Done releases the lock when none is left
+func (s *Server) runQueuedDeployments(projectName string, proj *project.Project) {
+	for next := s.Queue.Done(projectName); next != nil; next = s.Queue.Done(projectName) {
+		waited := time.Since(next.QueuedAt)
+		s.Logger.Info("starting queued deployment", "project", projectName, "commit", next.Push.Commit, "waited_ms", waited.Milliseconds())
+		s.Metrics.LockWait.Observe(waited.Seconds(), projectName, lockQueue)
+		s.executeDeployment(context.Background(), projectName, proj, next.Push, next.RecordID)
+	}
+}
+
+// recordQueued records a push waiting to be deployed in the history.
+// Returns the record ID, or 0 if nothing was recorded.
+func (s *Server) recordQueued(ctx context.Context, proj *project.Project, push *deployment.PushEvent) int64 {
+	if s.TestMode {
+		return 0
+	}
+
+	id, err := s.History.RecordDeployment(ctx, &history.DeploymentRecord{
+		Project:     proj.Name,
+		Branch:      projectForPush(proj, push).Branch,
+		Ref:         push.Ref,
+		Status:      "queued",
+		CommitHash:  stringPtrOrNil(push.Commit),
+		Trigger:     push.TriggerName(),
+		TriggeredBy: stringPtrOrNil(push.Pusher),
+	})
+	if err != nil {
+		s.Logger.Error("Failed to record queued deployment in history", "error", err, "project", proj.Name)
+		return 0
+	}
+	return id
 }
 
-// executeDeployment runs the deployment and records history
-func (s *Server) executeDeployment(ctx context.Context, projectName string, proj *project.Project, payload map[string]interface{}) {
+// recordSuperseded marks the record of a queued push that will not be
+// deployed as superseded
+func (s *Server) recordSuperseded(ctx context.Context, projectName string, item *deployment.QueuedDeployment, message string) {
+	if item.RecordID == 0 {
+		return
+	}
+
+	if err := s.History.UpdateDeployment(ctx, &history.DeploymentRecord{
+		ID:           item.RecordID,
+		Status:       "superseded",
+		ErrorMessage: stringPtrOrNil(message),
+	}); err != nil {
+		s.Logger.Error("Failed to record superseded deployment in history", "error", err, "project", projectName)
+	}
+}
+
+// recordInProgress records a deployment or restore that is starting as
+// in_progress, reusing the record made when it was queued, if any. Returns
+// the record ID, or 0 if nothing was recorded.
+func (s *Server) recordInProgress(ctx context.Context, proj *project.Project, push *deployment.PushEvent, queuedID int64) int64 {
+	if s.TestMode {
+		return 0
+	}
+
+	record := &history.DeploymentRecord{
+		ID:          queuedID,
+		Project:     proj.Name,
+		Branch:      proj.Branch,
+		Ref:         push.Ref,
+		Status:      "in_progress",
+		CommitHash:  stringPtrOrNil(push.Commit),
+		Trigger:     push.TriggerName(),
+		TriggeredBy: stringPtrOrNil(push.Pusher),
+	}
+	if queuedID != 0 {
+		// The deployment starts now, not when it was queued
+		record.StartedAt = time.Now()
+		if err := s.History.UpdateDeployment(ctx, record); err != nil {
+			s.Logger.Error("Failed to record deployment start in history", "error", err, "project", proj.Name)
+		}
+		return queuedID
+	}
+
+	id, err := s.History.RecordDeployment(ctx, record)
+	if err != nil {
+		s.Logger.Error("Failed to record deployment start in history", "error", err, "project", proj.Name)
+		return 0
+	}
+	return id
+}
+
+// finishRecord records the outcome of a deployment or restore, updating its
+// in_progress record if one was made
+func (s *Server) finishRecord(ctx context.Context, recordID int64, record *history.DeploymentRecord) {
+	var err error
+	if recordID != 0 {
+		record.ID = recordID
+		err = s.History.UpdateDeployment(ctx, record)
+	} else {
+		_, err = s.History.RecordDeployment(ctx, record)
+	}
+	if err != nil {
+		s.Logger.Error("Failed to record deployment history", "error", err, "project", record.Project, "status", record.Status)
+	}
+}
+
+// observeDeployment updates the metrics for a finished deployment or restore
+func (s *Server) observeDeployment(projectName, status string, d *deployment.Deployment) {
+	s.Metrics.Deployments.Inc(projectName, status)
+
+	// LockWait stays zero if the deployment failed before taking the lock file
+	if d.LockWait > 0 || d.LockRejected {
+		s.Metrics.LockWait.Observe(d.LockWait.Seconds(), projectName, lockFile)
+	}
+	if d.LockRejected {
+		s.Metrics.LockRejections.Inc(projectName, lockFile)
+	}
+}
+
+// executeDeployment runs the deployment and records history, in the record
+// made when the push was queued if queuedID is set
+func (s *Server) executeDeployment(ctx context.Context, projectName string, proj *project.Project, push *deployment.PushEvent, queuedID int64) {
 	s.Logger.Info("executeDeployment: starting", "project", projectName)
 	startTime := time.Now()
 
-	// Create deployment
-	deploy := deployment.NewDeployment(proj, payload, s.ExposeOutput, s.Logger)
+	// Manual deployments may target another branch than the configured one
+	proj = projectForPush(proj, push)
+
+	// Record the deployment as in progress, so a crash leaves a trace to recover
+	recordID := s.recordInProgress(ctx, proj, push, queuedID)
+
+	// Create deployment, recording its steps and reporting the commit it
+	// checks out to GitHub
+	deploy := deployment.NewDeployment(proj, push, s.ExposeOutput, s.Logger)
+	deploy.Executor.Observer = s.newStepRecorder(projectName, recordID)
+	var githubDeployment *githubDeployment
+	deploy.OnCommit = func(sha string) { githubDeployment = s.startGitHubReport(proj, sha) }
 
 	// Execute
 	response, statusCode := deploy.Execute(ctx)
@@ -164,41 +320,46 @@ func (s *Server) executeDeployment(ctx context.Context, projectName string, proj
 	// Calculate duration
 	duration := time.Since(startTime).Seconds()
 
+	var status string
+	var errorMsg *string
+	if statusCode == 200 {
+		if msg, ok := response["message"].(string); ok && msg == "Deployment successful" {
+			status = "success"
+		} else {
+			status = "skipped"
+		}
+	} else {
+		status = "failed"
+		if deploy.RolledBack {
+			status = "rolled_back"
+		}
+		if errStr, ok := response["error"].(string); ok {
+			errorMsg = &errStr
+		}
+	}
+
 	// Record history
 	if !s.TestMode {
-		ref, _ := payload["ref"].(string)
-		commitHash, _ := payload["after"].(string)
-
-		var status string
-		var errorMsg *string
-
-		if statusCode == 200 {
-			if msg, ok := response["message"].(string); ok && msg == "Deployment successful" {
-				status = "success"
-			} else {
-				status = "skipped"
-			}
-		} else {
-			status = "failed"
-			if errStr, ok := response["error"].(string); ok {
-				errorMsg = &errStr
-			}
+		// Prefer the SHA actually checked out over the one claimed by the payload
+		commitHash := deploy.CommitHash
+		if commitHash == "" {
+			commitHash = push.Commit
 		}
 
-		_, err := s.History.RecordDeployment(ctx, &history.DeploymentRecord{
+		s.finishRecord(ctx, recordID, &history.DeploymentRecord{
 			Project:         projectName,
 			Branch:          proj.Branch,
-			Ref:             ref,
+			Ref:             push.Ref,
 			Status:          status,
 			DurationSeconds: &duration,
 			CommitHash:      stringPtrOrNil(commitHash),
 			ErrorMessage:    errorMsg,
+			Trigger:         push.TriggerName(),
+			TriggeredBy:     stringPtrOrNil(push.Pusher),
 		})
-
-		if err != nil {
-			s.Logger.Error("Failed to record deployment history", "error", err, "project", projectName)
-		}
 	}
+	s.observeDeployment(projectName, status, deploy)
+	s.finishGitHubReport(projectName, githubDeployment, status, errorMsg)
 
 	// Log final status (we already responded to GitHub)
 	if statusCode == 200 {
@@ -233,7 +394,7 @@ func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if project exists
-	_, err := s.Registry.Get(projectName)
+	proj, err := s.Registry.Get(projectName)
 	if err != nil {
 		s.respondJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown project"})
 		return
@@ -267,6 +428,20 @@ func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
 		"recent_deployments": recent,
 	}
 
+	// Describe the live release from its manifest, if it has one
+	if current, err := deployment.NewExecutor(proj.Path).CurrentRelease(); err == nil {
+		currentRelease := map[string]interface{}{"release": current.Name}
+		if current.Manifest != nil {
+			currentRelease["manifest"] = current.Manifest
+		}
+		response["current_release"] = currentRelease
+	}
+
+	// Show who holds the project lock, including the CLI and other instances
+	if holder, err := deployment.ReadProjectLock(proj.Path); err == nil && holder != nil {
+		response["lock"] = holder
+	}
+
 	s.respondJSON(w, http.StatusOK, response)
 }
 
@@ -282,10 +457,6 @@ func (s *Server) respondJSON(w http.ResponseWriter, statusCode int, data interfa
 }
 
 // Helper functions
-func stringPtr(s string) *string {
-	return &s
-}
-
 func stringPtrOrNil(s string) *string {
 	if s == "" {
 		return nil
@@ -96,6 +96,10 @@ func TestHandleWebhook_InvalidSignature(t *testing.T) {
 		t.Errorf("Expected 'Invalid signature' error, got %v", response)
 	}
 
+	if got := server.Metrics.SignatureFailures.Value("test-project", "github"); got != 1 {
+		t.Errorf("Expected 1 signature failure in metrics, got %v", got)
+	}
+
 	_ = testProject
 }
 
@@ -214,6 +218,35 @@ func TestHandleWebhook_NonTargetBranch(t *testing.T) {
 	}
 }
 
+func TestHandleWebhook_BranchDeleted(t *testing.T) {
+	server, testProject := setupTestServer(t)
+
+	payload := []byte(`{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000","deleted":true}`)
+	signature := makeTestSignature(payload, testProject.Secret)
+
+	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", signature)
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	var response map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+
+	if response["message"] != "Branch deletion ignored" {
+		t.Errorf("Expected 'Branch deletion ignored' message, got %v", response)
+	}
+	if server.Queue.Pending("test-project") != nil || !server.Queue.TryAcquire("test-project") {
+		t.Error("Expected no deployment to be started or queued")
+	}
+}
+
 func TestHandleWebhook_ConcurrentDeployment(t *testing.T) {
 	server, testProject := setupTestServer(t)
 
@@ -221,7 +254,7 @@ func TestHandleWebhook_ConcurrentDeployment(t *testing.T) {
 	server.LockManager.TryLock("test-project")
 	defer server.LockManager.Unlock("test-project")
 
-	payload := []byte(`{"ref":"refs/heads/main"}`)
+	payload := []byte(`{"ref":"refs/heads/main","after":"abc123"}`)
 	signature := makeTestSignature(payload, testProject.Secret)
 
 	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
@@ -232,15 +265,100 @@ func TestHandleWebhook_ConcurrentDeployment(t *testing.T) {
 	rr := httptest.NewRecorder()
 	server.Router().ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusTooManyRequests {
-		t.Errorf("Expected status 429, got %d", rr.Code)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", rr.Code)
 	}
 
 	var response map[string]string
 	_ = json.Unmarshal(rr.Body.Bytes(), &response)
 
-	if response["error"] != "Deployment already in progress" {
-		t.Errorf("Expected 'Deployment already in progress' error, got %v", response)
+	if response["message"] != "Deployment queued" {
+		t.Errorf("Expected 'Deployment queued' message, got %v", response)
+	}
+
+	pending := server.Queue.Pending("test-project")
+	if pending == nil || pending.Push.Commit != "abc123" {
+		t.Errorf("Expected push abc123 to be pending, got %+v", pending)
+	}
+}
+
+func TestHandleWebhook_QueuedPushSuperseded(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testProject := &project.Project{
+		Name:   "test-project",
+		Path:   tmpDir,
+		Secret: "test-secret-at-least-32-chars-long-here",
+		Branch: "main",
+	}
+	registry := project.NewRegistry(map[string]*project.Project{
+		"test-project": testProject,
+	})
+
+	hist, err := history.NewHistory(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	server := NewServer(registry, hist, logger, false)
+	router := server.Router()
+
+	// Simulate an in-progress deployment
+	server.LockManager.TryLock("test-project")
+
+	for _, commit := range []string{"aaa111", "bbb222"} {
+		payload := []byte(`{"ref":"refs/heads/main","after":"` + commit + `"}`)
+		req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", makeTestSignature(payload, testProject.Secret))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202 for %s, got %d", commit, rr.Code)
+		}
+	}
+
+	// Only the newest push stays queued
+	if pending := server.Queue.Pending("test-project"); pending == nil || pending.Push.Commit != "bbb222" {
+		t.Errorf("Expected bbb222 to be pending, got %+v", pending)
+	}
+
+	// Each push keeps a single history record
+	assertHistory(t, hist, []struct{ status, commit string }{
+		{"queued", "bbb222"},
+		{"superseded", "aaa111"},
+	})
+
+	// Running the queued push updates its record. The project has no
+	// repository to clone from, so the deployment fails.
+	server.runQueuedDeployments("test-project", testProject)
+	assertHistory(t, hist, []struct{ status, commit string }{
+		{"failed", "bbb222"},
+		{"superseded", "aaa111"},
+	})
+}
+
+// assertHistory checks the status and commit of test-project's history
+// records, newest first
+func assertHistory(t *testing.T, hist *history.History, expected []struct{ status, commit string }) {
+	t.Helper()
+
+	records, err := hist.GetDeploymentHistory(context.Background(), "test-project", 10)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d history records, got %d", len(expected), len(records))
+	}
+	for i, e := range expected {
+		if records[i].Status != e.status || records[i].CommitHash == nil || *records[i].CommitHash != e.commit {
+			t.Errorf("Record %d: expected %s/%s, got %s/%v", i, e.status, e.commit, records[i].Status, records[i].CommitHash)
+		}
 	}
 }
 
@@ -280,8 +398,9 @@ func TestHandleHealth(t *testing.T) {
 
 func TestHandleStatus_UnknownProject(t *testing.T) {
 	server, _ := setupTestServer(t)
+	addTestToken(server, "read")
 
-	req := httptest.NewRequest("GET", "/status/unknown-project", nil)
+	req := authorized(httptest.NewRequest("GET", "/status/unknown-project", nil))
 	rr := httptest.NewRecorder()
 
 	server.Router().ServeHTTP(rr, req)
@@ -293,8 +412,9 @@ func TestHandleStatus_UnknownProject(t *testing.T) {
 
 func TestHandleStatus_TestMode(t *testing.T) {
 	server, _ := setupTestServer(t)
+	addTestToken(server, "read")
 
-	req := httptest.NewRequest("GET", "/status/test-project", nil)
+	req := authorized(httptest.NewRequest("GET", "/status/test-project", nil))
 	rr := httptest.NewRecorder()
 
 	server.Router().ServeHTTP(rr, req)
@@ -354,8 +474,9 @@ func TestHandleStatus_Success(t *testing.T) {
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	server := NewServer(registry, hist, logger, false) // NOT test mode
+	addTestToken(server, "read")
 
-	req := httptest.NewRequest("GET", "/status/test-project", nil)
+	req := authorized(httptest.NewRequest("GET", "/status/test-project", nil))
 	rr := httptest.NewRecorder()
 
 	server.Router().ServeHTTP(rr, req)
@@ -379,3 +500,133 @@ func TestHandleStatus_Success(t *testing.T) {
 		t.Error("Expected recent_deployments to be present")
 	}
 }
+
+func setupGitLabTestServer(t *testing.T) (*Server, *project.Project) {
+	server, testProject := setupTestServer(t)
+	testProject.Provider = project.ProviderGitLab
+	return server, testProject
+}
+
+func loadTestPayload(t *testing.T, name string) []byte {
+	payload, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read test payload %s: %v", name, err)
+	}
+	return payload
+}
+
+func TestHandleWebhook_GitLabPush(t *testing.T) {
+	server, testProject := setupGitLabTestServer(t)
+
+	payload := loadTestPayload(t, "gitlab_push.json")
+
+	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitlab-Event", "Push Hook")
+	req.Header.Set("X-Gitlab-Token", testProject.Secret)
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	server.WaitForDeployments()
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", rr.Code)
+	}
+
+	var response map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+
+	if response["message"] != "Deployment accepted" {
+		t.Errorf("Expected 'Deployment accepted' message, got %v", response)
+	}
+}
+
+func TestHandleWebhook_GitLabInvalidToken(t *testing.T) {
+	server, _ := setupGitLabTestServer(t)
+
+	payload := loadTestPayload(t, "gitlab_push.json")
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"wrong token", map[string]string{"X-Gitlab-Token": "wrong-secret-32-chars-long-xxxxxxx"}},
+		{"missing token", map[string]string{}},
+		{"github signature instead of token", map[string]string{"X-Hub-Signature-256": makeTestSignature(payload, "test-secret-at-least-32-chars-long-here")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gitlab-Event", "Push Hook")
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			rr := httptest.NewRecorder()
+			server.Router().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("Expected status 403, got %d", rr.Code)
+			}
+
+			var response map[string]string
+			_ = json.Unmarshal(rr.Body.Bytes(), &response)
+
+			if response["error"] != "Invalid signature" {
+				t.Errorf("Expected 'Invalid signature' error, got %v", response)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_GitLabNonPushEvent(t *testing.T) {
+	server, testProject := setupGitLabTestServer(t)
+
+	payload := []byte(`{"object_kind":"merge_request"}`)
+
+	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitlab-Event", "Merge Request Hook")
+	req.Header.Set("X-Gitlab-Token", testProject.Secret)
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	var response map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+
+	if response["message"] != "Ignoring non-push event" {
+		t.Errorf("Expected 'Ignoring non-push event' message, got %v", response)
+	}
+}
+
+func TestHandleWebhook_GitLabNonTargetBranch(t *testing.T) {
+	server, testProject := setupGitLabTestServer(t)
+
+	payload := loadTestPayload(t, "gitlab_push_develop.json")
+
+	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitlab-Event", "Push Hook")
+	req.Header.Set("X-Gitlab-Token", testProject.Secret)
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	var response map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+
+	if response["message"] != "Not target branch, skipping" {
+		t.Errorf("Expected 'Not target branch, skipping' message, got %v", response)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import "deplobox/internal/metrics"
+
+// Lock label values of the lock metrics
+const (
+	lockQueue = "queue" // The in-process DeployQueue / LockManager
+	lockFile  = "file"  // The cross-process project lock file
+)
+
+var (
+	// stepDurationBuckets covers quick file operations up to long builds
+	stepDurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+	// lockWaitBuckets covers an uncontended lock up to the lock timeout
+	lockWaitBuckets = []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 15, 30, 60, 120, 300}
+)
+
+// Metrics are the server's Prometheus metrics
+type Metrics struct {
+	Registry          *metrics.Registry
+	Deployments       *metrics.Counter   // project, status
+	StepDuration      *metrics.Histogram // project, step
+	LockWait          *metrics.Histogram // project, lock
+	LockRejections    *metrics.Counter   // project, lock
+	RateLimited       *metrics.Counter   // limiter
+	SignatureFailures *metrics.Counter   // project, provider
+}
+
+// NewMetrics registers the server metrics. inFlight reports the number of
+// deployments and restores running in the background.
+func NewMetrics(inFlight func() float64) *Metrics {
+	registry := metrics.NewRegistry()
+
+	m := &Metrics{
+		Registry: registry,
+		Deployments: registry.NewCounter("deplobox_deployments_total",
+			"Finished deployments and restores by project and status (success, failed, skipped, rolled_back, restored, superseded, aborted).",
+			"project", "status"),
+		StepDuration: registry.NewHistogram("deplobox_deployment_step_duration_seconds",
+			"Duration of deployment steps such as git_clone or post_deploy[0].",
+			stepDurationBuckets, "project", "step"),
+		LockWait: registry.NewHistogram("deplobox_lock_wait_seconds",
+			"Time deployments waited for the project lock, in the deploy queue or for the lock file held by another process.",
+			lockWaitBuckets, "project", "lock"),
+		LockRejections: registry.NewCounter("deplobox_lock_rejections_total",
+			"Deployments and restores rejected because the project lock was held.",
+			"project", "lock"),
+		RateLimited: registry.NewCounter("deplobox_rate_limit_rejections_total",
+			"Requests rejected by a rate limiter (global, webhook or login).",
+			"limiter"),
+		SignatureFailures: registry.NewCounter("deplobox_signature_failures_total",
+			"Webhooks with an invalid signature or token.",
+			"project", "provider"),
+	}
+	registry.NewGaugeFunc("deplobox_deployments_in_flight",
+		"Deployments and restores currently running in the background.",
+		inFlight)
+
+	return m
+}
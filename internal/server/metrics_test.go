@@ -0,0 +1,124 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"deplobox/internal/metrics"
+	"deplobox/internal/project"
+)
+
+func TestMetrics_RequiresToken(t *testing.T) {
+	server, _ := setupTestServer(t)
+	addTestToken(server, "read")
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/metrics", nil)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Expected content type %q, got %q", metrics.ContentType, ct)
+	}
+	if !strings.Contains(rr.Body.String(), "deplobox_deployments_in_flight 0\n") {
+		t.Errorf("Expected in-flight gauge in output, got:\n%s", rr.Body.String())
+	}
+}
+
+func TestMetrics_NotGloballyRateLimited(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+	server := NewServer(registry, nil, slog.New(slog.NewTextHandler(os.Stderr, nil)), false)
+	addTestToken(server, "read")
+	router := server.Router()
+
+	// A scraper polls more often than the global limit allows
+	for i := 0; i < GlobalRateLimit+5; i++ {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorized(httptest.NewRequest("GET", "/metrics", nil)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Scrape %d: expected status 200, got %d", i+1, rr.Code)
+		}
+	}
+}
+
+func TestMetrics_SeparateListener(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.MetricsAddr = "127.0.0.1:9100"
+
+	// Not served on the main router at all
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 on the main router, got %d", rr.Code)
+	}
+
+	// Served without a token on the metrics listener
+	rr = httptest.NewRecorder()
+	server.MetricsRouter().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on the metrics router, got %d", rr.Code)
+	}
+}
+
+func TestMetrics_Restore(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+	server := NewServer(registry, nil, slog.New(slog.NewTextHandler(os.Stderr, nil)), true)
+	addTestToken(server, "rollback")
+
+	// Rejected while the project is locked
+	server.Queue.TryAcquire("test-project")
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest([]byte(`{}`)))
+	server.Queue.Done("test-project")
+	if got := server.Metrics.LockRejections.Value("test-project", lockQueue); got != 1 {
+		t.Errorf("Expected 1 queue lock rejection, got %v", got)
+	}
+
+	// Restored
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest([]byte(`{"steps":1,"run_post_activate":true}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	server.WaitForDeployments()
+
+	if got := server.Metrics.Deployments.Value("test-project", "restored"); got != 1 {
+		t.Errorf("Expected 1 restored deployment, got %v", got)
+	}
+	if got := server.Metrics.LockWait.Count("test-project", lockFile); got != 1 {
+		t.Errorf("Expected 1 lock file wait observation, got %d", got)
+	}
+	if got := server.Metrics.StepDuration.Count("test-project", "post_activate[0]"); got != 1 {
+		t.Errorf("Expected 1 post_activate[0] duration observation, got %d", got)
+	}
+	if got := server.inFlight.Load(); got != 0 {
+		t.Errorf("Expected no deployments in flight, got %d", got)
+	}
+}
+
+func TestMetrics_RateLimited(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	limited := NewWebhookRateLimitMiddleware(1, server.Logger, func() { server.Metrics.RateLimited.Inc("webhook") })
+	handler := limited(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/in/test-project", nil))
+	}
+
+	if got := server.Metrics.RateLimited.Value("webhook"); got != 2 {
+		t.Errorf("Expected 2 rate-limited requests, got %v", got)
+	}
+}
@@ -44,7 +44,8 @@ func (rl *RateLimiter) GetLimiter(ip string) *rate.Limiter {
 
 // NewRateLimitMiddleware creates middleware for global rate limiting
 // hourLimit: requests per hour
-func NewRateLimitMiddleware(hourLimit int, logger *slog.Logger) func(http.Handler) http.Handler {
+// onReject: called for each rejected request, may be nil
+func NewRateLimitMiddleware(hourLimit int, logger *slog.Logger, onReject func()) func(http.Handler) http.Handler {
 	// Use the more restrictive limit (hour limit is usually tighter)
 	// Convert to requests per second
 	rps := rate.Limit(float64(hourLimit) / 3600.0)
@@ -56,6 +57,9 @@ func NewRateLimitMiddleware(hourLimit int, logger *slog.Logger) func(http.Handle
 
 			if !limiter.GetLimiter(ip).Allow() {
 				logger.Warn("Rate limit exceeded", "ip", ip, "path", r.URL.Path)
+				if onReject != nil {
+					onReject()
+				}
 				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
@@ -67,7 +71,8 @@ func NewRateLimitMiddleware(hourLimit int, logger *slog.Logger) func(http.Handle
 
 // NewWebhookRateLimitMiddleware creates middleware for webhook-specific rate limiting
 // limit: requests per minute
-func NewWebhookRateLimitMiddleware(limit int, logger *slog.Logger) func(http.Handler) http.Handler {
+// onReject: called for each rejected request, may be nil
+func NewWebhookRateLimitMiddleware(limit int, logger *slog.Logger, onReject func()) func(http.Handler) http.Handler {
 	// Convert to requests per second
 	rps := rate.Limit(float64(limit) / 60.0)
 	limiter := NewRateLimiter(rps, limit)
@@ -78,6 +83,9 @@ func NewWebhookRateLimitMiddleware(limit int, logger *slog.Logger) func(http.Han
 
 			if !limiter.GetLimiter(ip).Allow() {
 				logger.Warn("Webhook rate limit exceeded", "ip", ip, "path", r.URL.Path)
+				if onReject != nil {
+					onReject()
+				}
 				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"net/http"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/project"
+)
+
+// WebhookProvider adapts a git hosting service's push webhooks to the
+// common deployment flow.
+type WebhookProvider interface {
+	// Name returns the provider identifier used in project configuration
+	Name() string
+
+	// VerifySignature checks the request authenticates with the project secret
+	VerifySignature(r *http.Request, body []byte, secret string) bool
+
+	// IsPushEvent reports whether the request is a push event
+	IsPushEvent(r *http.Request) bool
+
+	// ParsePush extracts the ref, commit and pusher from a push payload.
+	// Missing fields are left empty; ShouldDeploy skips pushes without a ref.
+	ParsePush(payload map[string]interface{}) *deployment.PushEvent
+}
+
+// webhookProviders holds the built-in providers keyed by name
+var webhookProviders = map[string]WebhookProvider{
+	project.ProviderGitHub:    GitHubProvider{},
+	project.ProviderGitLab:    GitLabProvider{},
+	project.ProviderGitea:     GiteaProvider{},
+	project.ProviderBitbucket: BitbucketProvider{},
+}
+
+// ProviderFor returns the webhook provider for a configured provider name.
+// An empty name selects GitHub.
+func ProviderFor(name string) (WebhookProvider, bool) {
+	if name == "" {
+		name = project.ProviderGitHub
+	}
+	provider, ok := webhookProviders[name]
+	return provider, ok
+}
+
+// GitHubProvider handles GitHub push webhooks
+type GitHubProvider struct{}
+
+// Name returns "github"
+func (GitHubProvider) Name() string { return project.ProviderGitHub }
+
+// VerifySignature checks the X-Hub-Signature-256 HMAC
+func (GitHubProvider) VerifySignature(r *http.Request, body []byte, secret string) bool {
+	return VerifySignature(body, r.Header.Get("X-Hub-Signature-256"), secret)
+}
+
+// IsPushEvent checks X-GitHub-Event is "push"
+func (GitHubProvider) IsPushEvent(r *http.Request) bool {
+	return r.Header.Get("X-GitHub-Event") == "push"
+}
+
+// ParsePush reads ref, after and pusher.name
+func (GitHubProvider) ParsePush(payload map[string]interface{}) *deployment.PushEvent {
+	return &deployment.PushEvent{
+		Ref:    stringField(payload, "ref"),
+		Commit: stringField(payload, "after"),
+		Pusher: stringField(payload, "pusher", "name"),
+	}
+}
+
+// GitLabProvider handles GitLab push webhooks
+type GitLabProvider struct{}
+
+// Name returns "gitlab"
+func (GitLabProvider) Name() string { return project.ProviderGitLab }
+
+// VerifySignature checks the X-Gitlab-Token shared secret
+func (GitLabProvider) VerifySignature(r *http.Request, body []byte, secret string) bool {
+	return VerifyGitLabToken(r.Header.Get("X-Gitlab-Token"), secret)
+}
+
+// IsPushEvent checks X-Gitlab-Event is "Push Hook"
+func (GitLabProvider) IsPushEvent(r *http.Request) bool {
+	return r.Header.Get("X-Gitlab-Event") == "Push Hook"
+}
+
+// ParsePush reads ref, after and user_username
+func (GitLabProvider) ParsePush(payload map[string]interface{}) *deployment.PushEvent {
+	return &deployment.PushEvent{
+		Ref:    stringField(payload, "ref"),
+		Commit: stringField(payload, "after"),
+		Pusher: stringField(payload, "user_username"),
+	}
+}
+
+// GiteaProvider handles Gitea and Forgejo push webhooks
+type GiteaProvider struct{}
+
+// Name returns "gitea"
+func (GiteaProvider) Name() string { return project.ProviderGitea }
+
+// VerifySignature checks the X-Gitea-Signature HMAC, falling back to
+// X-Forgejo-Signature for Forgejo instances that only send their own header
+func (GiteaProvider) VerifySignature(r *http.Request, body []byte, secret string) bool {
+	signature := r.Header.Get("X-Gitea-Signature")
+	if signature == "" {
+		signature = r.Header.Get("X-Forgejo-Signature")
+	}
+	return VerifyGiteaSignature(body, signature, secret)
+}
+
+// IsPushEvent checks X-Gitea-Event (or X-Forgejo-Event) is "push"
+func (GiteaProvider) IsPushEvent(r *http.Request) bool {
+	event := r.Header.Get("X-Gitea-Event")
+	if event == "" {
+		event = r.Header.Get("X-Forgejo-Event")
+	}
+	return event == "push"
+}
+
+// ParsePush reads ref, after and pusher.login
+func (GiteaProvider) ParsePush(payload map[string]interface{}) *deployment.PushEvent {
+	return &deployment.PushEvent{
+		Ref:    stringField(payload, "ref"),
+		Commit: stringField(payload, "after"),
+		Pusher: stringField(payload, "pusher", "login"),
+	}
+}
+
+// BitbucketProvider handles Bitbucket Cloud push webhooks
+type BitbucketProvider struct{}
+
+// Name returns "bitbucket"
+func (BitbucketProvider) Name() string { return project.ProviderBitbucket }
+
+// VerifySignature checks the X-Hub-Signature HMAC (same "sha256=" format as GitHub)
+func (BitbucketProvider) VerifySignature(r *http.Request, body []byte, secret string) bool {
+	return VerifySignature(body, r.Header.Get("X-Hub-Signature"), secret)
+}
+
+// IsPushEvent checks X-Event-Key is "repo:push"
+func (BitbucketProvider) IsPushEvent(r *http.Request) bool {
+	return r.Header.Get("X-Event-Key") == "repo:push"
+}
+
+// ParsePush reads the first branch update from push.changes.
+// Bitbucket reports one change per updated ref; changes with a null "new"
+// (branch deletions) and tag pushes are skipped.
+func (BitbucketProvider) ParsePush(payload map[string]interface{}) *deployment.PushEvent {
+	push := &deployment.PushEvent{
+		Pusher: stringField(payload, "actor", "nickname"),
+	}
+
+	pushData, _ := payload["push"].(map[string]interface{})
+	changes, _ := pushData["changes"].([]interface{})
+	for _, c := range changes {
+		change, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		newRef, ok := change["new"].(map[string]interface{})
+		if !ok || stringField(newRef, "type") != "branch" {
+			continue
+		}
+		push.Ref = "refs/heads/" + stringField(newRef, "name")
+		push.Commit = stringField(newRef, "target", "hash")
+		break
+	}
+
+	return push
+}
+
+// stringField walks nested JSON objects and returns the string at the given path,
+// or an empty string if any element is missing or has the wrong type
+func stringField(payload map[string]interface{}, path ...string) string {
+	current := payload
+	for i, key := range path {
+		if i == len(path)-1 {
+			value, _ := current[key].(string)
+			return value
+		}
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = next
+	}
+	return ""
+}
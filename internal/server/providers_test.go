@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/project"
+)
+
+const providerTestSecret = "test-secret-at-least-32-chars-long-here"
+
+func makeGiteaSignature(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestProviderFor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"", project.ProviderGitHub, true},
+		{project.ProviderGitHub, project.ProviderGitHub, true},
+		{project.ProviderGitLab, project.ProviderGitLab, true},
+		{project.ProviderGitea, project.ProviderGitea, true},
+		{project.ProviderBitbucket, project.ProviderBitbucket, true},
+		{"svn", "", false},
+	}
+
+	for _, tc := range testCases {
+		provider, ok := ProviderFor(tc.name)
+		if ok != tc.ok {
+			t.Errorf("ProviderFor(%q) ok = %v, expected %v", tc.name, ok, tc.ok)
+			continue
+		}
+		if ok && provider.Name() != tc.expected {
+			t.Errorf("ProviderFor(%q) = %s, expected %s", tc.name, provider.Name(), tc.expected)
+		}
+	}
+
+	// Every configurable provider must have an implementation
+	for _, name := range project.SupportedProviders {
+		if _, ok := ProviderFor(name); !ok {
+			t.Errorf("No webhook provider registered for supported provider %q", name)
+		}
+	}
+}
+
+func TestProviders_VerifySignature(t *testing.T) {
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+
+	testCases := []struct {
+		name     string
+		provider WebhookProvider
+		headers  map[string]string
+		expected bool
+	}{
+		{"github valid", GitHubProvider{}, map[string]string{"X-Hub-Signature-256": makeTestSignature(payload, providerTestSecret)}, true},
+		{"github wrong header", GitHubProvider{}, map[string]string{"X-Hub-Signature": makeTestSignature(payload, providerTestSecret)}, false},
+		{"gitlab valid", GitLabProvider{}, map[string]string{"X-Gitlab-Token": providerTestSecret}, true},
+		{"gitlab wrong token", GitLabProvider{}, map[string]string{"X-Gitlab-Token": "nope"}, false},
+		{"gitea valid", GiteaProvider{}, map[string]string{"X-Gitea-Signature": makeGiteaSignature(payload, providerTestSecret)}, true},
+		{"forgejo valid", GiteaProvider{}, map[string]string{"X-Forgejo-Signature": makeGiteaSignature(payload, providerTestSecret)}, true},
+		{"gitea prefixed signature", GiteaProvider{}, map[string]string{"X-Gitea-Signature": makeTestSignature(payload, providerTestSecret)}, false},
+		{"gitea missing", GiteaProvider{}, map[string]string{}, false},
+		{"bitbucket valid", BitbucketProvider{}, map[string]string{"X-Hub-Signature": makeTestSignature(payload, providerTestSecret)}, true},
+		{"bitbucket wrong secret", BitbucketProvider{}, map[string]string{"X-Hub-Signature": makeTestSignature(payload, "wrong-secret-32-chars-long-xxxxxxx")}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			if got := tc.provider.VerifySignature(req, payload, providerTestSecret); got != tc.expected {
+				t.Errorf("VerifySignature() = %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestProviders_IsPushEvent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		provider WebhookProvider
+		header   string
+		value    string
+		expected bool
+	}{
+		{"github push", GitHubProvider{}, "X-GitHub-Event", "push", true},
+		{"github ping", GitHubProvider{}, "X-GitHub-Event", "ping", false},
+		{"gitlab push", GitLabProvider{}, "X-Gitlab-Event", "Push Hook", true},
+		{"gitlab tag push", GitLabProvider{}, "X-Gitlab-Event", "Tag Push Hook", false},
+		{"gitea push", GiteaProvider{}, "X-Gitea-Event", "push", true},
+		{"forgejo push", GiteaProvider{}, "X-Forgejo-Event", "push", true},
+		{"gitea create", GiteaProvider{}, "X-Gitea-Event", "create", false},
+		{"bitbucket push", BitbucketProvider{}, "X-Event-Key", "repo:push", true},
+		{"bitbucket pullrequest", BitbucketProvider{}, "X-Event-Key", "pullrequest:created", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/in/test-project", nil)
+			req.Header.Set(tc.header, tc.value)
+
+			if got := tc.provider.IsPushEvent(req); got != tc.expected {
+				t.Errorf("IsPushEvent() = %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestProviders_ParsePush(t *testing.T) {
+	testCases := []struct {
+		name     string
+		provider WebhookProvider
+		fixture  string
+		expected deployment.PushEvent
+	}{
+		{
+			name:     "gitlab",
+			provider: GitLabProvider{},
+			fixture:  "gitlab_push.json",
+			expected: deployment.PushEvent{Ref: "refs/heads/main", Commit: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", Pusher: "jsmith"},
+		},
+		{
+			name:     "gitea",
+			provider: GiteaProvider{},
+			fixture:  "gitea_push.json",
+			expected: deployment.PushEvent{Ref: "refs/heads/main", Commit: "bffeb74224043ba2feb48d137756c8a9331c449a", Pusher: "gitea"},
+		},
+		{
+			name:     "bitbucket skips tag change",
+			provider: BitbucketProvider{},
+			fixture:  "bitbucket_push.json",
+			expected: deployment.PushEvent{Ref: "refs/heads/main", Commit: "709d658dc5b6d6afcd46049c2f332ee3f515a67d", Pusher: "jdoe"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var payload map[string]interface{}
+			if err := json.Unmarshal(loadTestPayload(t, tc.fixture), &payload); err != nil {
+				t.Fatalf("Failed to parse fixture: %v", err)
+			}
+
+			push := tc.provider.ParsePush(payload)
+			if *push != tc.expected {
+				t.Errorf("ParsePush() = %+v, expected %+v", *push, tc.expected)
+			}
+		})
+	}
+}
+
+func TestProviders_ParsePush_GitHub(t *testing.T) {
+	payload := map[string]interface{}{
+		"ref":    "refs/heads/main",
+		"after":  "abc123",
+		"pusher": map[string]interface{}{"name": "octocat"},
+	}
+
+	push := GitHubProvider{}.ParsePush(payload)
+	expected := deployment.PushEvent{Ref: "refs/heads/main", Commit: "abc123", Pusher: "octocat"}
+	if *push != expected {
+		t.Errorf("ParsePush() = %+v, expected %+v", *push, expected)
+	}
+}
+
+func TestProviders_ParsePush_BitbucketBranchDeleted(t *testing.T) {
+	payload := map[string]interface{}{
+		"push": map[string]interface{}{
+			"changes": []interface{}{
+				map[string]interface{}{"old": map[string]interface{}{"type": "branch", "name": "main"}, "new": nil},
+			},
+		},
+	}
+
+	push := BitbucketProvider{}.ParsePush(payload)
+	if push.Ref != "" {
+		t.Errorf("Expected deleted branch to produce no ref, got %q", push.Ref)
+	}
+}
+
+func TestHandleWebhook_GiteaPush(t *testing.T) {
+	server, testProject := setupTestServer(t)
+	testProject.Provider = project.ProviderGitea
+
+	payload := loadTestPayload(t, "gitea_push.json")
+
+	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitea-Event", "push")
+	req.Header.Set("X-Gitea-Signature", makeGiteaSignature(payload, testProject.Secret))
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	server.WaitForDeployments()
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleWebhook_BitbucketPush(t *testing.T) {
+	server, testProject := setupTestServer(t)
+	testProject.Provider = project.ProviderBitbucket
+
+	payload := loadTestPayload(t, "bitbucket_push.json")
+
+	req := httptest.NewRequest("POST", "/in/test-project", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Key", "repo:push")
+	req.Header.Set("X-Hub-Signature", makeTestSignature(payload, testProject.Secret))
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	server.WaitForDeployments()
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
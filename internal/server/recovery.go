@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"deplobox/internal/deployment"
+)
+
+const (
+	// abortedMessage is the error recorded for deployments interrupted by a crash or restart
+	abortedMessage = "Deployment interrupted: deplobox stopped before it finished"
+	// droppedMessage is the error recorded for queued pushes lost by a crash or restart
+	droppedMessage = "Queued push dropped: deplobox stopped before it ran"
+)
+
+// RecoverInterruptedDeployments cleans up after deployments that were running
+// when a previous server process stopped: their in_progress history records
+// are marked aborted and their half-built releases are removed (or the newest
+// kept, with keep_failed). Pushes that were still queued are lost with the
+// process, so their records are marked aborted too.
+//
+// Projects whose lock is held are skipped, since another process (a second
+// server instance or the CLI) is still working on them.
+func (s *Server) RecoverInterruptedDeployments(ctx context.Context) {
+	for _, projectName := range s.Registry.List() {
+		proj, err := s.Registry.Get(projectName)
+		if err != nil {
+			continue
+		}
+
+		lock, err := deployment.TryLockProject(proj.Path, "recover")
+		if err != nil {
+			var locked *deployment.LockedError
+			if errors.As(err, &locked) {
+				s.Logger.Info("skipping recovery, project is locked", "project", projectName, "holder", locked.Holder)
+			} else {
+				s.Logger.Warn("skipping recovery, failed to lock project", "project", projectName, "error", err)
+			}
+			continue
+		}
+
+		if !s.TestMode {
+			aborted, err := s.History.AbortInProgressDeployments(ctx, projectName, abortedMessage)
+			if err != nil {
+				s.Logger.Error("failed to mark interrupted deployments as aborted", "project", projectName, "error", err)
+			} else if aborted > 0 {
+				s.Logger.Warn("marked interrupted deployments as aborted", "project", projectName, "count", aborted)
+				s.Metrics.Deployments.Add(float64(aborted), projectName, "aborted")
+			}
+
+			dropped, err := s.History.AbortQueuedDeployments(ctx, projectName, droppedMessage)
+			if err != nil {
+				s.Logger.Error("failed to mark dropped queued deployments as aborted", "project", projectName, "error", err)
+			} else if dropped > 0 {
+				s.Logger.Warn("marked dropped queued deployments as aborted", "project", projectName, "count", dropped)
+				s.Metrics.Deployments.Add(float64(dropped), projectName, "aborted")
+			}
+		}
+
+		removed, err := deployment.NewExecutor(proj.Path).CleanupFailedReleases(proj.KeepFailed)
+		if err != nil {
+			s.Logger.Warn("failed release cleanup failed", "project", projectName, "error", err)
+		} else if len(removed) > 0 {
+			s.Logger.Info("removed half-built releases", "project", projectName, "removed", removed)
+		}
+
+		lock.Release()
+	}
+}
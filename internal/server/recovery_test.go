@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+	"deplobox/pkg/fileutil"
+)
+
+func TestRecoverInterruptedDeployments(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+
+	hist, err := history.NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	// A deployment that was building a release when the server died, and a
+	// push queued behind it
+	ctx := context.Background()
+	for _, status := range []string{"in_progress", "queued"} {
+		if _, err := hist.RecordDeployment(ctx, &history.DeploymentRecord{
+			Project: "test-project", Branch: "main", Ref: "refs/heads/main", Status: status,
+		}); err != nil {
+			t.Fatalf("Failed to record deployment: %v", err)
+		}
+	}
+	halfBuilt := filepath.Join(testProject.Path, "releases", "2024-12-09-12-00-00")
+	if err := os.MkdirAll(halfBuilt, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(halfBuilt+deployment.IncompleteSuffix, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(registry, hist, slog.New(slog.NewTextHandler(os.Stderr, nil)), false)
+	server.RecoverInterruptedDeployments(ctx)
+
+	records, err := hist.GetDeploymentHistory(ctx, "test-project", 10)
+	if err != nil {
+		t.Fatalf("Failed to get deployment history: %v", err)
+	}
+	for _, record := range records {
+		if record.Status != "aborted" {
+			t.Errorf("Expected status 'aborted', got '%s'", record.Status)
+		}
+	}
+	if len(records) != 2 || *records[0].ErrorMessage != droppedMessage {
+		t.Errorf("Expected the queued push to be recorded as dropped, got %+v", records)
+	}
+	if fileutil.PathExists(halfBuilt) || fileutil.PathExists(halfBuilt+deployment.IncompleteSuffix) {
+		t.Error("Expected half-built release to be removed")
+	}
+	if !fileutil.DirExists(filepath.Join(testProject.Path, "releases", "2024-12-09-11-00-00")) {
+		t.Error("Expected complete releases to be kept")
+	}
+}
+
+func TestRecoverInterruptedDeployments_ProjectLocked(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+
+	hist, err := history.NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	ctx := context.Background()
+	if _, err := hist.RecordDeployment(ctx, &history.DeploymentRecord{
+		Project: "test-project", Branch: "main", Ref: "refs/heads/main", Status: "in_progress",
+	}); err != nil {
+		t.Fatalf("Failed to record deployment: %v", err)
+	}
+
+	// Another process is still deploying
+	lock, err := deployment.TryLockProject(testProject.Path, "deploy")
+	if err != nil {
+		t.Fatalf("TryLockProject error: %v", err)
+	}
+	defer lock.Release()
+
+	server := NewServer(registry, hist, slog.New(slog.NewTextHandler(os.Stderr, nil)), false)
+	server.RecoverInterruptedDeployments(ctx)
+
+	latest, _ := hist.GetLatestDeployment(ctx, "test-project")
+	if latest.Status != "in_progress" {
+		t.Errorf("Expected running deployment to stay in_progress, got '%s'", latest.Status)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"deplobox/internal/project"
+	"deplobox/internal/report"
+)
+
+// ReportTimeout bounds each call to GitHub, so a slow API cannot hold up a
+// deployment for long
+const ReportTimeout = 10 * time.Second
+
+// githubDeployment is the GitHub Deployment reporting a running deployment
+type githubDeployment struct {
+	reporter *report.GitHub
+	id       int64
+}
+
+// startGitHubReport creates a GitHub Deployment of the commit a deployment
+// checked out if the project has a github_reporter. Returns nil if it has
+// none or GitHub failed; reporting never fails a deployment.
+func (s *Server) startGitHubReport(proj *project.Project, commit string) *githubDeployment {
+	if proj.GitHubReporter == nil {
+		return nil
+	}
+
+	reporter, err := report.NewGitHub(proj.GitHubReporter)
+	if err != nil {
+		s.Logger.Warn("GitHub reporter unavailable", "project", proj.Name, "error", err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReportTimeout)
+	defer cancel()
+	id, err := reporter.Start(ctx, commit, "Deploying "+shortSHA(commit))
+	if err != nil {
+		s.Logger.Warn("failed to report deployment start to GitHub", "project", proj.Name, "commit", commit, "error", err)
+		if id == 0 {
+			return nil
+		}
+	} else {
+		s.Logger.Info("reported deployment to GitHub", "project", proj.Name, "commit", commit, "github_deployment_id", id)
+	}
+
+	return &githubDeployment{reporter: reporter, id: id}
+}
+
+// finishGitHubReport sets the final state of the GitHub Deployment, if any,
+// from the deployment's status. Deployments that failed before checking out
+// a commit have none.
+func (s *Server) finishGitHubReport(projectName string, gd *githubDeployment, status string, errorMsg *string) {
+	if gd == nil {
+		return
+	}
+
+	state, description := report.StateFailure, "Deployment failed"
+	switch status {
+	case "success":
+		state, description = report.StateSuccess, "Deployment successful"
+	case "skipped":
+		state, description = report.StateInactive, "Deployment skipped"
+	case "rolled_back":
+		description = "Deployment failed and was rolled back"
+	}
+	if errorMsg != nil {
+		description += ": " + *errorMsg
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReportTimeout)
+	defer cancel()
+	if err := gd.reporter.Finish(ctx, gd.id, state, description); err != nil {
+		s.Logger.Warn("failed to report deployment result to GitHub", "project", projectName, "github_deployment_id", gd.id, "error", err)
+	}
+}
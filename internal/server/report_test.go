@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/project"
+)
+
+func TestExecuteDeployment_GitHubReportWithoutCommit(t *testing.T) {
+	var requests atomic.Int32
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer github.Close()
+
+	server, testProject := setupTestServer(t)
+	testProject.GitHubReporter = &project.GitHubReporter{
+		Repository: "my-org/my-site",
+		Token:      "ghp_test",
+		BaseURL:    github.URL,
+	}
+
+	// The project has no repository to clone from, so no commit is ever
+	// checked out and nothing is reported
+	push := &deployment.PushEvent{Ref: "refs/heads/main", Commit: "1a2b3c4d5e6f"}
+	server.executeDeployment(context.Background(), "test-project", testProject, push, 0)
+
+	if n := requests.Load(); n != 0 {
+		t.Errorf("Expected no GitHub requests without a checked out commit, got %d", n)
+	}
+}
+
+func TestGitHubReport_StartAndFinish(t *testing.T) {
+	var mu sync.Mutex
+	var refs, states, descriptions []string
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		if strings.HasSuffix(r.URL.Path, "/statuses") {
+			states = append(states, body["state"])
+			descriptions = append(descriptions, body["description"])
+		} else {
+			refs = append(refs, body["ref"])
+		}
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer github.Close()
+
+	server, testProject := setupTestServer(t)
+	testProject.GitHubReporter = &project.GitHubReporter{
+		Repository: "my-org/my-site",
+		Token:      "ghp_test",
+		BaseURL:    github.URL,
+	}
+
+	errorMsg := "post_deploy failed"
+	gd := server.startGitHubReport(testProject, "1a2b3c4d5e6f")
+	server.finishGitHubReport("test-project", gd, "failed", &errorMsg)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(refs) != 1 || refs[0] != "1a2b3c4d5e6f" {
+		t.Errorf("Expected one GitHub deployment of the commit, got %v", refs)
+	}
+	if len(states) != 2 || states[0] != "in_progress" || states[1] != "failure" {
+		t.Fatalf("Expected in_progress then failure, got %v", states)
+	}
+	if descriptions[1] != "Deployment failed: post_deploy failed" {
+		t.Errorf("Expected the error in the failure description, got %q", descriptions[1])
+	}
+}
+
+func TestExecuteDeployment_GitHubReportUnreachable(t *testing.T) {
+	server, testProject := setupTestServer(t)
+	testProject.GitHubReporter = &project.GitHubReporter{
+		Repository: "my-org/my-site",
+		Token:      "ghp_test",
+		BaseURL:    "http://127.0.0.1:1", // Nothing listens here
+	}
+
+	// Reporting failures are only logged; finishing without a GitHub deployment is a no-op
+	if gd := server.startGitHubReport(testProject, "1a2b3c4d5e6f"); gd != nil {
+		t.Errorf("Expected no GitHub deployment when GitHub is unreachable, got %+v", gd)
+	}
+	server.finishGitHubReport("test-project", nil, "success", nil)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+)
+
+// RestoreRequest is the JSON body of a restore request
+type RestoreRequest struct {
+	To              string `json:"to"`                // Release name or commit SHA
+	Steps           int    `json:"steps"`             // Releases to step back when To is empty (default 1)
+	RunPostActivate bool   `json:"run_post_activate"` // Run post_activate in the restored release
+}
+
+// startRestore finds the release a restore request asks for and restores it
+// in the background, responding with 202
+func (s *Server) startRestore(w http.ResponseWriter, r *http.Request, proj *project.Project, req RestoreRequest) {
+	projectName := proj.Name
+
+	// Restores must not overlap a deployment of the same project
+	if !s.Queue.TryAcquire(projectName) {
+		s.Logger.Info("restore rejected, deployment in progress", "project", projectName)
+		s.Metrics.LockRejections.Inc(projectName, lockQueue)
+		s.respondJSON(w, http.StatusConflict, map[string]string{"error": "Deployment in progress"})
+		return
+	}
+
+	executor := deployment.NewExecutor(proj.Path)
+	target, err := executor.FindRestoreTarget(r.Context(), req.To, req.Steps, proj.PullTimeout)
+	if err != nil {
+		s.respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+
+		// Hand the lock on to pushes queued meanwhile, outside the request
+		s.runAsync(func() { s.runQueuedDeployments(projectName, proj) })
+		return
+	}
+
+	// Respond before running post_activate, which can outlast the write timeout
+	s.respondJSON(w, http.StatusAccepted, map[string]string{
+		"message": "Restore accepted",
+		"project": projectName,
+		"release": target.Name,
+	})
+
+	triggeredBy := tokenName(r)
+	s.runAsync(func() {
+		s.executeRestore(context.Background(), proj, target, req.RunPostActivate, triggeredBy)
+		s.runQueuedDeployments(projectName, proj)
+	})
+}
+
+// executeRestore restores a release and records it in the history, with the
+// name of the API token that requested it, if any
+func (s *Server) executeRestore(ctx context.Context, proj *project.Project, target *deployment.Release, runPostActivate bool, triggeredBy string) {
+	startTime := time.Now()
+
+	restore := deployment.NewRestore(proj, s.ExposeOutput, s.Logger)
+	restore.Push.Pusher = triggeredBy
+	recordID := s.recordInProgress(ctx, proj, restore.Push, 0)
+	restore.Executor.Observer = s.newStepRecorder(proj.Name, recordID)
+
+	response, statusCode := restore.Restore(ctx, target, runPostActivate)
+
+	duration := time.Since(startTime).Seconds()
+
+	status := "failed"
+	if restore.Restored {
+		status = "restored"
+	}
+	var errorMsg *string
+	if errStr, ok := response["error"].(string); ok {
+		errorMsg = &errStr
+	}
+
+	if !s.TestMode {
+		s.finishRecord(ctx, recordID, &history.DeploymentRecord{
+			Project:         proj.Name,
+			Branch:          proj.Branch,
+			Ref:             restore.Push.Ref,
+			Status:          status,
+			DurationSeconds: &duration,
+			CommitHash:      stringPtrOrNil(restore.CommitHash),
+			ErrorMessage:    errorMsg,
+			Trigger:         restore.Push.TriggerName(),
+			TriggeredBy:     stringPtrOrNil(triggeredBy),
+		})
+	}
+	s.observeDeployment(proj.Name, status, restore)
+
+	if statusCode == http.StatusOK {
+		s.Logger.Info("restore completed", "project", proj.Name, "release", target.Name)
+	} else {
+		s.Logger.Error("restore failed", "project", proj.Name, "response", response)
+	}
+}
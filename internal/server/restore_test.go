@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"deplobox/internal/history"
+	"deplobox/internal/project"
+	"deplobox/pkg/fileutil"
+)
+
+// setupRestoreProject creates a project with two releases, current pointing at the newest
+func setupRestoreProject(t *testing.T) *project.Project {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"2024-12-09-10-00-00", "2024-12-09-11-00-00"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "releases", name), 0755); err != nil {
+			t.Fatalf("Failed to create release dir: %v", err)
+		}
+	}
+	if err := os.Symlink("releases/2024-12-09-11-00-00", filepath.Join(tmpDir, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	return &project.Project{
+		Name:                "test-project",
+		Path:                tmpDir,
+		Secret:              "test-secret-at-least-32-chars-long-here",
+		Branch:              "main",
+		PullTimeout:         60,
+		PostActivateTimeout: 30,
+		PostActivate:        []interface{}{[]interface{}{"touch", "activated"}},
+	}
+}
+
+// newRestoreRequest builds a restore request with testAPIToken, which needs
+// the rollback scope
+func newRestoreRequest(payload []byte) *http.Request {
+	req := httptest.NewRequest("POST", "/api/projects/test-project/restore", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	return authorized(req)
+}
+
+func TestHandleAPIRestore_Unauthorized(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+	server := NewServer(registry, nil, slog.New(slog.NewTextHandler(os.Stderr, nil)), true)
+	addTestToken(server, "read")
+
+	// The project's webhook secret does not authorize restores
+	payload := []byte(`{}`)
+	req := httptest.NewRequest("POST", "/api/projects/test-project/restore", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", MakeTestSignature(payload, testProject.Secret))
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rr.Code)
+	}
+
+	// Nor does a token without the rollback scope
+	rr = httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest(payload))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a read token, got %d", rr.Code)
+	}
+}
+
+func TestHandleAPIRestore_Success(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+
+	hist, err := history.NewHistory(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer hist.Close()
+
+	server := NewServer(registry, hist, slog.New(slog.NewTextHandler(os.Stderr, nil)), false) // NOT test mode
+	addTestToken(server, "rollback")
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest([]byte(`{"steps":1,"run_post_activate":true}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response map[string]string
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+	if response["release"] != "2024-12-09-10-00-00" {
+		t.Errorf("Expected release 2024-12-09-10-00-00, got %v", response)
+	}
+
+	server.WaitForDeployments()
+
+	restoredRelease := filepath.Join(testProject.Path, "releases", "2024-12-09-10-00-00")
+	currentPath, err := fileutil.ResolveSymlink(filepath.Join(testProject.Path, "current"))
+	if err != nil {
+		t.Fatalf("Failed to resolve current symlink: %v", err)
+	}
+	if currentPath != restoredRelease {
+		t.Errorf("Expected current to point at %s, got %s", restoredRelease, currentPath)
+	}
+	if !fileutil.FileExists(filepath.Join(restoredRelease, "activated")) {
+		t.Error("Expected post_activate to run in the restored release")
+	}
+
+	latest, err := hist.GetLatestDeployment(context.Background(), "test-project")
+	if err != nil || latest == nil {
+		t.Fatalf("Failed to get latest deployment: %v", err)
+	}
+	if latest.Status != "restored" {
+		t.Errorf("Expected status 'restored', got '%s'", latest.Status)
+	}
+	if latest.CompletedAt == nil {
+		t.Error("Expected completed_at to be set")
+	}
+
+	// The in_progress record was updated rather than a second one added
+	records, err := hist.GetDeploymentHistory(context.Background(), "test-project", 10)
+	if err != nil {
+		t.Fatalf("Failed to get deployment history: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 history record, got %d", len(records))
+	}
+
+	// The project lock was released
+	if !server.Queue.TryAcquire("test-project") {
+		t.Error("Expected project lock to be released after the restore")
+	}
+}
+
+func TestHandleAPIRestore_DeploymentInProgress(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+	server := NewServer(registry, nil, slog.New(slog.NewTextHandler(os.Stderr, nil)), true)
+	addTestToken(server, "rollback")
+
+	server.Queue.TryAcquire("test-project")
+	defer server.Queue.Done("test-project")
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest([]byte(`{}`)))
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", rr.Code)
+	}
+}
+
+func TestHandleAPIRestore_UnknownRelease(t *testing.T) {
+	testProject := setupRestoreProject(t)
+	registry := project.NewRegistry(map[string]*project.Project{"test-project": testProject})
+	server := NewServer(registry, nil, slog.New(slog.NewTextHandler(os.Stderr, nil)), true)
+	addTestToken(server, "rollback")
+
+	rr := httptest.NewRecorder()
+	server.Router().ServeHTTP(rr, newRestoreRequest([]byte(`{"to":"missing-release"}`)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+	server.WaitForDeployments()
+	if !server.Queue.TryAcquire("test-project") {
+		t.Error("Expected project lock to be released after a rejected restore")
+	}
+}
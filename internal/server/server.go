@@ -7,11 +7,13 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"deplobox/internal/deployment"
 	"deplobox/internal/history"
 	"deplobox/internal/project"
+	"deplobox/internal/security"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -29,6 +31,7 @@ const (
 	// Rate limiting - requests per minute
 	GlobalRateLimit  = 12 // Global rate limit per minute
 	WebhookRateLimit = 4  // Webhook-specific rate limit per minute
+	LoginRateLimit   = 5  // Dashboard login attempts per minute
 )
 
 // Server represents the HTTP server
@@ -36,10 +39,15 @@ type Server struct {
 	Registry     *project.Registry
 	History      *history.History
 	LockManager  *deployment.LockManager
+	Queue        *deployment.DeployQueue
+	AdminTokens  []project.AdminToken // API tokens from the config, besides those in the token store
+	Metrics      *Metrics
+	MetricsAddr  string // Separate listen address for /metrics; empty serves it on the main router
 	Logger       *slog.Logger
 	ExposeOutput bool
 	TestMode     bool
 	deployWg     sync.WaitGroup // Tracks in-flight async deployments
+	inFlight     atomic.Int64   // Number of in-flight async deployments, for metrics
 }
 
 // NewServer creates a new server instance
@@ -50,14 +58,20 @@ func NewServer(registry *project.Registry, hist *history.History, logger *slog.L
 		exposeOutput = true
 	}
 
-	return &Server{
+	lockManager := deployment.NewLockManager()
+
+	s := &Server{
 		Registry:     registry,
 		History:      hist,
-		LockManager:  deployment.NewLockManager(),
+		LockManager:  lockManager,
+		Queue:        deployment.NewDeployQueue(lockManager),
 		Logger:       logger,
 		ExposeOutput: exposeOutput,
 		TestMode:     testMode,
 	}
+	s.Metrics = NewMetrics(func() float64 { return float64(s.inFlight.Load()) })
+
+	return s
 }
 
 // Router creates and configures the HTTP router
@@ -68,7 +82,6 @@ func (s *Server) Router() *chi.Mux {
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(RequestTimeout))
 
 	// Logging middleware
 	r.Use(func(next http.Handler) http.Handler {
@@ -88,22 +101,57 @@ func (s *Server) Router() *chi.Mux {
 		})
 	})
 
-	// Rate limiting middleware (only if not in test mode)
-	if !s.TestMode {
-		r.Use(NewRateLimitMiddleware(GlobalRateLimit, s.Logger))
-	}
-
-	// Routes
-	r.Get("/health", s.HandleHealth)
-	r.Get("/status/{projectName}", s.HandleStatus)
+	// The dashboard loads a page per click or refresh, more than the global
+	// rate limit allows; only login attempts are limited
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(RequestTimeout))
+
+		// Read-only dashboard, for a bearer token or the login cookie
+		r.With(s.DashboardAuth).Get("/dashboard", s.HandleDashboard)
+		r.With(s.DashboardAuth).Get("/dashboard/deployments/{id}", s.HandleDashboardDeployment)
+		r.Post("/dashboard/logout", s.HandleDashboardLogout)
+		if !s.TestMode {
+			r.With(NewWebhookRateLimitMiddleware(LoginRateLimit, s.Logger, func() { s.Metrics.RateLimited.Inc("login") })).Post("/dashboard/login", s.HandleDashboardLogin)
+		} else {
+			r.Post("/dashboard/login", s.HandleDashboardLogin)
+		}
+	})
 
-	// Webhook route with stricter rate limit
-	if !s.TestMode {
-		r.With(NewWebhookRateLimitMiddleware(WebhookRateLimit, s.Logger)).Post("/in/{projectName}", s.HandleWebhook)
-	} else {
-		r.Post("/in/{projectName}", s.HandleWebhook)
+	// Metrics are only public here if they have no listener of their own.
+	// Scrapes come every few seconds, so they skip the global rate limit.
+	if s.MetricsAddr == "" {
+		r.With(middleware.Timeout(RequestTimeout), s.RequireScope(security.ScopeRead)).Get("/metrics", s.Metrics.Registry.Handler().ServeHTTP)
 	}
 
+	// Everything else counts against the global rate limit
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(RequestTimeout))
+
+		// Rate limiting middleware (only if not in test mode)
+		if !s.TestMode {
+			r.Use(NewRateLimitMiddleware(GlobalRateLimit, s.Logger, func() { s.Metrics.RateLimited.Inc("global") }))
+		}
+
+		// Routes
+		r.Get("/health", s.HandleHealth)
+		r.With(s.RequireScope(security.ScopeRead)).Get("/status/{projectName}", s.HandleStatus)
+		r.With(s.RequireScope(security.ScopeRead)).Get("/deployments/{id}", s.HandleDeployment)
+
+		// Webhook and admin routes with stricter rate limit
+		if !s.TestMode {
+			webhookLimit := func() func(http.Handler) http.Handler {
+				return NewWebhookRateLimitMiddleware(WebhookRateLimit, s.Logger, func() { s.Metrics.RateLimited.Inc("webhook") })
+			}
+			r.With(webhookLimit()).Post("/in/{projectName}", s.HandleWebhook)
+			r.With(webhookLimit(), s.RequireScope(security.ScopeDeploy)).Post("/api/projects/{projectName}/deploy", s.HandleManualDeploy)
+			r.With(webhookLimit(), s.RequireScope(security.ScopeRollback)).Post("/api/projects/{projectName}/restore", s.HandleAPIRestore)
+		} else {
+			r.Post("/in/{projectName}", s.HandleWebhook)
+			r.With(s.RequireScope(security.ScopeDeploy)).Post("/api/projects/{projectName}/deploy", s.HandleManualDeploy)
+			r.With(s.RequireScope(security.ScopeRollback)).Post("/api/projects/{projectName}/restore", s.HandleAPIRestore)
+		}
+	})
+
 	return r
 }
 
@@ -120,9 +168,46 @@ func (s *Server) Start(host string, port int) error {
 		IdleTimeout:  HTTPIdleTimeout,
 	}
 
+	if s.MetricsAddr != "" {
+		metricsServer := &http.Server{
+			Addr:         s.MetricsAddr,
+			Handler:      s.MetricsRouter(),
+			ReadTimeout:  HTTPReadTimeout,
+			WriteTimeout: HTTPWriteTimeout,
+			IdleTimeout:  HTTPIdleTimeout,
+		}
+		s.Logger.Info("Starting metrics server", "addr", s.MetricsAddr)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil {
+				s.Logger.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
 	return server.ListenAndServe()
 }
 
+// MetricsRouter serves /metrics without authentication, for a listener that
+// only the monitoring system can reach
+func (s *Server) MetricsRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Get("/metrics", s.Metrics.Registry.Handler().ServeHTTP)
+	return r
+}
+
+// runAsync runs a deployment or restore in the background, tracked by
+// WaitForDeployments and the in-flight metric
+func (s *Server) runAsync(fn func()) {
+	s.deployWg.Add(1)
+	s.inFlight.Add(1)
+	go func() {
+		defer s.deployWg.Done()
+		defer s.inFlight.Add(-1)
+		fn()
+	}()
+}
+
 // WaitForDeployments waits for all in-flight async deployments to complete.
 // This is primarily useful for testing.
 func (s *Server) WaitForDeployments() {
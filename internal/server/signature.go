@@ -3,6 +3,7 @@ package server
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"strings"
 )
@@ -34,3 +35,34 @@ func VerifySignature(payload []byte, signature, secret string) bool {
 	// Constant-time comparison to prevent timing attacks
 	return hmac.Equal([]byte(expectedMAC), []byte(receivedMAC))
 }
+
+// VerifyGiteaSignature verifies the HMAC-SHA256 signature from a Gitea or
+// Forgejo webhook. Unlike GitHub, the X-Gitea-Signature header carries the
+// bare hex digest without a "sha256=" prefix.
+func VerifyGiteaSignature(payload []byte, signature, secret string) bool {
+	// Signature must be present
+	if signature == "" {
+		return false
+	}
+
+	// Compute expected HMAC
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	expectedMAC := hex.EncodeToString(mac.Sum(nil))
+
+	// Constant-time comparison to prevent timing attacks
+	return hmac.Equal([]byte(expectedMAC), []byte(signature))
+}
+
+// VerifyGitLabToken verifies the shared secret token from a GitLab webhook.
+// GitLab sends the configured secret verbatim in the X-Gitlab-Token header
+// instead of signing the payload.
+func VerifyGitLabToken(token, secret string) bool {
+	// Token must be present
+	if token == "" || secret == "" {
+		return false
+	}
+
+	// Constant-time comparison to prevent timing attacks
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
@@ -56,3 +56,26 @@ func TestVerifySignature_MalformedSignature(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyGitLabToken(t *testing.T) {
+	secret := "test-secret-at-least-32-chars-long-here"
+
+	testCases := []struct {
+		name     string
+		token    string
+		expected bool
+	}{
+		{"matching token", secret, true},
+		{"wrong token", "wrong-secret-at-least-32-chars-long-x", false},
+		{"missing token", "", false},
+		{"prefix of secret", secret[:10], false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if VerifyGitLabToken(tc.token, secret) != tc.expected {
+				t.Errorf("VerifyGitLabToken(%q) = %v, expected %v", tc.token, !tc.expected, tc.expected)
+			}
+		})
+	}
+}
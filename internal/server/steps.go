@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+
+	"deplobox/internal/deployment"
+	"deplobox/internal/history"
+)
+
+// stepRecorder stores the steps of one deployment in the history
+type stepRecorder struct {
+	server       *Server
+	project      string
+	deploymentID int64
+}
+
+// newStepRecorder creates the step observer for a deployment or restore with
+// the given history record ID (0 if it has none)
+func (s *Server) newStepRecorder(project string, deploymentID int64) *stepRecorder {
+	return &stepRecorder{server: s, project: project, deploymentID: deploymentID}
+}
+
+// StepStarted does nothing; steps are recorded once they finish
+func (r *stepRecorder) StepStarted(step *deployment.StepResult) {}
+
+// StepFinished records a step in the history
+func (r *stepRecorder) StepFinished(step *deployment.StepResult) {
+	r.server.Metrics.StepDuration.Observe(step.Duration.Seconds(), r.project, step.Name)
+
+	if r.server.TestMode || r.deploymentID == 0 {
+		return
+	}
+	if _, err := r.server.History.RecordStep(context.Background(), &history.StepRecord{
+		DeploymentID:    r.deploymentID,
+		Step:            step.Name,
+		Command:         step.Command,
+		ExitCode:        step.ExitCode,
+		StartedAt:       step.StartedAt,
+		DurationSeconds: step.Duration.Seconds(),
+		Output:          step.Output,
+		ErrorMessage:    stringPtrOrNil(step.Error),
+	}); err != nil {
+		r.server.Logger.Error("Failed to record deployment step", "error", err, "project", r.project, "step", step.Name)
+	}
+}
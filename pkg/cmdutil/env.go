@@ -0,0 +1,58 @@
+package cmdutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultPath is the PATH given to commands that run with a clean environment.
+const DefaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// ValidEnvKey checks if a string is a valid environment variable name.
+func ValidEnvKey(key string) bool {
+	return envKeyPattern.MatchString(key)
+}
+
+// ParseEnvFile reads "KEY=value" entries from a dotenv-style file.
+// Blank lines and lines starting with # are ignored, an optional "export "
+// prefix is stripped, and values wrapped in matching single or double quotes
+// are unquoted. No variable expansion is performed.
+func ParseEnvFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || !ValidEnvKey(key) {
+			return nil, fmt.Errorf("invalid env file entry on line %d", lineNum)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		env = append(env, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	return env, nil
+}
@@ -0,0 +1,68 @@
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := `# Application settings
+APP_ENV=production
+export APP_DEBUG=false
+
+DB_PASSWORD="quoted value"
+GREETING='single quoted'
+EMPTY=
+URL=https://example.com/?a=b
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+
+	got, err := ParseEnvFile(path)
+	if err != nil {
+		t.Fatalf("ParseEnvFile() error = %v", err)
+	}
+
+	want := []string{
+		"APP_ENV=production",
+		"APP_DEBUG=false",
+		"DB_PASSWORD=quoted value",
+		"GREETING=single quoted",
+		"EMPTY=",
+		"URL=https://example.com/?a=b",
+	}
+	if !equalStringSlices(got, want) {
+		t.Errorf("ParseEnvFile() = %v, want %v", got, want)
+	}
+}
+
+func TestParseEnvFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("VALID=1\nnot a variable\n"), 0600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+
+	if _, err := ParseEnvFile(path); err == nil {
+		t.Error("Expected error for invalid line")
+	}
+
+	if _, err := ParseEnvFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestValidEnvKey(t *testing.T) {
+	for _, key := range []string{"PATH", "_private", "APP_ENV2"} {
+		if !ValidEnvKey(key) {
+			t.Errorf("ValidEnvKey(%q) = false, want true", key)
+		}
+	}
+	for _, key := range []string{"", "2FAST", "BAD-NAME", "A B"} {
+		if ValidEnvKey(key) {
+			t.Errorf("ValidEnvKey(%q) = true, want false", key)
+		}
+	}
+}
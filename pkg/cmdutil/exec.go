@@ -144,11 +144,18 @@ func ParseCommandString(cmdStr string) ([]string, error) {
 }
 
 // ParseCommandList parses a command that can be either a string or a list.
-// This handles the two formats from YAML configuration:
+// This handles the formats from YAML configuration:
 //   - String format: "npm install --production"
 //   - List format: ["npm", "install", "--production"]
+//   - Map format: {command: ..., timeout: ...} (options are ignored, see ParseCommandSpec)
 func ParseCommandList(cmd interface{}) ([]string, error) {
 	switch v := cmd.(type) {
+	case map[string]interface{}:
+		spec, err := ParseCommandSpec(v)
+		if err != nil {
+			return nil, err
+		}
+		return spec.Args, nil
 	case string:
 		return ParseCommandString(v)
 	case []interface{}:
@@ -0,0 +1,206 @@
+package cmdutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// ShellPath is the shell used for commands that opt in to shell execution.
+const ShellPath = "/bin/sh"
+
+// shellOperators are tokens that only have a meaning when run through a shell.
+var shellOperators = map[string]bool{
+	"|": true, "||": true, "&&": true, "&": true, ";": true,
+	">": true, ">>": true, "<": true, "2>&1": true,
+}
+
+// envKeyPattern matches valid environment variable names.
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CommandSpec is a command together with its per-command options.
+// Plain string and list commands produce a spec with only Args set.
+type CommandSpec struct {
+	// Name is an optional label used in logs and error messages.
+	Name string
+
+	// Args is the command and its arguments.
+	Args []string
+
+	// Timeout in seconds. If zero, the caller's default timeout applies.
+	Timeout int
+
+	// Env contains extra environment variables in the form "KEY=value".
+	Env []string
+
+	// Workdir is a directory relative to the caller's working directory.
+	Workdir string
+
+	// AllowFailure lets the command fail without failing the command list.
+	AllowFailure bool
+
+	// Retries is the number of extra attempts after a failure.
+	Retries int
+
+	// Shell is set when Args runs a script through ShellPath -c.
+	Shell bool
+
+	// Script is the shell script when Shell is set.
+	Script string
+}
+
+// Label returns the spec name, or the formatted command if no name is set.
+func (s *CommandSpec) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Shell {
+		return s.Script
+	}
+	return FormatCommand(s.Args)
+}
+
+// ShellArgs returns the argv that runs script through ShellPath.
+func ShellArgs(script string) []string {
+	return []string{ShellPath, "-c", script}
+}
+
+// FindShellOperators returns the shell operators in a command string that would
+// be passed as literal arguments when the command runs without a shell.
+func FindShellOperators(cmdStr string) []string {
+	parts, err := ParseCommandString(cmdStr)
+	if err != nil {
+		return nil
+	}
+
+	var found []string
+	for _, part := range parts {
+		if shellOperators[part] {
+			found = append(found, part)
+		}
+	}
+	return found
+}
+
+// ParseCommandSpec parses a command from YAML configuration.
+// In addition to the string and list formats accepted by ParseCommandList,
+// it accepts a map with per-command options:
+//
+//	name: migrate
+//	command: php artisan migrate --force   # string or list, required
+//	timeout: 120                          # seconds
+//	env: {APP_ENV: production}
+//	workdir: backend                      # relative to the release
+//	allow_failure: true
+//	retries: 2
+//	shell: true                           # run a string command with /bin/sh -c
+func ParseCommandSpec(cmd interface{}) (*CommandSpec, error) {
+	return ParseCommandSpecWithShell(cmd, false)
+}
+
+// ParseCommandSpecWithShell parses a command like ParseCommandSpec. If shell is
+// true, string commands (plain or in a map) run through ShellPath by default.
+func ParseCommandSpecWithShell(cmd interface{}, shell bool) (*CommandSpec, error) {
+	m, ok := cmd.(map[string]interface{})
+	if !ok {
+		if script, isString := cmd.(string); isString && shell {
+			return &CommandSpec{Args: ShellArgs(script), Shell: true, Script: script}, nil
+		}
+		args, err := ParseCommandList(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return &CommandSpec{Args: args}, nil
+	}
+
+	spec := &CommandSpec{}
+	explicitShell := false
+	for key, value := range m {
+		var ok bool
+		switch key {
+		case "name":
+			spec.Name, ok = value.(string)
+		case "command":
+			args, err := ParseCommandList(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command: %w", err)
+			}
+			spec.Args, ok = args, true
+		case "timeout":
+			spec.Timeout, ok = value.(int)
+			if ok && spec.Timeout < 0 {
+				return nil, fmt.Errorf("timeout must be a positive integer, got %d", spec.Timeout)
+			}
+		case "env":
+			env, err := parseEnvMap(value)
+			if err != nil {
+				return nil, err
+			}
+			spec.Env, ok = env, true
+		case "workdir":
+			spec.Workdir, ok = value.(string)
+			if ok && !filepath.IsLocal(spec.Workdir) {
+				return nil, fmt.Errorf("workdir must be a relative path inside the release, got '%s'", spec.Workdir)
+			}
+		case "allow_failure":
+			spec.AllowFailure, ok = value.(bool)
+		case "shell":
+			spec.Shell, ok = value.(bool)
+			explicitShell = true
+		case "retries":
+			spec.Retries, ok = value.(int)
+			if ok && spec.Retries < 0 {
+				return nil, fmt.Errorf("retries must be a positive integer, got %d", spec.Retries)
+			}
+		default:
+			return nil, fmt.Errorf("unknown command option '%s'", key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid type for command option '%s': %T", key, value)
+		}
+	}
+
+	if len(spec.Args) == 0 {
+		return nil, fmt.Errorf("missing required 'command' option")
+	}
+
+	// Shell commands are a single script string
+	script, isString := m["command"].(string)
+	if !explicitShell && shell && isString {
+		spec.Shell = true
+	}
+	if spec.Shell {
+		if !isString {
+			return nil, fmt.Errorf("shell commands must be a string")
+		}
+		spec.Args = ShellArgs(script)
+		spec.Script = script
+	}
+
+	return spec, nil
+}
+
+// parseEnvMap converts a YAML env map into sorted "KEY=value" entries.
+func parseEnvMap(value interface{}) ([]string, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be a map, got %T", value)
+	}
+
+	env := make([]string, 0, len(m))
+	for key, v := range m {
+		if !ValidEnvKey(key) {
+			return nil, fmt.Errorf("invalid env variable name '%s'", key)
+		}
+		switch v.(type) {
+		case string, int, bool, float64:
+			env = append(env, fmt.Sprintf("%s=%v", key, v))
+		default:
+			return nil, fmt.Errorf("env variable '%s' must be a scalar, got %T", key, v)
+		}
+	}
+	sort.Strings(env)
+
+	return env, nil
+}
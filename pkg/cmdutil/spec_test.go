@@ -0,0 +1,150 @@
+package cmdutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   interface{}
+		want    *CommandSpec
+		wantErr bool
+	}{
+		{
+			"string format",
+			"npm install",
+			&CommandSpec{Args: []string{"npm", "install"}},
+			false,
+		},
+		{
+			"list format",
+			[]interface{}{"npm", "install"},
+			&CommandSpec{Args: []string{"npm", "install"}},
+			false,
+		},
+		{
+			"map with all options",
+			map[string]interface{}{
+				"name":          "migrate",
+				"command":       "php artisan migrate --force",
+				"timeout":       120,
+				"env":           map[string]interface{}{"APP_ENV": "production", "WORKERS": 4},
+				"workdir":       "backend",
+				"allow_failure": true,
+				"retries":       2,
+			},
+			&CommandSpec{
+				Name:         "migrate",
+				Args:         []string{"php", "artisan", "migrate", "--force"},
+				Timeout:      120,
+				Env:          []string{"APP_ENV=production", "WORKERS=4"},
+				Workdir:      "backend",
+				AllowFailure: true,
+				Retries:      2,
+			},
+			false,
+		},
+		{
+			"map with list command",
+			map[string]interface{}{"command": []interface{}{"npm", "ci"}},
+			&CommandSpec{Args: []string{"npm", "ci"}},
+			false,
+		},
+		{"map without command", map[string]interface{}{"name": "x"}, nil, true},
+		{"unknown option", map[string]interface{}{"command": "ls", "sudo": true}, nil, true},
+		{
+			"shell map",
+			map[string]interface{}{"command": "php artisan queue:restart || true", "shell": true},
+			&CommandSpec{Args: []string{ShellPath, "-c", "php artisan queue:restart || true"}, Shell: true, Script: "php artisan queue:restart || true"},
+			false,
+		},
+		{"shell with list command", map[string]interface{}{"command": []interface{}{"ls"}, "shell": true}, nil, true},
+		{"wrong option type", map[string]interface{}{"command": "ls", "timeout": "10"}, nil, true},
+		{"negative retries", map[string]interface{}{"command": "ls", "retries": -1}, nil, true},
+		{"absolute workdir", map[string]interface{}{"command": "ls", "workdir": "/etc"}, nil, true},
+		{"escaping workdir", map[string]interface{}{"command": "ls", "workdir": "../shared"}, nil, true},
+		{"invalid env name", map[string]interface{}{"command": "ls", "env": map[string]interface{}{"BAD-NAME": "x"}}, nil, true},
+		{"non-scalar env value", map[string]interface{}{"command": "ls", "env": map[string]interface{}{"A": []interface{}{"x"}}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommandSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCommandSpec() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCommandSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommandList_MapFormat(t *testing.T) {
+	got, err := ParseCommandList(map[string]interface{}{"command": "php artisan queue:restart", "allow_failure": true})
+	if err != nil {
+		t.Fatalf("ParseCommandList() error = %v", err)
+	}
+	if !equalStringSlices(got, []string{"php", "artisan", "queue:restart"}) {
+		t.Errorf("ParseCommandList() = %v", got)
+	}
+}
+
+func TestCommandSpec_Label(t *testing.T) {
+	if label := (&CommandSpec{Name: "migrate", Args: []string{"php", "artisan"}}).Label(); label != "migrate" {
+		t.Errorf("Label() = %q, want name", label)
+	}
+	if label := (&CommandSpec{Args: []string{"echo", "hello world"}}).Label(); label != "echo 'hello world'" {
+		t.Errorf("Label() = %q, want formatted command", label)
+	}
+}
+
+func TestParseCommandSpecWithShell(t *testing.T) {
+	// Project-level shell applies to string commands
+	spec, err := ParseCommandSpecWithShell("npm ci && npm run build", true)
+	if err != nil {
+		t.Fatalf("ParseCommandSpecWithShell() error = %v", err)
+	}
+	if !spec.Shell || !equalStringSlices(spec.Args, []string{ShellPath, "-c", "npm ci && npm run build"}) {
+		t.Errorf("Expected string command to run through shell, got %+v", spec)
+	}
+
+	// Lists stay explicit argv
+	spec, err = ParseCommandSpecWithShell([]interface{}{"npm", "ci"}, true)
+	if err != nil {
+		t.Fatalf("ParseCommandSpecWithShell() error = %v", err)
+	}
+	if spec.Shell {
+		t.Errorf("Expected list command not to run through shell, got %+v", spec)
+	}
+
+	// A map can opt out of the project default
+	spec, err = ParseCommandSpecWithShell(map[string]interface{}{"command": "npm ci", "shell": false}, true)
+	if err != nil {
+		t.Fatalf("ParseCommandSpecWithShell() error = %v", err)
+	}
+	if spec.Shell {
+		t.Errorf("Expected explicit shell: false to win, got %+v", spec)
+	}
+}
+
+func TestFindShellOperators(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"php artisan queue:restart || true", []string{"||"}},
+		{"npm ci && npm run build > build.log", []string{"&&", ">"}},
+		{"echo 'a || b'", nil},
+		{"npm install --production", nil},
+	}
+
+	for _, tt := range tests {
+		if got := FindShellOperators(tt.input); !equalStringSlices(got, tt.want) {
+			t.Errorf("FindShellOperators(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package integration
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +11,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -57,6 +60,8 @@ func TestEndToEndDeployment(t *testing.T) {
 		t.Fatalf("Failed to create current symlink: %v", err)
 	}
 
+	headCommit := gitHeadCommit(t, initialRelease)
+
 	// Create project configuration
 	testProject := &project.Project{
 		Name:              "test-project",
@@ -70,12 +75,12 @@ func TestEndToEndDeployment(t *testing.T) {
 
 	// Test 1: First deployment creates release
 	t.Run("FirstDeployment", func(t *testing.T) {
-		payload := map[string]interface{}{
-			"ref":   "refs/heads/main",
-			"after": "abc123",
+		push := &deployment.PushEvent{
+			Ref:    "refs/heads/main",
+			Commit: headCommit,
 		}
 
-		deploy := deployment.NewDeployment(testProject, payload, false, nil)
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
 		response, statusCode := deploy.Execute(context.Background())
 
 		if statusCode != 200 {
@@ -114,12 +119,12 @@ func TestEndToEndDeployment(t *testing.T) {
 		for i := 0; i < 3; i++ {
 			time.Sleep(1 * time.Second) // Ensure different timestamps (format is to the second)
 
-			payload := map[string]interface{}{
-				"ref":   "refs/heads/main",
-				"after": "def456",
+			push := &deployment.PushEvent{
+				Ref:    "refs/heads/main",
+				Commit: headCommit,
 			}
 
-			deploy := deployment.NewDeployment(testProject, payload, false, nil)
+			deploy := deployment.NewDeployment(testProject, push, false, nil)
 			response, statusCode := deploy.Execute(context.Background())
 
 			if statusCode != 200 {
@@ -163,12 +168,12 @@ func TestEndToEndDeployment(t *testing.T) {
 		}
 
 		// Perform deployment
-		payload := map[string]interface{}{
-			"ref":   "refs/heads/main",
-			"after": "ghi789",
+		push := &deployment.PushEvent{
+			Ref:    "refs/heads/main",
+			Commit: headCommit,
 		}
 
-		deploy := deployment.NewDeployment(testProject, payload, false, nil)
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
 		deploy.Execute(context.Background())
 
 		// Verify shared file still exists
@@ -253,7 +258,7 @@ func TestWebhookIntegration(t *testing.T) {
 	}
 
 	// Test webhook request
-	payload := []byte(`{"ref":"refs/heads/main","after":"abc123"}`)
+	payload := []byte(fmt.Sprintf(`{"ref":"refs/heads/main","after":"%s"}`, gitHeadCommit(t, initialRelease)))
 	signature := server.MakeTestSignature(payload, secret)
 
 	req := httptest.NewRequest("POST", "/in/webhook-project", strings.NewReader(string(payload)))
@@ -290,7 +295,7 @@ func TestWebhookIntegration(t *testing.T) {
 	}
 }
 
-// TestConcurrentDeployments ensures only one deployment runs at a time
+// TestConcurrentDeployments ensures a push during a deployment is queued and run afterwards
 func TestConcurrentDeployments(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -343,7 +348,7 @@ func TestConcurrentDeployments(t *testing.T) {
 		t.Fatalf("Failed to create current symlink: %v", err)
 	}
 
-	payload := []byte(`{"ref":"refs/heads/main","after":"abc123"}`)
+	payload := []byte(fmt.Sprintf(`{"ref":"refs/heads/main","after":"%s"}`, gitHeadCommit(t, initialRelease)))
 	signature := server.MakeTestSignature(payload, secret)
 
 	// Start first deployment in background
@@ -371,20 +376,25 @@ func TestConcurrentDeployments(t *testing.T) {
 	rr := httptest.NewRecorder()
 	srv.Router().ServeHTTP(rr, req)
 
-	// Verify second deployment was rejected
-	if rr.Code != http.StatusTooManyRequests {
-		t.Errorf("Expected status 429, got %d", rr.Code)
+	// Verify second deployment was queued behind the first
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", rr.Code)
 	}
 
 	var response map[string]string
 	json.Unmarshal(rr.Body.Bytes(), &response)
 
-	if response["error"] != "Deployment already in progress" {
-		t.Errorf("Expected 'Deployment already in progress' error, got %v", response)
+	if response["message"] != "Deployment queued" {
+		t.Errorf("Expected 'Deployment queued' message, got %v", response)
 	}
 
-	// Wait for first deployment to complete
+	// Wait for first deployment and the queued one to complete
 	<-done
+	srv.WaitForDeployments()
+
+	if pending := srv.Queue.Pending("concurrent-project"); pending != nil {
+		t.Errorf("Expected queued deployment to have run, still pending: %+v", pending)
+	}
 }
 
 // setupTestGitRepo initializes a minimal git repository for testing
@@ -433,3 +443,931 @@ func setupTestGitRepo(t *testing.T, path string) error {
 
 	return nil
 }
+
+// gitHeadCommit returns the full SHA of HEAD in the given repository
+func gitHeadCommit(t *testing.T, path string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to resolve HEAD in %s: %v", path, err)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// TestDeployPushedCommit ensures the release contains the pushed commit even if the branch has moved on
+func TestDeployPushedCommit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "commit-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	pushedCommit := gitHeadCommit(t, initialRelease)
+
+	// A second push lands before the first one is deployed
+	for _, cmdParts := range [][]string{
+		{"sh", "-c", "echo 'second' > README.md"},
+		{"git", "commit", "-am", "Second commit"},
+		{"git", "push", "origin", "main"},
+	} {
+		cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
+		cmd.Dir = initialRelease
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Command %v failed: %v, output: %s", cmdParts, err, output)
+		}
+	}
+
+	testProject := &project.Project{
+		Name:              "commit-project",
+		Path:              projectPath,
+		Secret:            "commit-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 300,
+	}
+
+	t.Run("ChecksOutPushedCommit", func(t *testing.T) {
+		deploy := deployment.NewDeployment(testProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: pushedCommit}, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != 200 {
+			t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+		}
+
+		if deploy.CommitHash != pushedCommit {
+			t.Errorf("Expected deployed commit %s, got %s", pushedCommit, deploy.CommitHash)
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if head := gitHeadCommit(t, currentPath); head != pushedCommit {
+			t.Errorf("Expected release HEAD %s, got %s", pushedCommit, head)
+		}
+
+		// Release is named after the UTC time and short SHA, with a manifest
+		if !strings.HasSuffix(filepath.Base(currentPath), "Z-"+pushedCommit[:7]) {
+			t.Errorf("Expected release name to end with the short commit SHA, got %s", filepath.Base(currentPath))
+		}
+		manifest, err := deployment.ReadReleaseManifest(currentPath)
+		if err != nil {
+			t.Fatalf("ReadReleaseManifest error: %v", err)
+		}
+		if manifest.Commit != pushedCommit || manifest.Branch != "main" || manifest.DeploymentID != deploy.ID || manifest.ActivatedAt == nil {
+			t.Errorf("Unexpected release manifest: %+v", manifest)
+		}
+
+		// The manifest does not show up as an untracked file
+		statusCmd := exec.Command("git", "-C", currentPath, "status", "--porcelain")
+		if output, err := statusCmd.Output(); err != nil || len(output) != 0 {
+			t.Errorf("Expected a clean worktree, got %q (%v)", output, err)
+		}
+	})
+
+	t.Run("RejectsCommitNotOnBranch", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		deploy := deployment.NewDeployment(testProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: "0123456789abcdef0123456789abcdef01234567"}, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d: %v", statusCode, response)
+		}
+	})
+}
+
+// TestRepoCache ensures releases are built from the persistent bare repository cache
+func TestRepoCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "cache-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	originPath := filepath.Join(projectPath, "origin.git")
+
+	testProject := &project.Project{
+		Name:              "cache-project",
+		Path:              projectPath,
+		Secret:            "cache-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 300,
+	}
+
+	t.Run("CreatesCacheOnFirstDeploy", func(t *testing.T) {
+		commit := gitHeadCommit(t, initialRelease)
+		deploy := deployment.NewDeployment(testProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: commit}, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != 200 {
+			t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+		}
+
+		if !deployment.HasRepoCache(projectPath) {
+			t.Fatal("Expected repository cache to be created")
+		}
+
+		// Releases must point at the real remote, not the local cache
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		cmd := exec.Command("git", "remote", "get-url", "origin")
+		cmd.Dir = currentPath
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to read release origin: %v", err)
+		}
+		if remote := strings.TrimSpace(string(output)); remote != originPath {
+			t.Errorf("Expected release origin %s, got %s", originPath, remote)
+		}
+	})
+
+	t.Run("ExcludeGitDirExportsTree", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		// A new push must be fetched into the existing cache
+		for _, cmdParts := range [][]string{
+			{"sh", "-c", "echo 'exported' > README.md"},
+			{"git", "commit", "-am", "Export commit"},
+			{"git", "push", "origin", "main"},
+		} {
+			cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
+			cmd.Dir = initialRelease
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("Command %v failed: %v, output: %s", cmdParts, err, output)
+			}
+		}
+		commit := gitHeadCommit(t, initialRelease)
+
+		exportProject := *testProject
+		exportProject.ExcludeGitDir = true
+
+		deploy := deployment.NewDeployment(&exportProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: commit}, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != 200 {
+			t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+		}
+		if deploy.CommitHash != commit {
+			t.Errorf("Expected deployed commit %s, got %s", commit, deploy.CommitHash)
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if fileutil.DirExists(filepath.Join(currentPath, ".git")) {
+			t.Error("Expected release without .git when exclude_git_dir is set")
+		}
+		content, err := os.ReadFile(filepath.Join(currentPath, "README.md"))
+		if err != nil {
+			t.Fatalf("Expected README.md in exported release: %v", err)
+		}
+		if strings.TrimSpace(string(content)) != "exported" {
+			t.Errorf("Expected exported README content, got %q", content)
+		}
+
+		// No archive leftovers in the project root
+		leftovers, _ := filepath.Glob(filepath.Join(projectPath, ".release-*.tar"))
+		if len(leftovers) != 0 {
+			t.Errorf("Expected archive to be cleaned up, found %v", leftovers)
+		}
+	})
+}
+
+// TestHealthCheckRollback ensures a release that fails its health check is rolled back
+func TestHealthCheckRollback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "health-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	var healthy atomic.Bool
+	healthy.Store(true)
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer app.Close()
+
+	testProject := &project.Project{
+		Name:              "health-project",
+		Path:              projectPath,
+		Secret:            "health-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 300,
+		HealthCheck: &project.HealthCheck{
+			URL:            app.URL + "/health",
+			ExpectedStatus: http.StatusOK,
+			Retries:        1,
+			Interval:       0,
+			Timeout:        5,
+		},
+	}
+	push := &deployment.PushEvent{Ref: "refs/heads/main", Commit: gitHeadCommit(t, initialRelease)}
+
+	t.Run("HealthyReleaseStaysActive", func(t *testing.T) {
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != 200 {
+			t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+		}
+		if deploy.RolledBack {
+			t.Error("Expected healthy release not to be rolled back")
+		}
+	})
+
+	t.Run("UnhealthyReleaseRollsBack", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		healthyRelease, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+
+		healthy.Store(false)
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %v", statusCode, response)
+		}
+		if !deploy.RolledBack {
+			t.Error("Expected deployment to be rolled back")
+		}
+		if response["rolled_back"] != true {
+			t.Errorf("Expected rolled_back in response, got %v", response)
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if currentPath != healthyRelease {
+			t.Errorf("Expected current to be restored to %s, got %s", healthyRelease, currentPath)
+		}
+	})
+
+	t.Run("RollsBackToLiveReleaseNotRolledBackBuild", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		// The rolled-back build from the previous subtest is the newest-but-one
+		// release now; the rollback must skip it for the live one
+		liveRelease, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %v", statusCode, response)
+		}
+		if !deploy.RolledBack {
+			t.Error("Expected deployment to be rolled back")
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if currentPath != liveRelease {
+			t.Errorf("Expected current to be restored to the live release %s, got %s", liveRelease, currentPath)
+		}
+	})
+}
+
+// TestPostActivateRollback ensures rollback_on_failure restores the previous release and runs on_rollback
+func TestPostActivateRollback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "rollback-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	testProject := &project.Project{
+		Name:                "rollback-project",
+		Path:                projectPath,
+		Secret:              "rollback-test-secret-at-least-32-chars-long-here",
+		Branch:              "main",
+		PullTimeout:         60,
+		PostDeployTimeout:   300,
+		PostActivateTimeout: 30,
+		PostActivate:        []interface{}{"false"},
+	}
+	push := &deployment.PushEvent{Ref: "refs/heads/main", Commit: gitHeadCommit(t, initialRelease)}
+
+	t.Run("WithoutPolicyKeepsNewRelease", func(t *testing.T) {
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %v", statusCode, response)
+		}
+		if deploy.RolledBack {
+			t.Error("Expected no rollback without rollback_on_failure")
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if currentPath == initialRelease {
+			t.Error("Expected current to keep pointing at the new release")
+		}
+	})
+
+	t.Run("WithPolicyRestoresPreviousRelease", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		previousRelease, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+
+		rollbackProject := *testProject
+		rollbackProject.RollbackOnFailure = true
+		rollbackProject.OnRollback = []interface{}{[]interface{}{"touch", "rolled-back"}}
+
+		deploy := deployment.NewDeployment(&rollbackProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %v", statusCode, response)
+		}
+		if !deploy.RolledBack {
+			t.Error("Expected deployment to be rolled back")
+		}
+		if errMsg, _ := response["error"].(string); !strings.Contains(errMsg, "Post-activate command failed") || !strings.Contains(errMsg, "rolled back to") {
+			t.Errorf("Expected error to describe failure and rollback, got %q", errMsg)
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if currentPath != previousRelease {
+			t.Errorf("Expected current to be restored to %s, got %s", previousRelease, currentPath)
+		}
+
+		// on_rollback runs in the restored release
+		if !fileutil.FileExists(filepath.Join(previousRelease, "rolled-back")) {
+			t.Error("Expected on_rollback command to run in the restored release")
+		}
+	})
+
+	t.Run("WithPolicyRestoresLiveReleaseAfterManualRestore", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		// current was restored to the oldest release, so the newest-but-one
+		// release is not the one that is live
+		executor := deployment.NewExecutor(projectPath)
+		if err := executor.UpdateCurrentSymlink(initialRelease); err != nil {
+			t.Fatalf("Failed to restore the initial release: %v", err)
+		}
+
+		rollbackProject := *testProject
+		rollbackProject.RollbackOnFailure = true
+
+		deploy := deployment.NewDeployment(&rollbackProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %v", statusCode, response)
+		}
+		if !deploy.RolledBack {
+			t.Error("Expected deployment to be rolled back")
+		}
+
+		currentPath, err := fileutil.ResolveSymlink(filepath.Join(projectPath, "current"))
+		if err != nil {
+			t.Fatalf("Failed to resolve current symlink: %v", err)
+		}
+		if currentPath != initialRelease {
+			t.Errorf("Expected current to be restored to the live release %s, got %s", initialRelease, currentPath)
+		}
+	})
+}
+
+// TestLifecycleHooks ensures pre_deploy runs before the release and outcome hooks receive the result
+func TestLifecycleHooks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "hooks-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	outcomeFile := filepath.Join(tmpDir, "outcome")
+	recordOutcome := []interface{}{"sh", "-c", fmt.Sprintf("echo \"$DEPLOBOX_STATUS:$DEPLOBOX_ERROR\" > %s", outcomeFile)}
+
+	testProject := &project.Project{
+		Name:                "hooks-project",
+		Path:                projectPath,
+		Secret:              "hooks-test-secret-at-least-32-chars-long-here",
+		Branch:              "main",
+		PullTimeout:         60,
+		PreDeployTimeout:    30,
+		PreDeploy:           []interface{}{[]interface{}{"touch", "maintenance"}},
+		PostDeployTimeout:   300,
+		PostActivateTimeout: 30,
+		OnSuccess:           []interface{}{recordOutcome},
+		OnFailure:           []interface{}{recordOutcome},
+	}
+	push := &deployment.PushEvent{Ref: "refs/heads/main", Commit: gitHeadCommit(t, initialRelease)}
+
+	t.Run("Success", func(t *testing.T) {
+		deploy := deployment.NewDeployment(testProject, push, true, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != 200 {
+			t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+		}
+
+		// pre_deploy runs in the release that was current before the deploy
+		if !fileutil.FileExists(filepath.Join(initialRelease, "maintenance")) {
+			t.Error("Expected pre_deploy command to run in the previous current release")
+		}
+
+		content, err := os.ReadFile(outcomeFile)
+		if err != nil {
+			t.Fatalf("Expected on_success to write outcome file: %v", err)
+		}
+		if strings.TrimSpace(string(content)) != "success:" {
+			t.Errorf("Expected outcome 'success:', got %q", content)
+		}
+	})
+
+	t.Run("PreDeployFailureRunsOnFailure", func(t *testing.T) {
+		time.Sleep(1 * time.Second) // Ensure a different release timestamp
+
+		entriesBefore, _ := os.ReadDir(releasesDir)
+
+		failingProject := *testProject
+		failingProject.PreDeploy = []interface{}{"false"}
+
+		deploy := deployment.NewDeployment(&failingProject, push, false, nil)
+		response, statusCode := deploy.Execute(context.Background())
+		if statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %v", statusCode, response)
+		}
+
+		entriesAfter, _ := os.ReadDir(releasesDir)
+		if len(entriesAfter) != len(entriesBefore) {
+			t.Errorf("Expected no release to be created after pre_deploy failure, had %d now %d", len(entriesBefore), len(entriesAfter))
+		}
+
+		content, err := os.ReadFile(outcomeFile)
+		if err != nil {
+			t.Fatalf("Expected on_failure to write outcome file: %v", err)
+		}
+		if !strings.HasPrefix(strings.TrimSpace(string(content)), "failed:Pre-deploy command failed") {
+			t.Errorf("Expected failed outcome with error, got %q", content)
+		}
+	})
+}
+
+// TestHookEnvironment ensures hook commands receive the DEPLOBOX_* variables and project env
+func TestHookEnvironment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "env-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	envFile := filepath.Join(tmpDir, "deploy.env")
+	if err := os.WriteFile(envFile, []byte("FROM_FILE=file\nOVERRIDDEN=file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+
+	envDump := filepath.Join(tmpDir, "env.dump")
+	testProject := &project.Project{
+		Name:              "env-project",
+		Path:              projectPath,
+		Secret:            "env-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 300,
+		PostDeploy:        []interface{}{[]interface{}{"sh", "-c", "env > " + envDump}},
+		Env:               map[string]string{"OVERRIDDEN": "env"},
+		EnvFile:           envFile,
+		CleanEnv:          true,
+	}
+
+	commit := gitHeadCommit(t, initialRelease)
+	deploy := deployment.NewDeployment(testProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: commit, Pusher: "octocat"}, false, nil)
+	response, statusCode := deploy.Execute(context.Background())
+	if statusCode != 200 {
+		t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+	}
+
+	content, err := os.ReadFile(envDump)
+	if err != nil {
+		t.Fatalf("Expected post_deploy to dump its environment: %v", err)
+	}
+	env := map[string]string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			env[key] = value
+		}
+	}
+
+	expected := map[string]string{
+		"DEPLOBOX_PROJECT":          "env-project",
+		"DEPLOBOX_RELEASE_DIR":      deploy.ReleaseDir,
+		"DEPLOBOX_PREVIOUS_RELEASE": initialRelease,
+		"DEPLOBOX_COMMIT":           commit,
+		"DEPLOBOX_BRANCH":           "main",
+		"DEPLOBOX_DEPLOYMENT_ID":    deploy.ID,
+		"DEPLOBOX_PUSHER":           "octocat",
+		"DEPLOBOX_TRIGGER":          "webhook",
+		"FROM_FILE":                 "file",
+		"OVERRIDDEN":                "env",
+	}
+	for key, value := range expected {
+		if env[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, env[key])
+		}
+	}
+	if deploy.ReleaseDir == "" || deploy.ID == "" {
+		t.Error("Expected release dir and deployment ID to be set")
+	}
+
+	// clean_env: the server's environment is not inherited
+	if _, ok := env["HOME"]; ok && os.Getenv("HOME") != "" {
+		t.Error("Expected HOME not to be inherited with clean_env")
+	}
+}
+
+// TestFailedReleaseCleanup ensures a release whose post_deploy fails is removed,
+// or kept with keep_failed until the next failure replaces it
+func TestFailedReleaseCleanup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "failed-project")
+	releasesDir := filepath.Join(projectPath, "releases")
+
+	initialRelease := filepath.Join(releasesDir, "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	testProject := &project.Project{
+		Name:              "failed-project",
+		Path:              projectPath,
+		Secret:            "failed-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 30,
+		PostDeploy:        []interface{}{"false"},
+	}
+	push := &deployment.PushEvent{Ref: "refs/heads/main", Commit: gitHeadCommit(t, initialRelease)}
+
+	// releaseEntries returns everything in releases/ other than the initial release
+	releaseEntries := func(t *testing.T) []string {
+		entries, err := os.ReadDir(releasesDir)
+		if err != nil {
+			t.Fatalf("Failed to read releases dir: %v", err)
+		}
+		var names []string
+		for _, entry := range entries {
+			if entry.Name() != "2025-01-01-00-00-00" {
+				names = append(names, entry.Name())
+			}
+		}
+		return names
+	}
+
+	t.Run("RemovesFailedBuild", func(t *testing.T) {
+		deploy := deployment.NewDeployment(testProject, push, false, nil)
+		if _, statusCode := deploy.Execute(context.Background()); statusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d", statusCode)
+		}
+		if names := releaseEntries(t); len(names) != 0 {
+			t.Errorf("Expected failed build to be removed, found %v", names)
+		}
+	})
+
+	t.Run("KeepFailedKeepsLastFailedBuild", func(t *testing.T) {
+		keepProject := *testProject
+		keepProject.KeepFailed = true
+
+		var lastRelease string
+		for i := 0; i < 2; i++ {
+			time.Sleep(1 * time.Second) // Ensure a different release timestamp
+			deploy := deployment.NewDeployment(&keepProject, push, false, nil)
+			if _, statusCode := deploy.Execute(context.Background()); statusCode != http.StatusInternalServerError {
+				t.Fatalf("Expected status 500, got %d", statusCode)
+			}
+			lastRelease = deploy.ReleaseDir
+		}
+
+		names := releaseEntries(t)
+		expected := []string{filepath.Base(lastRelease), filepath.Base(lastRelease) + deployment.IncompleteSuffix}
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected only the last failed build %v, found %v", expected, names)
+		}
+
+		// The failed build is not a release restore or cleanup would consider
+		releases, err := deployment.NewExecutor(projectPath).ListReleases()
+		if err != nil {
+			t.Fatalf("ListReleases error: %v", err)
+		}
+		if len(releases) != 1 || releases[0].Path != initialRelease {
+			t.Errorf("Expected only the initial release to be listed, got %+v", releases)
+		}
+	})
+}
+
+// TestCompareReleases ensures the commits and changed files between two releases are reported
+func TestCompareReleases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "compare-project")
+
+	initialRelease := filepath.Join(projectPath, "releases", "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+	initialCommit := gitHeadCommit(t, initialRelease)
+
+	// Push a second commit from a scratch clone, leaving the initial release at the first
+	scratch := filepath.Join(tmpDir, "scratch")
+	for _, cmdParts := range [][]string{
+		{"git", "clone", "--quiet", "--branch", "main", filepath.Join(projectPath, "origin.git"), scratch},
+		{"git", "-C", scratch, "-c", "user.email=test@example.com", "-c", "user.name=Test User", "commit", "--quiet", "--allow-empty", "-m", "Empty commit"},
+		{"sh", "-c", "echo 'changed' > " + filepath.Join(scratch, "README.md")},
+		{"git", "-C", scratch, "-c", "user.email=test@example.com", "-c", "user.name=Test User", "commit", "--quiet", "-am", "Change README"},
+		{"git", "-C", scratch, "push", "--quiet", "origin", "main"},
+	} {
+		if output, err := exec.Command(cmdParts[0], cmdParts[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("Command %v failed: %v, output: %s", cmdParts, err, output)
+		}
+	}
+	newCommit := gitHeadCommit(t, scratch)
+
+	testProject := &project.Project{
+		Name:              "compare-project",
+		Path:              projectPath,
+		Secret:            "compare-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 300,
+	}
+	deploy := deployment.NewDeployment(testProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: newCommit}, false, nil)
+	if response, statusCode := deploy.Execute(context.Background()); statusCode != 200 {
+		t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+	}
+
+	executor := deployment.NewExecutor(projectPath)
+	from, err := executor.FindRelease("2025-01-01-00-00-00")
+	if err != nil {
+		t.Fatalf("FindRelease error: %v", err)
+	}
+	to, err := executor.FindRelease(filepath.Base(deploy.ReleaseDir))
+	if err != nil {
+		t.Fatalf("FindRelease error: %v", err)
+	}
+
+	// The legacy release has no manifest, so its commit comes from its .git
+	if commit, err := executor.ReleaseCommit(context.Background(), *from, 30); err != nil || commit != initialCommit {
+		t.Errorf("Expected legacy release commit %s, got %s (%v)", initialCommit, commit, err)
+	}
+
+	log, stat, err := executor.CompareReleases(context.Background(), *from, *to, 30)
+	if err != nil {
+		t.Fatalf("CompareReleases error: %v", err)
+	}
+	if !strings.Contains(log, "Change README") || !strings.Contains(log, "Empty commit") {
+		t.Errorf("Expected both new commits in log, got %q", log)
+	}
+	if !strings.Contains(stat, "README.md") {
+		t.Errorf("Expected README.md in diffstat, got %q", stat)
+	}
+}
+
+// TestGitHubReport ensures GitHub is told about the commit actually deployed,
+// even when the push did not name one
+func TestGitHubReport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "report-project")
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	initialRelease := filepath.Join(projectPath, "releases", "2025-01-01-00-00-00")
+	if err := setupTestGitRepo(t, initialRelease); err != nil {
+		t.Fatalf("Failed to setup initial release git repo: %v", err)
+	}
+	if err := os.Symlink(initialRelease, filepath.Join(projectPath, "current")); err != nil {
+		t.Fatalf("Failed to create current symlink: %v", err)
+	}
+
+	var mu sync.Mutex
+	var refs, states []string
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/statuses") {
+			states = append(states, fmt.Sprint(body["state"]))
+		} else {
+			refs = append(refs, fmt.Sprint(body["ref"]))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer github.Close()
+
+	secret := "report-test-secret-at-least-32-chars-long-here"
+	testProject := &project.Project{
+		Name:              "report-project",
+		Path:              projectPath,
+		Secret:            secret,
+		Branch:            "main",
+		PullTimeout:       60,
+		PostDeployTimeout: 300,
+		GitHubReporter: &project.GitHubReporter{
+			Repository: "my-org/my-site",
+			Token:      "ghp_test",
+			BaseURL:    github.URL,
+		},
+	}
+	registry := project.NewRegistry(map[string]*project.Project{"report-project": testProject})
+	srv := server.NewServer(registry, nil, slog.New(slog.NewTextHandler(os.Stderr, nil)), true)
+
+	// The push names no commit, so the branch head is deployed and reported
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+	req := httptest.NewRequest("POST", "/in/report-project", strings.NewReader(string(payload)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", server.MakeTestSignature(payload, secret))
+
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	srv.WaitForDeployments()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if head := gitHeadCommit(t, initialRelease); len(refs) != 1 || refs[0] != head {
+		t.Errorf("Expected one GitHub deployment of %s, got %v", head, refs)
+	}
+	if len(states) != 2 || states[0] != "in_progress" || states[1] != "success" {
+		t.Errorf("Expected in_progress then success, got %v", states)
+	}
+}
+
+// TestFirstDeploymentPreDeploy ensures pre_deploy runs on the first deployment,
+// before any release is current
+func TestFirstDeploymentPreDeploy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "first-project")
+	if err := os.MkdirAll(filepath.Join(projectPath, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create shared dir: %v", err)
+	}
+
+	// A pushed repository and its cache, but no release yet
+	workRepo := filepath.Join(tmpDir, "src", "work")
+	if err := setupTestGitRepo(t, workRepo); err != nil {
+		t.Fatalf("Failed to setup git repo: %v", err)
+	}
+	cacheCmd := exec.Command("git", "clone", "--bare", filepath.Join(tmpDir, "origin.git"), deployment.RepoCachePath(projectPath))
+	if output, err := cacheCmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create repository cache: %v, output: %s", err, output)
+	}
+
+	testProject := &project.Project{
+		Name:              "first-project",
+		Path:              projectPath,
+		Secret:            "first-test-secret-at-least-32-chars-long-here",
+		Branch:            "main",
+		PullTimeout:       60,
+		PreDeployTimeout:  30,
+		PostDeployTimeout: 300,
+		PreDeploy:         []interface{}{[]interface{}{"touch", "pre-deploy-ran"}},
+	}
+
+	deploy := deployment.NewDeployment(testProject, &deployment.PushEvent{Ref: "refs/heads/main", Commit: gitHeadCommit(t, workRepo)}, false, nil)
+	response, statusCode := deploy.Execute(context.Background())
+	if statusCode != 200 {
+		t.Fatalf("Expected status 200, got %d: %v", statusCode, response)
+	}
+
+	if !fileutil.FileExists(filepath.Join(projectPath, "pre-deploy-ran")) {
+		t.Error("Expected pre_deploy to run in the project root")
+	}
+	if !fileutil.SymlinkExists(filepath.Join(projectPath, "current")) {
+		t.Error("Expected the first release to be activated")
+	}
+}
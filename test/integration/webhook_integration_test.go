@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -160,8 +161,9 @@ func TestWebhookHistoryRecording(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	srv := server.NewServer(registry, hist, logger, false)
 
-	// Test that failed deployment is recorded
-	payload := []byte(`{"ref":"refs/heads/main","after":"abc123"}`)
+	// Test that the deployment is recorded with the checked out commit
+	headCommit := gitHeadCommit(t, initialRelease)
+	payload := []byte(fmt.Sprintf(`{"ref":"refs/heads/main","after":"%s"}`, headCommit))
 	signature := server.MakeTestSignature(payload, secret)
 
 	req := httptest.NewRequest("POST", "/in/history-test", bytes.NewReader(payload))
@@ -199,6 +201,9 @@ func TestWebhookHistoryRecording(t *testing.T) {
 		if latest.Status != "success" {
 			t.Errorf("Expected status 'success', got '%s'", latest.Status)
 		}
+		if latest.CommitHash == nil || *latest.CommitHash != headCommit {
+			t.Errorf("Expected commit hash '%s', got %v", headCommit, latest.CommitHash)
+		}
 	}
 }
 
@@ -255,16 +260,16 @@ func TestConcurrentDeploymentLocking(t *testing.T) {
 	rr := httptest.NewRecorder()
 	srv.Router().ServeHTTP(rr, req)
 
-	// Verify request was rejected with 429
-	if rr.Code != http.StatusTooManyRequests {
-		t.Errorf("Expected status 429, got %d", rr.Code)
+	// Verify request was queued behind the running deployment
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", rr.Code)
 	}
 
 	var response map[string]string
 	json.Unmarshal(rr.Body.Bytes(), &response)
 
-	if response["error"] != "Deployment already in progress" {
-		t.Errorf("Expected 'Deployment already in progress' error, got '%s'", response["error"])
+	if response["message"] != "Deployment queued" {
+		t.Errorf("Expected 'Deployment queued' message, got '%s'", response["message"])
 	}
 }
 